@@ -0,0 +1,308 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"xfsgo/common"
+	"xfsgo/common/rawencode"
+	"xfsgo/storage"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The address index maps the sender and the recipient of each transaction
+// to its position, under addrIndexPre+address+height+index+block_hash so that
+// the blocks of side chains have their own keys. It is written with the
+// blocks when enabled, addrIndexFromKey holds the height of the first
+// indexed block and addrIndexLastKey the height of the highest one.
+var (
+	addrIndexPre     = []byte("addrIndex:")
+	addrIndexFromKey = []byte("addrIndexFrom")
+	addrIndexLastKey = []byte("addrIndexLast")
+)
+
+const (
+	addrIndexSender    = uint8(1)
+	addrIndexRecipient = uint8(2)
+
+	// addrIndexCursorLen is the length of a cursor, the position of
+	// an entry: a height, an index and a block hash.
+	addrIndexCursorLen = 16 + 32
+)
+
+var (
+	ErrAddressIndexDisabled = errors.New("address index disabled")
+	errInvalidCursor        = errors.New("invalid cursor")
+)
+
+// AddressTx is a transaction of an address found in the address index.
+type AddressTx struct {
+	BlockHash   common.Hash `json:"block_hash"`
+	BlockHeight uint64      `json:"block_height"`
+	Index       uint64      `json:"index"`
+	TxHash      common.Hash `json:"tx_hash"`
+	Sender      bool        `json:"sender"`
+	Recipient   bool        `json:"recipient"`
+}
+
+type addrIndexEntry struct {
+	txHash common.Hash
+	flags  uint8
+}
+
+// entry = format(1byte)+tx_hash(32byte)+flags(1byte)
+func (e *addrIndexEntry) Encode() ([]byte, error) {
+	w := rawencode.NewBinaryWriter()
+	w.WriteUint8(binaryFormatV1)
+	w.WriteFixed(e.txHash[:])
+	w.WriteUint8(e.flags)
+	return w.Bytes(), nil
+}
+
+func (e *addrIndexEntry) Decode(data []byte) error {
+	r := rawencode.NewBinaryReader(data)
+	if r.ReadUint8() != binaryFormatV1 {
+		return rawencode.ErrFormatVersion
+	}
+	r.ReadFixed(e.txHash[:])
+	e.flags = r.ReadUint8()
+	return r.Finish()
+}
+
+func addrIndexPrefix(addr common.Address) []byte {
+	return append(append([]byte{}, addrIndexPre...), addr.Bytes()...)
+}
+
+// addrIndexPosition encodes a height and an index so that they sort
+// in the order of the chain.
+func addrIndexPosition(height, index uint64) []byte {
+	var pos [16]byte
+	binary.BigEndian.PutUint64(pos[:8], height)
+	binary.BigEndian.PutUint64(pos[8:], index)
+	return pos[:]
+}
+
+func (db *extraDB) writeAddressIndex(w storage.Writer, block *Block) error {
+	if last, ok := db.addressIndexLast(); !ok || block.Height() > last {
+		if err := w.Put(addrIndexLastKey, addrIndexHeight(block.Height())); err != nil {
+			return err
+		}
+	}
+	blockHash := block.Hash()
+	for i, tx := range block.Transactions {
+		flags := make(map[common.Address]uint8)
+		if from, err := tx.FromAddr(); err == nil {
+			flags[from] |= addrIndexSender
+		}
+		flags[tx.To] |= addrIndexRecipient
+		entry := &addrIndexEntry{
+			txHash: tx.Hash(),
+		}
+		for addr, f := range flags {
+			entry.flags = f
+			data, err := rawencode.Encode(entry)
+			if err != nil {
+				return err
+			}
+			key := append(addrIndexPrefix(addr), addrIndexPosition(block.Height(), uint64(i))...)
+			key = append(key, blockHash[:]...)
+			if err = w.Put(key, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func addrIndexHeight(height uint64) []byte {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], height)
+	return data[:]
+}
+
+func (db *extraDB) getAddressIndexHeight(key []byte) (uint64, bool) {
+	data, err := db.storage.GetData(key)
+	if err != nil || len(data) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
+
+// addressIndexFrom returns the height of the first indexed block,
+// false when the index was never enabled.
+func (db *extraDB) addressIndexFrom() (uint64, bool) {
+	return db.getAddressIndexHeight(addrIndexFromKey)
+}
+
+// addressIndexLast returns the height of the highest indexed block,
+// false when no block was indexed.
+func (db *extraDB) addressIndexLast() (uint64, bool) {
+	return db.getAddressIndexHeight(addrIndexLastKey)
+}
+
+func (db *extraDB) setAddressIndexFrom(height uint64) error {
+	return db.storage.SetData(addrIndexFromKey, addrIndexHeight(height))
+}
+
+// EnableAddressIndex indexes the transactions of the blocks written from now
+// on by address. The blocks of a database which was not indexed before, or
+// which were written while the index was disabled, are only indexed by
+// RebuildAddressIndex: the index then starts after the current block.
+func (bc *BlockChain) EnableAddressIndex() error {
+	height := bc.CurrentBlock().Height()
+	if from, ok := bc.extraDB.addressIndexFrom(); ok {
+		// the height after the highest indexed block
+		next := from
+		if last, indexed := bc.extraDB.addressIndexLast(); indexed && last+1 > next {
+			next = last + 1
+		}
+		if next > height {
+			bc.addrIndex = true
+			return nil
+		}
+		logrus.Warnf("Address index misses the blocks %d to %d, "+
+			"run xfsgo db rebuild-address-index to index them", next, height)
+	}
+	if err := bc.extraDB.setAddressIndexFrom(height + 1); err != nil {
+		return err
+	}
+	bc.addrIndex = true
+	return nil
+}
+
+// AddressIndexFrom returns the height of the first block in the address index.
+func (bc *BlockChain) AddressIndexFrom() (uint64, error) {
+	if !bc.addrIndex {
+		return 0, ErrAddressIndexDisabled
+	}
+	from, _ := bc.extraDB.addressIndexFrom()
+	return from, nil
+}
+
+// GetTransactionsByAddress returns at most limit transactions of the canonical
+// chain sent or received by addr between the heights from and to, in the
+// order of the chain. A non empty cursor, returned by the previous call when
+// there are more transactions, continues after them.
+func (bc *BlockChain) GetTransactionsByAddress(addr common.Address, from, to uint64, limit int, cursor []byte) ([]*AddressTx, []byte, error) {
+	if !bc.addrIndex {
+		return nil, nil, ErrAddressIndexDisabled
+	}
+	if len(cursor) != 0 && len(cursor) != addrIndexCursorLen {
+		return nil, nil, errInvalidCursor
+	}
+	prefix := addrIndexPrefix(addr)
+	start := addrIndexPosition(from, 0)
+	if bytes.Compare(cursor, start) > 0 {
+		start = cursor
+	}
+	txs := make([]*AddressTx, 0)
+	// the iterator starts at the cursor, so that each page is read once
+	it := bc.extraDB.storage.NewIterator()
+	defer it.Close()
+	it.Seek(append(append([]byte{}, prefix...), start...))
+	for it.Next() {
+		k := it.Key()
+		if !bytes.HasPrefix(k, prefix) {
+			break
+		}
+		pos := k[len(prefix):]
+		if len(pos) != addrIndexCursorLen {
+			continue
+		}
+		height := binary.BigEndian.Uint64(pos[:8])
+		if height > to {
+			break
+		}
+		blockHash := common.Bytes2Hash(pos[16:])
+		// entries of the blocks of side chains
+		if hash, ok := bc.chainDB.getBlockHashByNumber(height); !ok || hash != blockHash {
+			continue
+		}
+		if len(txs) == limit {
+			return txs, append([]byte{}, pos...), nil
+		}
+		entry := new(addrIndexEntry)
+		if err := rawencode.Decode(it.Val(), entry); err != nil {
+			return nil, nil, err
+		}
+		txs = append(txs, &AddressTx{
+			BlockHash:   blockHash,
+			BlockHeight: height,
+			Index:       binary.BigEndian.Uint64(pos[8:16]),
+			TxHash:      entry.txHash,
+			Sender:      entry.flags&addrIndexSender != 0,
+			Recipient:   entry.flags&addrIndexRecipient != 0,
+		})
+	}
+	return txs, nil, nil
+}
+
+// RebuildAddressIndex replaces the address index by the index of the
+// canonical blocks of the stores, it returns the number of indexed blocks.
+// progress is called with each block when not nil. The index is written in
+// as many transactions as needed, so it starts after the head until the
+// rebuild is done: the error of a failed rebuild says it is incomplete.
+func RebuildAddressIndex(chainDB, extraDB storage.Database, progress func(*Block)) (uint64, error) {
+	chain := newChainDB(chainDB)
+	extra := newExtraDB(extraDB)
+	head := chain.GetHeadBlock()
+	if head == nil {
+		return 0, errNoHeadBlock
+	}
+	if err := storage.Update(extraDB, func(w storage.Writer) error {
+		if err := w.Put(addrIndexFromKey, addrIndexHeight(head.Height()+1)); err != nil {
+			return err
+		}
+		return w.Delete(addrIndexLastKey)
+	}); err != nil {
+		return 0, err
+	}
+	var n uint64
+	err := storage.UpdateChunked(extraDB, func(w storage.Writer) error {
+		if err := extraDB.PrefixForeachData(addrIndexPre, func(k []byte, _ []byte) error {
+			return w.Delete(append([]byte{}, k...))
+		}); err != nil {
+			return err
+		}
+		for height := uint64(0); height <= head.Height(); height++ {
+			block := chain.GetBlockByNumber(height)
+			if block == nil {
+				continue
+			}
+			if err := extra.writeAddressIndex(w, block); err != nil {
+				return err
+			}
+			n++
+			if progress != nil {
+				progress(block)
+			}
+		}
+		return w.Put(addrIndexLastKey, addrIndexHeight(head.Height()))
+	})
+	if err == nil {
+		err = extra.setAddressIndexFrom(0)
+	}
+	if err != nil {
+		return n, fmt.Errorf("address index incomplete, it starts at height %d "+
+			"until it is rebuilt again: %v", head.Height()+1, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,291 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"strings"
+	"testing"
+	"xfsgo/assert"
+	"xfsgo/common"
+	"xfsgo/crypto"
+	"xfsgo/storage"
+)
+
+func newTestAccount(t *testing.T) (*ecdsa.PrivateKey, common.Address) {
+	key, err := crypto.GenPrvKey()
+	assert.Error(t, err)
+	return key, crypto.DefaultPubKey2Addr(key.PublicKey)
+}
+
+func newTestTransfer(t *testing.T, key *ecdsa.PrivateKey, to common.Address, nonce uint64) *Transaction {
+	tx := NewTransaction(to, big.NewInt(1))
+	tx.Nonce = nonce
+	assert.Error(t, tx.SignWithPrivateKey(key))
+	return tx
+}
+
+// writeTestBlock writes a block with the transactions on top of parent,
+// the block is not validated.
+func writeTestBlock(t *testing.T, bc *BlockChain, parent *Block, txs []*Transaction) *Block {
+	block := NewBlock(&BlockHeader{
+		Height:        parent.Height() + 1,
+		Version:       BlockVersion,
+		HashPrevBlock: parent.Hash(),
+		Timestamp:     parent.Timestamp() + uint64(len(txs)) + 1,
+		StateRoot:     parent.StateRoot(),
+		Bits:          parent.Bits(),
+	}, txs, nil)
+	assert.Error(t, bc.WriteBlock(block))
+	return block
+}
+
+func addressTxPositions(t *testing.T, bc *BlockChain, addr common.Address, from, to uint64) [][2]uint64 {
+	txs, next, err := bc.GetTransactionsByAddress(addr, from, to, 100, nil)
+	assert.Error(t, err)
+	if next != nil {
+		t.Fatalf("got cursor %x on the last page", next)
+	}
+	positions := make([][2]uint64, 0)
+	for _, tx := range txs {
+		positions = append(positions, [2]uint64{tx.BlockHeight, tx.Index})
+	}
+	return positions
+}
+
+func TestBlockChain_GetTransactionsByAddress(t *testing.T) {
+	bc := newTestChain(t)
+	keyA, addrA := newTestAccount(t)
+	keyB, addrB := newTestAccount(t)
+	_, addrC := newTestAccount(t)
+	if _, _, err := bc.GetTransactionsByAddress(addrA, 0, 10, 10, nil); err != ErrAddressIndexDisabled {
+		t.Fatalf("got err %v, want %v", err, ErrAddressIndexDisabled)
+	}
+	assert.Error(t, bc.EnableAddressIndex())
+	from, err := bc.AddressIndexFrom()
+	assert.Error(t, err)
+	if from != 1 {
+		t.Fatalf("got indexed from %d, want 1", from)
+	}
+	genesis := bc.CurrentBlock()
+	block1 := writeTestBlock(t, bc, genesis, []*Transaction{
+		newTestTransfer(t, keyA, addrB, 0),
+		newTestTransfer(t, keyB, addrC, 0),
+	})
+	writeTestBlock(t, bc, block1, []*Transaction{
+		newTestTransfer(t, keyA, addrC, 1),
+	})
+	// a side block at the same height
+	writeTestBlock(t, bc, block1, []*Transaction{
+		newTestTransfer(t, keyA, addrB, 1),
+	})
+
+	txs, _, err := bc.GetTransactionsByAddress(addrB, 0, 10, 10, nil)
+	assert.Error(t, err)
+	if len(txs) != 2 || !txs[0].Recipient || txs[0].Sender || !txs[1].Sender || txs[1].Recipient {
+		t.Fatalf("got %+v, want the transfer to B and the transfer of B", txs)
+	}
+	if txs[0].TxHash != block1.Transactions[0].Hash() || txs[0].BlockHash != block1.Hash() {
+		t.Fatalf("got %+v, want the first transaction of block 1", txs[0])
+	}
+	tests := []struct {
+		addr     common.Address
+		from, to uint64
+		want     [][2]uint64
+	}{
+		{addrA, 0, 10, [][2]uint64{{1, 0}, {2, 0}}},
+		{addrA, 2, 10, [][2]uint64{{2, 0}}},
+		{addrA, 0, 1, [][2]uint64{{1, 0}}},
+		{addrC, 0, 10, [][2]uint64{{1, 1}, {2, 0}}},
+	}
+	for _, test := range tests {
+		got := addressTxPositions(t, bc, test.addr, test.from, test.to)
+		if len(got) != len(test.want) {
+			t.Fatalf("got %v, want %v", got, test.want)
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		}
+	}
+
+	// pages of one transaction
+	var cursor []byte
+	pages := 0
+	for {
+		txs, next, err := bc.GetTransactionsByAddress(addrC, 0, 10, 1, cursor)
+		assert.Error(t, err)
+		pages += len(txs)
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+	if pages != 2 {
+		t.Fatalf("got %d transactions in pages, want 2", pages)
+	}
+	if _, _, err = bc.GetTransactionsByAddress(addrC, 0, 10, 1, []byte{1}); err != errInvalidCursor {
+		t.Fatalf("got err %v, want %v", err, errInvalidCursor)
+	}
+}
+
+func TestRebuildAddressIndex(t *testing.T) {
+	bc := newTestChain(t)
+	keyA, addrA := newTestAccount(t)
+	_, addrB := newTestAccount(t)
+	block := writeTestBlock(t, bc, bc.CurrentBlock(), []*Transaction{
+		newTestTransfer(t, keyA, addrB, 0),
+	})
+	writeTestBlock(t, bc, block, nil)
+	n, err := RebuildAddressIndex(bc.chainDB.storage, bc.extraDB.storage, nil)
+	assert.Error(t, err)
+	if n != 3 {
+		t.Fatalf("got %d indexed blocks, want 3", n)
+	}
+	assert.Error(t, bc.EnableAddressIndex())
+	from, err := bc.AddressIndexFrom()
+	assert.Error(t, err)
+	if from != 0 {
+		t.Fatalf("got indexed from %d, want 0", from)
+	}
+	if got := addressTxPositions(t, bc, addrA, 0, 10); len(got) != 1 || got[0] != [2]uint64{1, 0} {
+		t.Fatalf("got %v, want the transaction of block 1", got)
+	}
+}
+
+func TestBlockChain_EnableAddressIndexGap(t *testing.T) {
+	bc := newTestChain(t)
+	key, _ := newTestAccount(t)
+	_, to := newTestAccount(t)
+	reopen := func(index bool) *BlockChain {
+		reopened, err := NewBlockChain(bc.stateDB, bc.chainDB.storage, bc.extraDB.storage, NewEventBus())
+		assert.Error(t, err)
+		if index {
+			assert.Error(t, reopened.EnableAddressIndex())
+		}
+		return reopened
+	}
+	assert.Error(t, bc.EnableAddressIndex())
+	block := writeTestBlock(t, bc, bc.CurrentBlock(), []*Transaction{newTestTransfer(t, key, to, 0)})
+
+	// restarted with the index, nothing is missing
+	bc = reopen(true)
+	if from, _ := bc.AddressIndexFrom(); from != 1 {
+		t.Fatalf("got indexed from %d, want 1", from)
+	}
+	// blocks written while the index is disabled
+	bc = reopen(false)
+	block = writeTestBlock(t, bc, block, []*Transaction{newTestTransfer(t, key, to, 1)})
+	block = writeTestBlock(t, bc, block, nil)
+	bc = reopen(true)
+	if from, _ := bc.AddressIndexFrom(); from != 4 {
+		t.Fatalf("got indexed from %d, want 4", from)
+	}
+	writeTestBlock(t, bc, block, []*Transaction{newTestTransfer(t, key, to, 2)})
+	if got := addressTxPositions(t, bc, to, 4, 10); len(got) != 1 || got[0] != [2]uint64{4, 0} {
+		t.Fatalf("got %v, want the transaction of block 4", got)
+	}
+	bc = reopen(true)
+	if from, _ := bc.AddressIndexFrom(); from != 4 {
+		t.Fatalf("got indexed from %d after a restart, want 4", from)
+	}
+}
+
+func TestRebuildAddressIndex_Chunked(t *testing.T) {
+	bc := newTestChain(t)
+	keyA, addrA := newTestAccount(t)
+	_, addrB := newTestAccount(t)
+	block := bc.CurrentBlock()
+	for i := uint64(0); i < 3; i++ {
+		block = writeTestBlock(t, bc, block, []*Transaction{
+			newTestTransfer(t, keyA, addrB, 2*i),
+			newTestTransfer(t, keyA, addrB, 2*i+1),
+		})
+	}
+	// a transaction holds fewer writes than the index
+	extraDb := &smallTxnDB{Database: bc.extraDB.storage, maxPuts: 2}
+	for i := 0; i < 2; i++ {
+		n, err := RebuildAddressIndex(bc.chainDB.storage, extraDb, nil)
+		assert.Error(t, err)
+		if n != 4 {
+			t.Fatalf("got %d indexed blocks, want 4", n)
+		}
+	}
+	assert.Error(t, bc.EnableAddressIndex())
+	if from, _ := bc.AddressIndexFrom(); from != 0 {
+		t.Fatalf("got indexed from %d, want 0", from)
+	}
+	if got := addressTxPositions(t, bc, addrA, 0, 10); len(got) != 6 {
+		t.Fatalf("got %v, want 6 transactions", got)
+	}
+	if got := addressTxPositions(t, bc, addrB, 2, 2); len(got) != 2 || got[1] != [2]uint64{2, 1} {
+		t.Fatalf("got %v, want the transactions of block 2", got)
+	}
+}
+
+// commitLimitDB fails the commits of the transactions after the first commits.
+type commitLimitDB struct {
+	storage.Database
+	commits int
+}
+
+type commitLimitTxn struct {
+	storage.Txn
+	db *commitLimitDB
+}
+
+func (t *commitLimitTxn) Commit() error {
+	if t.db.commits == 0 {
+		t.Txn.Discard()
+		return errTestCommit
+	}
+	t.db.commits--
+	return t.Txn.Commit()
+}
+
+func (db *commitLimitDB) NewTxn() storage.Txn {
+	return &commitLimitTxn{Txn: db.Database.NewTxn(), db: db}
+}
+
+func TestRebuildAddressIndex_Failure(t *testing.T) {
+	bc := newTestChain(t)
+	key, to := newTestAccount(t)
+	block := bc.CurrentBlock()
+	for i := uint64(0); i < 3; i++ {
+		block = writeTestBlock(t, bc, block, []*Transaction{newTestTransfer(t, key, to, i)})
+	}
+	_, err := RebuildAddressIndex(bc.chainDB.storage, bc.extraDB.storage, nil)
+	assert.Error(t, err)
+	// the markers and the first chunk are committed
+	extraDb := &smallTxnDB{
+		Database: &commitLimitDB{Database: bc.extraDB.storage, commits: 2},
+		maxPuts:  2,
+	}
+	if _, err = RebuildAddressIndex(bc.chainDB.storage, extraDb, nil); err == nil ||
+		!strings.Contains(err.Error(), "incomplete") {
+		t.Fatalf("got err %v, want an incomplete index", err)
+	}
+	if from, ok := bc.extraDB.addressIndexFrom(); !ok || from != 4 {
+		t.Fatalf("got stored index start %d %v, want 4", from, ok)
+	}
+	assert.Error(t, bc.EnableAddressIndex())
+	if from, _ := bc.AddressIndexFrom(); from != 4 {
+		t.Fatalf("got indexed from %d after a failed rebuild, want 4", from)
+	}
+}
@@ -17,7 +17,10 @@
 package api
 
 import (
+	"context"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"xfsgo"
 	"xfsgo/common"
 	"xfsgo/common/urlsafeb64"
@@ -30,6 +33,7 @@ import (
 type ChainAPIHandler struct {
 	BlockChain    *xfsgo.BlockChain
 	TxPendingPool *xfsgo.TxPool
+	SyncTracker   *xfsgo.SyncTracker
 }
 
 type GetBlockByIdArgs struct {
@@ -64,6 +68,44 @@ type SendRawTransactionArgs struct {
 	Data string `json:"data"`
 }
 
+// GetTransactionsByAddressArgs selects the transactions of Address between
+// the heights FromHeight and ToHeight, at most Limit, from the Cursor of
+// the previous page on.
+type GetTransactionsByAddressArgs struct {
+	Address    string      `json:"address"`
+	FromHeight json.Number `json:"from_height"`
+	ToHeight   json.Number `json:"to_height"`
+	Limit      json.Number `json:"limit"`
+	Cursor     string      `json:"cursor"`
+}
+
+// AddressTransaction is a transaction of an address with its position.
+type AddressTransaction struct {
+	*xfsgo.AddressTx
+	Transaction *TransferObj `json:"transaction"`
+}
+
+// AddressTransactions is a page of the transactions of an address, Next is
+// the cursor of the next page and is empty on the last one. The blocks
+// before IndexedFrom are not indexed.
+type AddressTransactions struct {
+	Transactions []*AddressTransaction `json:"transactions"`
+	Next         string                `json:"next"`
+	IndexedFrom  uint64                `json:"indexed_from"`
+}
+
+const (
+	defaultAddressTxsLimit = 100
+	maxAddressTxsLimit     = 1000
+)
+
+// GetStatsArgs selects the number of recent blocks averaged by GetStats.
+type GetStatsArgs struct {
+	Window json.Number `json:"window"`
+}
+
+const maxStatsWindow = 10000
+
 type GetBlockSectionArgs struct {
 	From  json.Number `json:"from"`
 	Count json.Number `json:"count"`
@@ -122,10 +164,47 @@ func (receiver *ChainAPIHandler) GetReceiptByHash(args GetReceiptByHashArgs, rec
 	return nil
 }
 
-func (receiver *ChainAPIHandler) GetTransaction(args GetTransactionArgs, resp *TransferObj) error {
+// GetTransaction returns the transaction with the hash, mined or in the pool,
+// with its status and the block including it.
+func (receiver *ChainAPIHandler) GetTransaction(args GetTransactionArgs, resp *TransactionInfo) error {
 	ID := common.Hex2Hash(args.Hash)
+	result := &TransactionInfo{}
 	data := receiver.BlockChain.GetTransaction(ID)
-	result := NewTransferObj(data)
+	lookup := receiver.BlockChain.GetTransactionLookup(ID)
+	var (
+		pooled      *xfsgo.Transaction
+		processable bool
+	)
+	if receiver.TxPendingPool != nil {
+		pooled, processable = receiver.TxPendingPool.GetTransaction(ID)
+	}
+	switch {
+	case data != nil && lookup != nil && lookup.Canonical:
+		result.Status = TxStatusMined
+		result.BlockHash = lookup.BlockHash
+		result.BlockHeight = lookup.BlockHeight
+		result.Index = lookup.Index
+		if head := receiver.BlockChain.CurrentBlock().Height(); head >= lookup.BlockHeight {
+			result.Confirmations = head - lookup.BlockHeight + 1
+		}
+	case pooled != nil:
+		data = pooled
+		result.Status = TxStatusQueued
+		if processable {
+			result.Status = TxStatusPending
+		}
+	case data != nil && lookup != nil:
+		result.Status = TxStatusSideChain
+		result.BlockHash = lookup.BlockHash
+		result.BlockHeight = lookup.BlockHeight
+		result.Index = lookup.Index
+	default:
+		return xfsgo.NewRPCError(-32001, "Not found transaction")
+	}
+	result.TransferObj = NewTransferObj(data)
+	if from, err := data.FromAddr(); err == nil {
+		result.From = from.B58String()
+	}
 	*resp = *result
 	return nil
 }
@@ -151,7 +230,7 @@ func (receiver *ChainAPIHandler) SendRawTransaction(args SendRawTransactionArgs,
 	return nil
 }
 
-func (receiver *ChainAPIHandler) GetBlockSection(args GetBlockSectionArgs, resp *GetBlocks) error {
+func (receiver *ChainAPIHandler) GetBlockSection(ctx context.Context, args GetBlockSectionArgs, resp *GetBlocks) error {
 
 	numbersForm, err := common.Uint64s(args.From)
 	if err != nil {
@@ -161,7 +240,10 @@ func (receiver *ChainAPIHandler) GetBlockSection(args GetBlockSectionArgs, resp
 	if err != nil {
 		return xfsgo.NewRPCErrorCause(-32001, err)
 	}
-	data := receiver.BlockChain.GetBlockSection(numbersForm, numbersCount)
+	data := receiver.BlockChain.GetBlockSection(ctx, numbersForm, numbersCount)
+	if err = ctx.Err(); err != nil {
+		return xfsgo.NewRPCErrorCause(-32603, err)
+	}
 	GetBlockByNumberBlock := make([]*GetBlockByNumberBlock, 0)
 	if len(data) == 0 {
 		*resp = GetBlockByNumberBlock
@@ -175,3 +257,108 @@ func (receiver *ChainAPIHandler) GetBlockSection(args GetBlockSectionArgs, resp
 	*resp = GetBlockByNumberBlock
 	return nil
 }
+
+// GetTransactionsByAddress returns the transactions of the canonical chain sent
+// or received by an address, it needs the address index of the node.
+func (receiver *ChainAPIHandler) GetTransactionsByAddress(args GetTransactionsByAddressArgs, resp *AddressTransactions) error {
+	if args.Address == "" {
+		return xfsgo.NewRPCError(-32602, "Address not found")
+	}
+	address := common.B58ToAddress([]byte(args.Address))
+	var (
+		from, to uint64
+		limit    = uint64(defaultAddressTxsLimit)
+		err      error
+	)
+	if args.FromHeight != "" {
+		if from, err = common.Uint64s(args.FromHeight); err != nil {
+			return xfsgo.NewRPCErrorCause(-32602, err)
+		}
+	}
+	to = receiver.BlockChain.CurrentBlock().Height()
+	if args.ToHeight != "" {
+		if to, err = common.Uint64s(args.ToHeight); err != nil {
+			return xfsgo.NewRPCErrorCause(-32602, err)
+		}
+	}
+	if args.Limit != "" {
+		if limit, err = common.Uint64s(args.Limit); err != nil {
+			return xfsgo.NewRPCErrorCause(-32602, err)
+		}
+	}
+	if limit == 0 || limit > maxAddressTxsLimit {
+		return xfsgo.NewRPCError(-32602, fmt.Sprintf("Limit must be between 1 and %d", maxAddressTxsLimit))
+	}
+	cursor, err := hex.DecodeString(args.Cursor)
+	if err != nil {
+		return xfsgo.NewRPCErrorCause(-32602, err)
+	}
+	indexedFrom, err := receiver.BlockChain.AddressIndexFrom()
+	if err != nil {
+		return xfsgo.NewRPCErrorCause(-32001, err)
+	}
+	txs, next, err := receiver.BlockChain.GetTransactionsByAddress(address, from, to, int(limit), cursor)
+	if err != nil {
+		return xfsgo.NewRPCErrorCause(-32001, err)
+	}
+	result := &AddressTransactions{
+		Transactions: make([]*AddressTransaction, 0, len(txs)),
+		Next:         hex.EncodeToString(next),
+		IndexedFrom:  indexedFrom,
+	}
+	for _, tx := range txs {
+		item := &AddressTransaction{AddressTx: tx}
+		if data := receiver.BlockChain.GetTransaction(tx.TxHash); data != nil {
+			item.Transaction = NewTransferObj(data)
+		}
+		result.Transactions = append(result.Transactions, item)
+	}
+	*resp = *result
+	return nil
+}
+
+// GetStats returns the statistics of the last blocks of the chain.
+func (receiver *ChainAPIHandler) GetStats(args GetStatsArgs, resp *ChainStats) error {
+	window := uint64(xfsgo.DefaultStatsWindow)
+	if args.Window != "" {
+		var err error
+		if window, err = common.Uint64s(args.Window); err != nil {
+			return xfsgo.NewRPCErrorCause(-32602, err)
+		}
+	}
+	if window == 0 || window > maxStatsWindow {
+		return xfsgo.NewRPCError(-32602, fmt.Sprintf("Window must be between 1 and %d", maxStatsWindow))
+	}
+	stats, err := receiver.BlockChain.GetStats(window)
+	if err != nil {
+		return xfsgo.NewRPCErrorCause(-32001, err)
+	}
+	*resp = ChainStats{
+		Height:         stats.Height,
+		Window:         stats.Window,
+		AvgBlockTime:   stats.AvgBlockTime,
+		Bits:           stats.NextBits,
+		Target:         stats.Target,
+		Difficulty:     stats.Difficulty,
+		HashRate:       stats.HashRate,
+		TotalSupply:    stats.TotalSupply,
+		Transactions:   stats.Transactions,
+		AvgTxsPerBlock: stats.AvgTxsPerBlock,
+	}
+	return nil
+}
+
+// SyncStatus returns the progress of the sync with the peers.
+func (receiver *ChainAPIHandler) SyncStatus(_ EmptyArgs, resp *SyncProgress) error {
+	if receiver.SyncTracker == nil {
+		return xfsgo.NewRPCError(-32001, "Sync status not available")
+	}
+	progress := receiver.SyncTracker.Progress()
+	*resp = SyncProgress{
+		Syncing:       progress.Syncing,
+		StartHeight:   progress.StartHeight,
+		CurrentHeight: progress.CurrentHeight,
+		HighestHeight: progress.HighestHeight,
+	}
+	return nil
+}
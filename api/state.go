@@ -17,20 +17,43 @@
 package api
 
 import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"math/big"
+	"strconv"
+	"strings"
 	"xfsgo"
+	"xfsgo/avlmerkle"
 	"xfsgo/common"
-	"xfsgo/storage/badger"
+	"xfsgo/storage"
+)
+
+const (
+	BlockLatest  = "latest"
+	BlockPending = "pending"
 )
 
 type StateAPIHandler struct {
-	StateDb *badger.Storage
+	StateDb    storage.Database
+	BlockChain *xfsgo.BlockChain
+	TxPool     *xfsgo.TxPool
 	// State *xfsgo.StateTree
 }
 
+// GetStateObjArgs selects the state by RootHash, or else by Block.
 type GetStateObjArgs struct {
 	RootHash string `json:"root_hash"`
 	Address  string `json:"address"`
+	Block    string `json:"block"`
+}
+
+// GetAccountArgs reads the account at Address in the state after Block, which
+// is "latest" (the default), "pending", a block height or a block hash.
+type GetAccountArgs struct {
+	Address string `json:"address"`
+	Block   string `json:"block"`
 }
 
 type StateObj struct {
@@ -39,19 +62,114 @@ type StateObj struct {
 	Nonce   uint64   `json:"nonce"`
 }
 
-func (state *StateAPIHandler) GetStateObj(args GetStateObjArgs, resp *StateObj) error {
+type GetProofArgs struct {
+	Address string      `json:"address"`
+	Height  json.Number `json:"height"`
+}
+
+// ListAccountsArgs selects the state like GetStateObjArgs. The accounts are
+// listed in the order of their keys from the hex key Start on, at most Limit.
+type ListAccountsArgs struct {
+	RootHash string      `json:"root_hash"`
+	Block    string      `json:"block"`
+	Start    string      `json:"start"`
+	Limit    json.Number `json:"limit"`
+}
+
+// AccountEntry is an account of ListAccounts. The balance is a decimal
+// string, like in the genesis files, so that it is kept exactly.
+type AccountEntry struct {
+	Key     string `json:"key"`
+	Address string `json:"address"`
+	Balance string `json:"balance"`
+	Nonce   uint64 `json:"nonce"`
+}
+
+// AccountList is a page of accounts, Next is the start of the next page
+// and is empty on the last one.
+type AccountList struct {
+	Accounts []*AccountEntry `json:"accounts"`
+	Next     string          `json:"next"`
+}
+
+const (
+	defaultListAccountsLimit = 100
+	maxListAccountsLimit     = 1000
+)
 
-	if args.RootHash == "" {
-		return xfsgo.NewRPCError(-32601, "Root hash not found")
+// StateProof is an account with the merkle proof of its state against
+// the state root of a block, it can be checked with xfsgo.VerifyStateProof.
+// Account is nil when the proof shows the address has no state.
+type StateProof struct {
+	Height    uint64           `json:"height"`
+	BlockHash common.Hash      `json:"block_hash"`
+	StateRoot common.Hash      `json:"state_root"`
+	Account   *StateObj        `json:"account"`
+	Proof     *avlmerkle.Proof `json:"proof"`
+}
+
+// openState opens the state after the block selected by block, see GetAccountArgs.
+func (state *StateAPIHandler) openState(block string) (*xfsgo.StateTree, error) {
+	var root common.Hash
+	switch {
+	case block == "" || block == BlockLatest:
+		root = state.BlockChain.CurrentBlock().Header.StateRoot
+	case block == BlockPending:
+		stateTree, err := state.BlockChain.PendingState(state.TxPool.GetTransactions())
+		if err != nil {
+			return nil, stateError(err)
+		}
+		return stateTree, nil
+	default:
+		var b *xfsgo.Block
+		if height, err := strconv.ParseUint(block, 10, 64); err == nil {
+			b = state.BlockChain.GetBlockByNumber(height)
+		} else if hash := strings.TrimPrefix(block, "0x"); len(hash) == 2*len(common.Hash{}) {
+			b = state.BlockChain.GetBlockByHash(common.Hex2Hash(hash))
+		} else {
+			return nil, xfsgo.NewRPCError(-32602, "Invalid block, want latest, pending, a height or a hash")
+		}
+		if b == nil {
+			return nil, xfsgo.NewRPCError(-32001, "Not found block")
+		}
+		root = b.Header.StateRoot
 	}
-	if args.Address == "" {
-		return xfsgo.NewRPCError(-32601, "Address not found")
+	stateTree, err := state.BlockChain.StateAt(root)
+	if err != nil {
+		return nil, stateError(err)
 	}
-	roothash := common.Hex2Hash(args.RootHash)
+	return stateTree, nil
+}
 
-	rootHashByte := roothash.Bytes()
+func stateError(err error) error {
+	if err == xfsgo.ErrStateNotFound {
+		return xfsgo.NewRPCErrorCause(-32002, err)
+	}
+	return xfsgo.NewRPCErrorCause(-32603, err)
+}
 
-	stateTree := xfsgo.NewStateTree(state.StateDb, rootHashByte)
+// openStateRoot opens the state with the given root when it is not empty,
+// or else the state after block.
+func (state *StateAPIHandler) openStateRoot(rootHash string, block string) (*xfsgo.StateTree, error) {
+	if rootHash == "" {
+		return state.openState(block)
+	}
+	root := common.Hex2Hash(rootHash)
+	stateTree, err := xfsgo.OpenStateTree(state.StateDb, root.Bytes())
+	if err != nil {
+		return nil, stateError(err)
+	}
+	return stateTree, nil
+}
+
+func (state *StateAPIHandler) GetStateObj(args GetStateObjArgs, resp *StateObj) error {
+	if args.Address == "" {
+		return xfsgo.NewRPCError(-32601, "Address not found")
+	}
+	stateTree, err := state.openStateRoot(args.RootHash, args.Block)
+	if err != nil {
+		return err
+	}
 
 	address := common.B58ToAddress([]byte(args.Address))
 
@@ -76,3 +194,130 @@ func (state *StateAPIHandler) GetStateObj(args GetStateObjArgs, resp *StateObj)
 	*resp = *result
 	return nil
 }
+
+// GetBalance returns the balance of the account in the selected state.
+func (state *StateAPIHandler) GetBalance(args GetAccountArgs, resp **big.Int) error {
+	if args.Address == "" {
+		return xfsgo.NewRPCError(-32601, "Address not found")
+	}
+	stateTree, err := state.openState(args.Block)
+	if err != nil {
+		return err
+	}
+	*resp = stateTree.GetBalance(common.B58ToAddress([]byte(args.Address)))
+	return nil
+}
+
+// GetNonce returns the nonce of the account in the selected state.
+func (state *StateAPIHandler) GetNonce(args GetAccountArgs, resp *uint64) error {
+	if args.Address == "" {
+		return xfsgo.NewRPCError(-32601, "Address not found")
+	}
+	stateTree, err := state.openState(args.Block)
+	if err != nil {
+		return err
+	}
+	*resp = stateTree.GetNonce(common.B58ToAddress([]byte(args.Address)))
+	return nil
+}
+
+// ListAccounts returns a page of the accounts in the selected state.
+func (state *StateAPIHandler) ListAccounts(ctx context.Context, args ListAccountsArgs, resp *AccountList) error {
+	limit := uint64(defaultListAccountsLimit)
+	if args.Limit != "" {
+		var err error
+		if limit, err = common.Uint64s(args.Limit); err != nil {
+			return xfsgo.NewRPCErrorCause(-32602, err)
+		}
+	}
+	if limit == 0 || limit > maxListAccountsLimit {
+		return xfsgo.NewRPCError(-32602, fmt.Sprintf("Limit must be between 1 and %d", maxListAccountsLimit))
+	}
+	var start []byte
+	if args.Start != "" {
+		var err error
+		if start, err = hex.DecodeString(args.Start); err != nil {
+			return xfsgo.NewRPCErrorCause(-32602, err)
+		}
+	}
+	stateTree, err := state.openStateRoot(args.RootHash, args.Block)
+	if err != nil {
+		return err
+	}
+	result := &AccountList{
+		Accounts: make([]*AccountEntry, 0),
+	}
+	err = stateTree.IterateAccounts(start, func(key []byte, obj *xfsgo.StateObj) bool {
+		// stop when the call timed out
+		if ctx.Err() != nil {
+			return true
+		}
+		if uint64(len(result.Accounts)) == limit {
+			result.Next = hex.EncodeToString(key)
+			return true
+		}
+		addr := obj.GetAddress()
+		result.Accounts = append(result.Accounts, &AccountEntry{
+			Key:     hex.EncodeToString(key),
+			Address: addr.B58String(),
+			Balance: obj.GetBalance().String(),
+			Nonce:   obj.GetNonce(),
+		})
+		return false
+	})
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return xfsgo.NewRPCErrorCause(-32603, err)
+	}
+	*resp = *result
+	return nil
+}
+
+// GetProof returns the account at address with the proof of its state at the
+// block of the given height, or at the head block when no height is given.
+func (state *StateAPIHandler) GetProof(args GetProofArgs, resp *StateProof) error {
+	if args.Address == "" {
+		return xfsgo.NewRPCError(-32601, "Address not found")
+	}
+	var block *xfsgo.Block
+	if args.Height == "" {
+		block = state.BlockChain.CurrentBlock()
+	} else {
+		height, err := common.Uint64s(args.Height)
+		if err != nil {
+			return xfsgo.NewRPCErrorCause(-32001, err)
+		}
+		block = state.BlockChain.GetBlockByNumber(height)
+	}
+	if block == nil {
+		return xfsgo.NewRPCError(-32001, "Not found block")
+	}
+	address := common.B58ToAddress([]byte(args.Address))
+	root := block.Header.StateRoot
+	stateTree, err := state.BlockChain.StateAt(root)
+	if err != nil {
+		return stateError(err)
+	}
+	proof, err := stateTree.GetProof(address)
+	if err != nil {
+		return xfsgo.NewRPCErrorCause(-32001, err)
+	}
+	result := &StateProof{
+		Height:    block.Height(),
+		BlockHash: block.Hash(),
+		StateRoot: root,
+		Proof:     proof,
+	}
+	if data := stateTree.GetStateObj(address); data != nil {
+		addr := data.GetAddress()
+		result.Account = &StateObj{
+			Address: addr.B58String(),
+			Balance: data.GetBalance(),
+			Nonce:   data.GetNonce(),
+		}
+	}
+	*resp = *result
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package api
+
+import (
+	"xfsgo"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Topics of the websocket subscriptions.
+const (
+	TopicNewHeads               = "newHeads"
+	TopicNewPendingTransactions = "newPendingTransactions"
+	TopicMinedBlocks            = "minedBlocks"
+	TopicSyncStatus             = "syncStatus"
+)
+
+// SyncStatus is the notification of the syncStatus topic.
+type SyncStatus struct {
+	Syncing bool   `json:"syncing"`
+	Height  uint64 `json:"height"`
+}
+
+// SubscriptionFeed publishes the events of the node to the websocket
+// subscribers of the RPC server.
+type SubscriptionFeed struct {
+	EventBus   *xfsgo.EventBus
+	BlockChain *xfsgo.BlockChain
+	Server     *xfsgo.RPCServer
+}
+
+// Start registers the topics and forwards the events to their subscribers.
+func (feed *SubscriptionFeed) Start() {
+	// the namespace a client needs to subscribe to each topic
+	for topic, namespace := range map[string]string{
+		TopicNewHeads:               "Chain",
+		TopicNewPendingTransactions: "TxPool",
+		TopicMinedBlocks:            "Miner",
+		TopicSyncStatus:             "Chain",
+	} {
+		feed.Server.RegisterTopic(topic, namespace)
+	}
+	// the event bus blocks the publishers until the events are received,
+	// so each one is read by a dedicated loop.
+	go feed.loop(xfsgo.ChainHeadEvent{}, func(e interface{}) (string, interface{}) {
+		block := e.(xfsgo.ChainHeadEvent).Block
+		return TopicNewHeads, NewBlockByNumBlockHeader(block.Header, block.Hash())
+	})
+	go feed.loop(xfsgo.TxPreEvent{}, func(e interface{}) (string, interface{}) {
+		hash := e.(xfsgo.TxPreEvent).Tx.Hash()
+		return TopicNewPendingTransactions, hash.Hex()
+	})
+	go feed.loop(xfsgo.NewMinedBlockEvent{}, func(e interface{}) (string, interface{}) {
+		block := e.(xfsgo.NewMinedBlockEvent).Block
+		return TopicMinedBlocks, NewBlockByNumBlockHeader(block.Header, block.Hash())
+	})
+	go feed.loop(xfsgo.SyncStartEvent{}, func(e interface{}) (string, interface{}) {
+		return TopicSyncStatus, &SyncStatus{Syncing: true, Height: feed.BlockChain.CurrentBlock().Height()}
+	})
+	go feed.loop(xfsgo.SyncDoneEvent{}, func(e interface{}) (string, interface{}) {
+		return TopicSyncStatus, &SyncStatus{Syncing: false, Height: feed.BlockChain.CurrentBlock().Height()}
+	})
+}
+
+func (feed *SubscriptionFeed) loop(event interface{}, convert func(e interface{}) (string, interface{})) {
+	sub := feed.EventBus.Subscript(event)
+	for e := range sub.Chan() {
+		topic, result := convert(e)
+		if err := feed.Server.Notify(topic, result); err != nil {
+			logrus.Warnf("notify %s err: %s", topic, err)
+		}
+	}
+}
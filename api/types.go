@@ -54,5 +54,54 @@ type TransferObj struct {
 	Hash      common.Hash    `json:"hash"`
 }
 
+// Status of a transaction in TransactionInfo.
+const (
+	// TxStatusMined is a transaction of a block of the canonical chain.
+	TxStatusMined = "mined"
+	// TxStatusPending is a processable transaction of the pool.
+	TxStatusPending = "pending"
+	// TxStatusQueued is a transaction of the pool waiting for
+	// the transactions before its nonce.
+	TxStatusQueued = "queued"
+	// TxStatusSideChain is a transaction only found in a block
+	// which is not in the canonical chain.
+	TxStatusSideChain = "sidechain"
+)
+
+// TransactionInfo is a transaction with its status and, unless it is in the
+// pool, the block including it. Confirmations is the number of canonical
+// blocks from this block to the head, this block included.
+type TransactionInfo struct {
+	*TransferObj
+	From          string      `json:"from"`
+	Status        string      `json:"status"`
+	BlockHash     common.Hash `json:"block_hash"`
+	BlockHeight   uint64      `json:"block_height"`
+	Index         uint64      `json:"index"`
+	Confirmations uint64      `json:"confirmations"`
+}
+
 type GetBlocks []*GetBlockByNumberBlock
 type transactions []*xfsgo.Transaction
+
+// ChainStats are the statistics of the chain, the times are in seconds.
+type ChainStats struct {
+	Height         uint64   `json:"height"`
+	Window         uint64   `json:"window"`
+	AvgBlockTime   float64  `json:"avg_block_time"`
+	Bits           uint32   `json:"bits"`
+	Target         *big.Int `json:"target"`
+	Difficulty     *big.Int `json:"difficulty"`
+	HashRate       *big.Int `json:"hash_rate"`
+	TotalSupply    *big.Int `json:"total_supply"`
+	Transactions   uint64   `json:"transactions"`
+	AvgTxsPerBlock float64  `json:"avg_txs_per_block"`
+}
+
+// SyncProgress is the progress of the sync of the chain with the peers.
+type SyncProgress struct {
+	Syncing       bool   `json:"syncing"`
+	StartHeight   uint64 `json:"start_height"`
+	CurrentHeight uint64 `json:"current_height"`
+	HighestHeight uint64 `json:"highest_height"`
+}
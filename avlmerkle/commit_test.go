@@ -0,0 +1,138 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package avlmerkle
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"xfsgo/common/rawencode"
+	"xfsgo/storage"
+	"xfsgo/storage/badger"
+	"xfsgo/storage/memdb"
+)
+
+var errTestCrash = errors.New("crash")
+
+func putTestKeys(tr *Tree, from, to int) {
+	for i := from; i < to; i++ {
+		tr.Put([]byte(fmt.Sprintf("k%03d", i)), []byte(fmt.Sprintf("v%d", i)))
+	}
+}
+
+func checkTestKeys(t *testing.T, tr *Tree, to int) {
+	for i := 0; i < to; i++ {
+		got, has := tr.Get([]byte(fmt.Sprintf("k%03d", i)))
+		if !has || string(got) != fmt.Sprintf("v%d", i) {
+			t.Fatalf("key k%03d got %q %v", i, got, has)
+		}
+	}
+}
+
+// checkStoredNodes verifies that the children of every stored node are stored.
+func checkStoredNodes(t *testing.T, db storage.Database) int {
+	count := 0
+	err := db.PrefixForeachData(treeNodePre, func(k []byte, v []byte) error {
+		node := &TreeNode{}
+		if err := rawencode.Decode(v, node); err != nil {
+			return err
+		}
+		count++
+		if node.isLeaf() {
+			return nil
+		}
+		for _, child := range [][]byte{node.left, node.right} {
+			if _, err := db.GetData(treeNodeKey(child)); err != nil {
+				return fmt.Errorf("node %x misses child %x: %v", node.id, child, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestTree_CommitIncremental(t *testing.T) {
+	db := memdb.New()
+	tr := NewTree(db, nil)
+	putTestKeys(tr, 0, 100)
+	if err := tr.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if nodes := tr.root.collectUnpersisted(nil); len(nodes) != 0 {
+		t.Fatalf("got %d unpersisted nodes after commit", len(nodes))
+	}
+	stored := checkStoredNodes(t, db)
+	reopened := NewTree(db, tr.Checksum())
+	checkTestKeys(t, reopened, 100)
+	// only the path to the changed key is written again
+	reopened.Put([]byte("k050"), []byte("v50"))
+	reopened.Put([]byte("k050"), []byte("changed"))
+	if nodes := reopened.root.collectUnpersisted(nil); len(nodes) > reopened.root.depth+1 {
+		t.Fatalf("got %d nodes to write, want at most %d", len(nodes), reopened.root.depth+1)
+	}
+	if err := reopened.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := checkStoredNodes(t, db); got-stored > reopened.root.depth+1 {
+		t.Fatalf("got %d new nodes, want at most %d", got-stored, reopened.root.depth+1)
+	}
+}
+
+func TestTree_CommitCrash(t *testing.T) {
+	dir := t.TempDir()
+	db := badger.New(dir)
+	tr := NewTree(db, nil)
+	putTestKeys(tr, 0, 50)
+	if err := tr.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	oldRoot := tr.Checksum()
+	putTestKeys(tr, 50, 100)
+	newRoot := tr.Checksum()
+	// the process dies once the first part of the commit is stored
+	tr.db.maxTxnNodes = 4
+	tr.db.afterTxn = func() error {
+		return errTestCrash
+	}
+	if err := tr.Commit(); err != errTestCrash {
+		t.Fatalf("got err %v, want %v", err, errTestCrash)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db = badger.New(dir)
+	defer func() {
+		_ = db.Close()
+	}()
+	checkStoredNodes(t, db)
+	if _, err := db.GetData(treeNodeKey(newRoot)); err == nil {
+		t.Fatalf("root of the interrupted commit is stored")
+	}
+	recovered := NewTree(db, oldRoot)
+	checkTestKeys(t, recovered, 50)
+	// the block is applied again on the last committed root
+	putTestKeys(recovered, 50, 100)
+	if err := recovered.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	checkStoredNodes(t, db)
+	checkTestKeys(t, NewTree(db, newRoot), 100)
+}
@@ -0,0 +1,158 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package avlmerkle
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// checkTree verifies the depths, routing keys and balance of every node.
+func checkTree(t *testing.T, tr *Tree, n *TreeNode) {
+	if n == nil || n.isLeaf() {
+		return
+	}
+	left := tr.mustLoadLeft(n)
+	right := tr.mustLoadRight(n)
+	checkTree(t, tr, left)
+	checkTree(t, tr, right)
+	if bf := left.depth - right.depth; bf > 1 || bf < -1 {
+		t.Fatalf("node %s unbalanced: %d", n.key, bf)
+	}
+	depth := left.depth
+	if right.depth > depth {
+		depth = right.depth
+	}
+	if n.depth != depth+1 {
+		t.Fatalf("node %s got depth %d, want %d", n.key, n.depth, depth+1)
+	}
+	if bytes.Compare(left.key, right.key) >= 0 || !bytes.Equal(n.key, right.key) {
+		t.Fatalf("node %s keys out of order: left %s right %s", n.key, left.key, right.key)
+	}
+}
+
+func TestTree_Remove(t *testing.T) {
+	tr := NewTree(nil, nil)
+	model := make(map[string]string)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		k := fmt.Sprintf("k%03d", r.Intn(300))
+		if r.Intn(3) == 0 {
+			value, removed := tr.Remove([]byte(k))
+			want, has := model[k]
+			if removed != has || string(value) != want {
+				t.Fatalf("remove %s got %q %v, want %q %v", k, value, removed, want, has)
+			}
+			delete(model, k)
+		} else {
+			v := fmt.Sprintf("v%d", i)
+			tr.Put([]byte(k), []byte(v))
+			model[k] = v
+		}
+		checkTree(t, tr, tr.root)
+	}
+	if tr.Size() != len(model) {
+		t.Fatalf("got size %d, want %d", tr.Size(), len(model))
+	}
+	for k, v := range model {
+		got, has := tr.Get([]byte(k))
+		if !has || string(got) != v {
+			t.Fatalf("get %s got %q %v, want %q", k, got, has, v)
+		}
+	}
+	for k := range model {
+		if _, removed := tr.Remove([]byte(k)); !removed {
+			t.Fatalf("remove %s failed", k)
+		}
+		checkTree(t, tr, tr.root)
+	}
+	if tr.Checksum() != nil || tr.Size() != 0 {
+		t.Fatalf("tree not empty after removing every key")
+	}
+}
+
+func TestTree_RemoveRehash(t *testing.T) {
+	tr := newProofTestTree(10)
+	before := tr.ChecksumHex()
+	tr.Put([]byte("extra"), []byte("x"))
+	if _, removed := tr.Remove([]byte("extra")); !removed {
+		t.Fatalf("remove extra failed")
+	}
+	if _, removed := tr.Remove([]byte("extra")); removed {
+		t.Fatalf("remove of absent key should fail")
+	}
+	// the removed key is not provable any more
+	proof, err := tr.Prove([]byte("extra"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyProof(tr.Checksum(), []byte("extra"), nil, proof); err != nil {
+		t.Fatal(err)
+	}
+	if tr.ChecksumHex() == "" || tr.Size() != 10 {
+		t.Fatalf("got size %d, root %s (before %s)", tr.Size(), tr.ChecksumHex(), before)
+	}
+}
+
+func TestTree_Iterate(t *testing.T) {
+	tr := NewTree(nil, nil)
+	keys := make([]string, 0)
+	for _, i := range rand.New(rand.NewSource(2)).Perm(40) {
+		k := fmt.Sprintf("k%02d", i)
+		tr.Put([]byte(k), []byte("v"+k))
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	collect := func(start, end []byte, ascending bool) string {
+		got := make([]string, 0)
+		tr.Iterate(start, end, ascending, func(key []byte, value []byte) bool {
+			got = append(got, string(key))
+			return false
+		})
+		return strings.Join(got, ",")
+	}
+	if got, want := collect(nil, nil, true), strings.Join(keys, ","); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := collect([]byte("k10"), []byte("k15"), true), "k10,k11,k12,k13,k14"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := collect([]byte("k10"), []byte("k15"), false), "k14,k13,k12,k11,k10"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := collect([]byte("k375"), nil, true), "k38,k39"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := collect(nil, []byte("k02"), false), "k01,k00"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	count := 0
+	stopped := tr.Iterate(nil, nil, true, func(key []byte, value []byte) bool {
+		count++
+		return count == 5
+	})
+	if !stopped || count != 5 {
+		t.Fatalf("got stopped %v after %d keys, want stop after 5", stopped, count)
+	}
+	if tr.Iterate(nil, nil, true, func(key []byte, value []byte) bool { return false }) {
+		t.Fatalf("complete iteration should not report stopped")
+	}
+}
@@ -1,7 +0,0 @@
-package avlmerkle
-
-import "testing"
-
-func TestFileExist(t *testing.T) {
-
-}
@@ -31,6 +31,7 @@ type TreeNode struct {
 	left, right         []byte
 	id, key, value      []byte
 	depth               int
+	persisted           bool // stored in the database under its id
 }
 
 func newLeafNode(k, v []byte) *TreeNode {
@@ -179,6 +180,47 @@ func (n *TreeNode) insert(t *Tree, k, v []byte) *TreeNode {
 	}).rebalance(t)
 }
 
+// remove deletes the key k from the subtree of n and returns the new subtree
+// root, nil when it is empty, and the removed value.
+func (n *TreeNode) remove(t *Tree, k []byte) (*TreeNode, []byte, bool) {
+	if n.isLeaf() {
+		if bytes.Compare(k, n.key) == common.Zero {
+			return nil, n.value, true
+		}
+		return n, nil, false
+	}
+	leftNode := t.mustLoadLeft(n)
+	rightNode := t.mustLoadRight(n)
+
+	if bytes.Compare(k, leftNode.key) <= common.Zero {
+		newLeft, value, removed := leftNode.remove(t, k)
+		if !removed {
+			return n, nil, false
+		}
+		// the sibling takes the place of the parent
+		if newLeft == nil {
+			return rightNode, value, true
+		}
+		return n.update(func(node *TreeNode) {
+			node.left = newLeft.id
+			node.leftNode = newLeft
+			node.sync(t, newLeft, rightNode)
+		}).rebalance(t), value, true
+	}
+	newRight, value, removed := rightNode.remove(t, k)
+	if !removed {
+		return n, nil, false
+	}
+	if newRight == nil {
+		return leftNode, value, true
+	}
+	return n.update(func(node *TreeNode) {
+		node.right = newRight.id
+		node.rightNode = newRight
+		node.sync(t, leftNode, newRight)
+	}).rebalance(t), value, true
+}
+
 func (n *TreeNode) lookup(t *Tree, k []byte) ([]byte, bool) {
 	// Judge whether the current node is a leaf node
 	if n.isLeaf() {
@@ -304,11 +346,24 @@ func (n *TreeNode) clone() *TreeNode {
 
 func (n *TreeNode) update(fn func(node *TreeNode)) *TreeNode {
 	cpy := n.clone()
+	cpy.persisted = false
 	fn(cpy)
 	cpy.rehash()
 	return cpy
 }
 
+// collectUnpersisted appends the nodes of the subtree that are not stored yet,
+// children before their parent. Nodes which are not loaded are stored,
+// as well as all the nodes below a stored one.
+func (n *TreeNode) collectUnpersisted(nodes []*TreeNode) []*TreeNode {
+	if n == nil || n.persisted {
+		return nodes
+	}
+	nodes = n.leftNode.collectUnpersisted(nodes)
+	nodes = n.rightNode.collectUnpersisted(nodes)
+	return append(nodes, n)
+}
+
 func (n *TreeNode) dfsCall(t *Tree, fn func(node *TreeNode) error) error {
 	if err := fn(n); err != nil {
 		return err
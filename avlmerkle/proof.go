@@ -0,0 +1,205 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package avlmerkle
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"xfsgo/common"
+)
+
+var (
+	ErrInvalidProof = errors.New("invalid merkle proof")
+	ErrKeyNotProven = errors.New("merkle proof does not prove the key value")
+)
+
+// ProofNode holds the fields of a tree node that its id is computed from.
+// On the path of a proof, the child id leading to the leaf is left empty,
+// the verifier computes it.
+type ProofNode struct {
+	Depth int    `json:"depth"`
+	Key   []byte `json:"key"`
+	Left  []byte `json:"left,omitempty"`
+	Right []byte `json:"right,omitempty"`
+	Value []byte `json:"value,omitempty"`
+}
+
+// ProofStep is an inner node on the path from the root to the leaf.
+type ProofStep struct {
+	ProofNode
+	// LeftSibling is set when the path goes to the right child,
+	// its key proves the lookup of the key had to go right.
+	LeftSibling *ProofNode `json:"left_sibling,omitempty"`
+}
+
+// Proof proves that a key is or is not in a tree with a given root.
+// The path leads from the root to the leaf where the lookup of the key ends,
+// the key is in the tree if and only if it is the key of that leaf.
+type Proof struct {
+	Path []ProofStep `json:"path"`
+	Leaf *ProofNode  `json:"leaf,omitempty"`
+}
+
+func newProofNode(n *TreeNode) *ProofNode {
+	pn := &ProofNode{
+		Depth: n.depth,
+		Key:   n.key,
+	}
+	if n.isLeaf() {
+		pn.Value = n.value
+	} else {
+		pn.Left = n.left
+		pn.Right = n.right
+	}
+	return pn
+}
+
+// hash computes the node id, left and right replace the children ids when not nil.
+func (pn *ProofNode) hash(left, right []byte) ([]byte, error) {
+	if pn.Depth < 0 || pn.Depth > math.MaxUint8 {
+		return nil, ErrInvalidProof
+	}
+	n := &TreeNode{
+		depth: pn.Depth,
+		key:   pn.Key,
+		value: pn.Value,
+		left:  pn.Left,
+		right: pn.Right,
+	}
+	if left != nil {
+		n.left = left
+	}
+	if right != nil {
+		n.right = right
+	}
+	if !n.isLeaf() && (len(n.left) != 32 || len(n.right) != 32) {
+		return nil, ErrInvalidProof
+	}
+	n.rehash()
+	return n.id, nil
+}
+
+// Prove returns a proof of the presence or absence of key k in the tree.
+// Only committed and loaded nodes are used, so the proof is against Checksum.
+func (t *Tree) Prove(k []byte) (*Proof, error) {
+	proof := &Proof{
+		Path: make([]ProofStep, 0),
+	}
+	n := t.root
+	if n == nil {
+		return proof, nil
+	}
+	for !n.isLeaf() {
+		left, err := t.loadLeft(n)
+		if err != nil {
+			return nil, err
+		}
+		step := ProofStep{
+			ProofNode: *newProofNode(n),
+		}
+		if bytes.Compare(k, left.key) <= common.Zero {
+			step.Left = nil
+			n = left
+		} else {
+			right, err := t.loadRight(n)
+			if err != nil {
+				return nil, err
+			}
+			step.Right = nil
+			step.LeftSibling = newProofNode(left)
+			n = right
+		}
+		proof.Path = append(proof.Path, step)
+	}
+	proof.Leaf = newProofNode(n)
+	return proof, nil
+}
+
+// VerifyProof checks the proof against the tree root. A nil value checks that
+// key is absent from the tree, any other value that key is bound to it.
+func VerifyProof(root []byte, key []byte, value []byte, proof *Proof) error {
+	if proof == nil {
+		return ErrInvalidProof
+	}
+	var zero [32]byte
+	if len(root) == 0 || bytes.Equal(root, zero[:]) {
+		if len(proof.Path) != 0 || proof.Leaf != nil {
+			return ErrInvalidProof
+		}
+		if value != nil {
+			return ErrKeyNotProven
+		}
+		return nil
+	}
+	leaf := proof.Leaf
+	if leaf == nil || leaf.Depth != 0 {
+		return ErrInvalidProof
+	}
+	id, err := leaf.hash(nil, nil)
+	if err != nil {
+		return err
+	}
+	childKey := leaf.Key
+	for i := len(proof.Path) - 1; i >= 0; i-- {
+		step := &proof.Path[i]
+		if step.Depth <= 0 {
+			return ErrInvalidProof
+		}
+		switch {
+		case len(step.Left) == 0 && len(step.Right) != 0:
+			// the lookup goes left for keys up to the largest key of the left child
+			if bytes.Compare(key, childKey) > common.Zero {
+				return ErrInvalidProof
+			}
+			id, err = step.hash(id, nil)
+		case len(step.Right) == 0 && len(step.Left) != 0 && step.LeftSibling != nil:
+			var siblingId []byte
+			siblingId, err = step.LeftSibling.hash(nil, nil)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(siblingId, step.Left) {
+				return ErrInvalidProof
+			}
+			if bytes.Compare(key, step.LeftSibling.Key) <= common.Zero {
+				return ErrInvalidProof
+			}
+			id, err = step.hash(nil, id)
+		default:
+			return ErrInvalidProof
+		}
+		if err != nil {
+			return err
+		}
+		childKey = step.Key
+	}
+	if !bytes.Equal(id, root) {
+		return ErrInvalidProof
+	}
+	found := bytes.Equal(leaf.Key, key)
+	if value == nil {
+		if found {
+			return ErrKeyNotProven
+		}
+		return nil
+	}
+	if !found || !bytes.Equal(leaf.Value, value) {
+		return ErrKeyNotProven
+	}
+	return nil
+}
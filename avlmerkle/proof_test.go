@@ -0,0 +1,119 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package avlmerkle
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func newProofTestTree(n int) *Tree {
+	tr := NewTree(nil, nil)
+	for i := 0; i < n; i++ {
+		tr.Put([]byte(fmt.Sprintf("k%03d", i*2)), []byte(fmt.Sprintf("v%d", i)))
+	}
+	return tr
+}
+
+func TestTree_ProveInclusion(t *testing.T) {
+	tr := newProofTestTree(50)
+	root := tr.Checksum()
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("k%03d", i*2))
+		value := []byte(fmt.Sprintf("v%d", i))
+		proof, err := tr.Prove(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = VerifyProof(root, key, value, proof); err != nil {
+			t.Fatalf("verify key %s: %v", key, err)
+		}
+		if err = VerifyProof(root, key, []byte("other"), proof); err != ErrKeyNotProven {
+			t.Fatalf("verify key %s with wrong value got err %v, want %v", key, err, ErrKeyNotProven)
+		}
+		if err = VerifyProof(root, key, nil, proof); err != ErrKeyNotProven {
+			t.Fatalf("verify absence of key %s got err %v, want %v", key, err, ErrKeyNotProven)
+		}
+	}
+}
+
+func TestTree_ProveAbsence(t *testing.T) {
+	tr := newProofTestTree(50)
+	root := tr.Checksum()
+	for _, key := range []string{"a", "k001", "k051", "k097", "k999", "z"} {
+		proof, err := tr.Prove([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = VerifyProof(root, []byte(key), nil, proof); err != nil {
+			t.Fatalf("verify absence of key %s: %v", key, err)
+		}
+	}
+	// a proof of absence of one key does not prove the absence of another
+	proof, _ := tr.Prove([]byte("k001"))
+	if err := VerifyProof(root, []byte("k050"), nil, proof); err == nil {
+		t.Fatalf("proof of k001 should not prove the absence of k050")
+	}
+}
+
+func TestVerifyProof_Tampered(t *testing.T) {
+	tr := newProofTestTree(20)
+	root := tr.Checksum()
+	key := []byte("k010")
+	proof, err := tr.Prove(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// survives a JSON round trip
+	data, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &Proof{}
+	if err = json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyProof(root, key, []byte("v5"), decoded); err != nil {
+		t.Fatal(err)
+	}
+	decoded.Leaf.Value = []byte("v6")
+	if err = VerifyProof(root, key, []byte("v6"), decoded); err != ErrInvalidProof {
+		t.Fatalf("got err %v, want %v", err, ErrInvalidProof)
+	}
+	other := newProofTestTree(21).Checksum()
+	if err = VerifyProof(other, key, []byte("v5"), proof); err != ErrInvalidProof {
+		t.Fatalf("got err %v, want %v", err, ErrInvalidProof)
+	}
+	if err = VerifyProof(root, key, []byte("v5"), &Proof{}); err != ErrInvalidProof {
+		t.Fatalf("got err %v, want %v", err, ErrInvalidProof)
+	}
+}
+
+func TestTree_ProveEmpty(t *testing.T) {
+	tr := NewTree(nil, nil)
+	proof, err := tr.Prove([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyProof(tr.Checksum(), []byte("a"), nil, proof); err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyProof(tr.Checksum(), []byte("a"), []byte("b"), proof); err != ErrKeyNotProven {
+		t.Fatalf("got err %v, want %v", err, ErrKeyNotProven)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package avlmerkle
+
+import (
+	"bytes"
+	"sync"
+	"xfsgo/storage"
+)
+
+type nodeSet map[[32]byte]struct{}
+
+func (s nodeSet) add(id []byte) {
+	var k [32]byte
+	copy(k[:], id)
+	s[k] = struct{}{}
+}
+
+func (s nodeSet) has(id []byte) bool {
+	var k [32]byte
+	copy(k[:], id)
+	_, ok := s[k]
+	return ok
+}
+
+// pruneGuard records the nodes written to a database while it is pruned,
+// so that nodes committed meanwhile are never swept.
+type pruneGuard struct {
+	// writes are held shared by the commits and exclusively by the sweep.
+	writes  sync.RWMutex
+	mu      sync.Mutex
+	pruning sync.Mutex
+	written nodeSet
+}
+
+// afterPruneScan is called between the scan and the sweep of Prune, for tests.
+var afterPruneScan func()
+
+// pruneGuards maps each storage.Database to its *pruneGuard.
+var pruneGuards sync.Map
+
+func getPruneGuard(db storage.Database) *pruneGuard {
+	g, _ := pruneGuards.LoadOrStore(db, &pruneGuard{})
+	return g.(*pruneGuard)
+}
+
+// markWritten is called before nodes are written.
+func (g *pruneGuard) markWritten(nodes []*TreeNode) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.written == nil {
+		return
+	}
+	for _, node := range nodes {
+		g.written.add(node.id)
+	}
+}
+
+func (g *pruneGuard) isWritten(id []byte) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.written.has(id)
+}
+
+// markReachable adds the nodes below id to live, skipping the subtrees
+// already marked from another root.
+func markReachable(db *treeDb, id []byte, live nodeSet) error {
+	var zero [32]byte
+	if bytes.Equal(id, zero[:]) || live.has(id) {
+		return nil
+	}
+	node, err := db.getTreeNodeByKey(treeNodeKey(id))
+	if err != nil {
+		return err
+	}
+	live.add(id)
+	if node.isLeaf() {
+		return nil
+	}
+	if err = markReachable(db, node.left, live); err != nil {
+		return err
+	}
+	return markReachable(db, node.right, live)
+}
+
+// Prune deletes the tree nodes of db which are not reachable from any of roots
+// and returns how many were deleted. Trees can be committed to db meanwhile,
+// but they must be based on one of roots: the nodes of other roots may be gone.
+func Prune(db storage.Database, roots [][]byte) (int, error) {
+	guard := getPruneGuard(db)
+	guard.pruning.Lock()
+	defer guard.pruning.Unlock()
+	guard.mu.Lock()
+	guard.written = make(nodeSet)
+	guard.mu.Unlock()
+	defer func() {
+		guard.mu.Lock()
+		guard.written = nil
+		guard.mu.Unlock()
+	}()
+
+	tdb := newTreeDb(db)
+	live := make(nodeSet)
+	for _, root := range roots {
+		if len(root) == 0 {
+			continue
+		}
+		if err := markReachable(tdb, root, live); err != nil {
+			return 0, err
+		}
+	}
+	dead := make([][]byte, 0)
+	if err := db.PrefixForeachData(treeNodePre, func(k []byte, v []byte) error {
+		id := k[len(treeNodePre):]
+		if !live.has(id) {
+			dead = append(dead, append([]byte{}, k...))
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	if afterPruneScan != nil {
+		afterPruneScan()
+	}
+	guard.writes.Lock()
+	defer guard.writes.Unlock()
+	deleted := 0
+	batch := db.NewWriteBatch()
+	for _, key := range dead {
+		if guard.isWritten(key[len(treeNodePre):]) {
+			continue
+		}
+		if err := batch.Delete(key); err != nil {
+			batch.Destroy()
+			return 0, err
+		}
+		deleted++
+	}
+	if err := db.CommitWriteBatch(batch); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package avlmerkle
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"xfsgo/storage/memdb"
+)
+
+func TestPrune(t *testing.T) {
+	db := memdb.New()
+	tr := NewTree(db, nil)
+	roots := make([][]byte, 0)
+	for i := 0; i < 5; i++ {
+		putTestKeys(tr, i*20, (i+1)*20)
+		tr.Put([]byte("k000"), []byte(fmt.Sprintf("v0-%d", i)))
+		if err := tr.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, tr.Checksum())
+	}
+	before := checkStoredNodes(t, db)
+	kept := roots[3:]
+	deleted, err := Prune(db, kept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted == 0 {
+		t.Fatalf("no node deleted")
+	}
+	if got := checkStoredNodes(t, db); got != before-deleted {
+		t.Fatalf("got %d stored nodes, want %d", got, before-deleted)
+	}
+	for _, root := range roots[:3] {
+		if _, err = db.GetData(treeNodeKey(root)); err == nil {
+			t.Fatalf("pruned root %x is stored", root)
+		}
+	}
+	for i, root := range kept {
+		reopened := NewTree(db, root)
+		if got, _ := reopened.Get([]byte("k000")); string(got) != fmt.Sprintf("v0-%d", i+3) {
+			t.Fatalf("root %d got k000 %q", i+3, got)
+		}
+		for j := 1; j < (i+4)*20; j++ {
+			key := []byte(fmt.Sprintf("k%03d", j))
+			if got, has := reopened.Get(key); !has || string(got) != fmt.Sprintf("v%d", j) {
+				t.Fatalf("root %d got key %s %q %v", i+3, key, got, has)
+			}
+		}
+	}
+	// nothing else to delete
+	if deleted, err = Prune(db, kept); err != nil || deleted != 0 {
+		t.Fatalf("got %d deleted, err %v", deleted, err)
+	}
+}
+
+func TestPrune_ConcurrentCommit(t *testing.T) {
+	db := memdb.New()
+	tr := NewTree(db, nil)
+	putTestKeys(tr, 0, 50)
+	if err := tr.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	base := tr.Checksum()
+	// the nodes of an unreachable root are written again after the scan
+	putTestKeys(tr, 50, 100)
+	if err := tr.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	var newRoot []byte
+	afterPruneScan = func() {
+		next := NewTree(db, base)
+		putTestKeys(next, 50, 100)
+		if err := next.Commit(); err != nil {
+			t.Error(err)
+		}
+		newRoot = next.Checksum()
+	}
+	defer func() {
+		afterPruneScan = nil
+	}()
+	if _, err := Prune(db, [][]byte{base}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(newRoot, tr.Checksum()) {
+		t.Fatalf("got root %x, want %x", newRoot, tr.Checksum())
+	}
+	checkStoredNodes(t, db)
+	checkTestKeys(t, NewTree(db, newRoot), 100)
+}
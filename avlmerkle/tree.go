@@ -19,13 +19,11 @@ package avlmerkle
 import (
 	"bytes"
 	"encoding/hex"
-	"errors"
 	"github.com/sirupsen/logrus"
-	"strings"
 	"xfsgo/common"
 	"xfsgo/common/rawencode"
 	"xfsgo/lru"
-	"xfsgo/storage/badger"
+	"xfsgo/storage"
 )
 
 type Tree struct {
@@ -37,7 +35,7 @@ type Tree struct {
 // NewTree creates a trie with an existing db and a root node.
 // If the root exists and its format is correct, you need load the root node from the db
 // and store the datas in a cache.
-func NewTree(db *badger.Storage, root []byte) *Tree {
+func NewTree(db storage.Database, root []byte) *Tree {
 	t := &Tree{
 		db: newTreeDb(db),
 	}
@@ -46,12 +44,25 @@ func NewTree(db *badger.Storage, root []byte) *Tree {
 	if root != nil && len(root) == int(32) && bytes.Compare(root, zero[:]) > common.Zero {
 		t.root = t.mustLoadNode(root)
 	}
+	return t
+}
 
-	if err := FileExist(); err == nil {
-		t.BackCommit()
+// OpenTree is like NewTree but returns the error of loading the root node,
+// such as when it is not stored, instead of panicking.
+func OpenTree(db storage.Database, root []byte) (*Tree, error) {
+	t := &Tree{
+		db: newTreeDb(db),
 	}
-
-	return t
+	t.cache = lru.NewCache(2048)
+	var zero [32]byte
+	if len(root) == 32 && bytes.Compare(root, zero[:]) > common.Zero {
+		n, err := t.loadNode(root)
+		if err != nil {
+			return nil, err
+		}
+		t.root = n
+	}
+	return t, nil
 }
 
 func (t *Tree) Put(k, v []byte) {
@@ -62,6 +73,19 @@ func (t *Tree) Put(k, v []byte) {
 	t.root = t.root.insert(t, k, v)
 }
 
+// Remove deletes the key k from the tree and returns its former value,
+// the tree is rebalanced and rehashed along the path to the key.
+func (t *Tree) Remove(k []byte) ([]byte, bool) {
+	if t.root == nil {
+		return nil, false
+	}
+	root, value, removed := t.root.remove(t, k)
+	if removed {
+		t.root = root
+	}
+	return value, removed
+}
+
 func (t *Tree) Checksum() []byte {
 	if t.root == nil {
 		return nil
@@ -144,12 +168,14 @@ func (t *Tree) loadNode(id []byte) (*TreeNode, error) {
 		if err := rawencode.Decode(data, tn); err != nil {
 			return nil, err
 		}
+		tn.persisted = true
 		return tn, nil
 	}
-	tn, err := t.db.getTreeNodeByKey(append([]byte("tree:"), id...))
+	tn, err := t.db.getTreeNodeByKey(treeNodeKey(id))
 	if err != nil {
 		return nil, err
 	}
+	tn.persisted = true
 	// push cache
 	buf, err := rawencode.Encode(tn)
 	if err != nil {
@@ -187,79 +213,70 @@ func (t *Tree) foreach(n *TreeNode, fn func(key []byte, value []byte)) {
 	t.foreach(t.mustLoadRight(n), fn)
 }
 
-func (t *Tree) BackCommit() error {
+// Iterate calls fn for the keys in the range [start, end) in ascending
+// or descending order, a nil start or end leaves the range open on that side.
+// The iteration stops when fn returns true, Iterate then returns true.
+func (t *Tree) Iterate(start, end []byte, ascending bool, fn func(key []byte, value []byte) bool) bool {
 	if t.root == nil {
-		return nil
-	}
-	journalObj, err := NewJournal()
-	if err != nil {
-		return err
-	}
-	scanner, err := journalObj.Replay()
-	if err != nil {
-		return err
+		return false
 	}
-	root := common.Encode16Byte(t.Checksum())
-	batch := t.db.newWriteBatch()
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, root) {
-			result := journalObj.StrToMap(line, root)
-			if result != nil {
-				rootJournal := result["root"].(string)
-				if rootJournal == root {
-					keys := common.Decode16Byte(result["key"].(string))
-					bs := common.Decode16Byte(result["bs"].(string))
-					batch.Put(keys, bs)
-				}
-			} else {
-				continue
-			}
+	return t.iterate(t.root, start, end, ascending, fn)
+}
 
+func (t *Tree) iterate(n *TreeNode, start, end []byte, ascending bool, fn func(key []byte, value []byte) bool) bool {
+	if n.isLeaf() {
+		if start != nil && bytes.Compare(n.key, start) < common.Zero {
+			return false
 		}
-
+		if end != nil && bytes.Compare(n.key, end) >= common.Zero {
+			return false
+		}
+		return fn(n.key, n.value)
+	}
+	left := t.mustLoadLeft(n)
+	// the keys of the left subtree are at most left.key, those of the right one above it
+	visitLeft := start == nil || bytes.Compare(left.key, start) >= common.Zero
+	visitRight := end == nil || bytes.Compare(left.key, end) < common.Zero
+	if ascending {
+		if visitLeft && t.iterate(left, start, end, ascending, fn) {
+			return true
+		}
+		return visitRight && t.iterate(t.mustLoadRight(n), start, end, ascending, fn)
 	}
-	if err = t.db.commitBatch(batch); err != nil {
-		return err
+	if visitRight && t.iterate(t.mustLoadRight(n), start, end, ascending, fn) {
+		return true
 	}
-	return nil
+	return visitLeft && t.iterate(left, start, end, ascending, fn)
+}
+
+// Size returns the number of keys in the tree.
+// Nodes do not store the size of their subtree, so it visits every leaf.
+func (t *Tree) Size() int {
+	size := 0
+	t.Foreach(func(key []byte, value []byte) {
+		size++
+	})
+	return size
 }
 
+// Commit writes the nodes changed since the last commit to the database.
+// The nodes are written children first in a single transaction, split only
+// when it gets too large for badger, so the root is never stored before
+// every node below it: a crash during the commit leaves the previous roots
+// intact and the new one unreadable, never half written.
 func (t *Tree) Commit() error {
 	if t.root == nil {
 		return nil
 	}
-	journalObj, err := NewJournal()
-	if err != nil {
-		return err
-	}
-	batch := t.db.newWriteBatch()
-	err = t.root.dfsCall(t, func(node *TreeNode) error {
-		root := t.Checksum()
-		_ = root
-		key := append([]byte("tree:"), node.id...)
-
-		bs, err := rawencode.Encode(node)
-		if err != nil {
-			return err
-		}
-		// return batch.Put(append([]byte("tree:"), node.id...), bs)
-		if len(root) == 0 || len(key) == 0 || len(bs) == 0 {
-			return errors.New("root or key or bs not null")
-		}
-		writeJournal := "root: " + common.Encode16Byte(root) + " key: " + common.Encode16Byte(key) + " bs: " + common.Encode16Byte(bs)
-		journalObj.JouWrite(writeJournal)
-		return batch.Put(key, bs)
-	})
-	if err != nil {
-		return err
+	nodes := t.root.collectUnpersisted(nil)
+	if len(nodes) == 0 {
+		return nil
 	}
-	if err = t.db.commitBatch(batch); err != nil {
+	if err := t.db.writeNodes(nodes); err != nil {
 		return err
 	}
-	// delete journal Log
-	if err = journalObj.DelWellDate(); err != nil {
-		return err
+	for _, node := range nodes {
+		node.persisted = true
 	}
 	return nil
 }
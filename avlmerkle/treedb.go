@@ -19,28 +19,42 @@ package avlmerkle
 import (
 	"sync"
 	"xfsgo/common/rawencode"
-	"xfsgo/storage/badger"
+	"xfsgo/storage"
 )
 
+var treeNodePre = []byte("tree:")
+
+func treeNodeKey(id []byte) []byte {
+	return append(append([]byte{}, treeNodePre...), id...)
+}
+
 //treeDb stores the tree to the db.
 type treeDb struct {
-	storage   *badger.Storage
+	storage   storage.Database
 	writeLock sync.Mutex
+	guard     *pruneGuard
+	// maxTxnNodes splits the writes of a commit every maxTxnNodes nodes
+	// when it is not zero, and afterTxn is called after each part, for tests.
+	maxTxnNodes int
+	afterTxn    func() error
 }
 
-func newTreeDb(db *badger.Storage) *treeDb {
+func newTreeDb(db storage.Database) *treeDb {
 	tdb := &treeDb{
 		storage: db,
 	}
+	if db != nil {
+		tdb.guard = getPruneGuard(db)
+	}
 	return tdb
 }
 
-func (db *treeDb) newWriteBatch() *badger.StorageWriteBatch {
+func (db *treeDb) newWriteBatch() storage.WriteBatch {
 	return db.storage.NewWriteBatch()
 }
 
 // commitBatch creates StorageWriteBatch to flush persistent data out
-func (db *treeDb) commitBatch(batch *badger.StorageWriteBatch) error {
+func (db *treeDb) commitBatch(batch storage.WriteBatch) error {
 	return db.storage.CommitWriteBatch(batch)
 }
 
@@ -55,3 +69,55 @@ func (db *treeDb) getTreeNodeByKey(key []byte) (*TreeNode, error) {
 	}
 	return node, nil
 }
+
+// writeNodes stores nodes in their order within one transaction, which is
+// committed early and continued in a new one only when badger can't hold more.
+func (db *treeDb) writeNodes(nodes []*TreeNode) error {
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+	db.guard.writes.RLock()
+	defer db.guard.writes.RUnlock()
+	db.guard.markWritten(nodes)
+	txn := db.storage.NewTxn()
+	defer func() {
+		txn.Discard()
+	}()
+	flush := func() error {
+		if err := txn.Commit(); err != nil {
+			return err
+		}
+		if db.afterTxn != nil {
+			if err := db.afterTxn(); err != nil {
+				return err
+			}
+		}
+		txn = db.storage.NewTxn()
+		return nil
+	}
+	count := 0
+	for _, node := range nodes {
+		bs, err := rawencode.Encode(node)
+		if err != nil {
+			return err
+		}
+		if db.maxTxnNodes > 0 && count == db.maxTxnNodes {
+			if err = flush(); err != nil {
+				return err
+			}
+			count = 0
+		}
+		key := treeNodeKey(node.id)
+		if err = txn.Put(key, bs); err == storage.ErrTxnTooBig {
+			if err = flush(); err != nil {
+				return err
+			}
+			count = 0
+			err = txn.Put(key, bs)
+		}
+		if err != nil {
+			return err
+		}
+		count++
+	}
+	return txn.Commit()
+}
@@ -24,7 +24,7 @@ import (
 	"xfsgo/miner"
 	"xfsgo/node"
 	"xfsgo/p2p"
-	"xfsgo/storage/badger"
+	"xfsgo/storage"
 )
 
 // Backend represents the backend server of the xfs and implements the xfs full node service.
@@ -37,6 +37,7 @@ type Backend struct {
 	miner      *miner.Miner
 	eventBus   *xfsgo.EventBus
 	txPool     *xfsgo.TxPool
+	pruner     *xfsgo.StatePruner
 }
 
 type Params struct {
@@ -44,15 +45,42 @@ type Params struct {
 	GenesisFile     string
 	Coinbase        common.Address
 	ProtocolVersion uint32
+	// StateKeepBlocks is the number of recent blocks whose state is kept,
+	// older state is pruned unless StateArchive is set.
+	StateKeepBlocks uint64
+	StateArchive    bool
+	// AddressIndex indexes the transactions by sender and recipient.
+	AddressIndex bool
 }
 
 // Config contains the configuration options of the Backend.
 type Config struct {
 	*Params
-	ChainDB *badger.Storage
-	KeysDB  *badger.Storage
-	StateDB *badger.Storage
-	ExtraDB *badger.Storage
+	ChainDB storage.Database
+	KeysDB  storage.Database
+	StateDB storage.Database
+	ExtraDB storage.Database
+}
+
+// WriteGenesis writes the genesis block of the configured network
+// to the stores unless they already have one.
+func WriteGenesis(config *Config) error {
+	var err error
+	if config.NetworkID == uint32(1) {
+		_, err = xfsgo.WriteMainNetGenesisBlock(config.StateDB, config.ChainDB)
+	} else if config.NetworkID == uint32(2) {
+		_, err = xfsgo.WriteTestNetGenesisBlock(config.StateDB, config.ChainDB)
+	} else if len(config.GenesisFile) > 0 {
+		var fr *os.File
+		if fr, err = os.Open(config.GenesisFile); err != nil {
+			return err
+		}
+		defer func() {
+			_ = fr.Close()
+		}()
+		_, err = xfsgo.WriteGenesisBlock(config.StateDB, config.ChainDB, fr)
+	}
+	return err
 }
 
 // NewBackend constructs and returns a Backend instance by a note in network and config.
@@ -65,32 +93,34 @@ func NewBackend(stack *node.Node, config *Config) (*Backend, error) {
 		p2pServer: stack.P2PServer(),
 	}
 
+	if err = xfsgo.CheckSchema(&xfsgo.Stores{
+		ChainDB: config.ChainDB,
+		KeysDB:  config.KeysDB,
+		StateDB: config.StateDB,
+		ExtraDB: config.ExtraDB,
+	}); err != nil {
+		return nil, err
+	}
 	back.eventBus = xfsgo.NewEventBus()
-	if config.NetworkID == uint32(1) {
-		if _, err = xfsgo.WriteMainNetGenesisBlock(
-			back.config.StateDB, back.config.ChainDB); err != nil {
-			return nil, err
-		}
-	} else if config.NetworkID == uint32(2) {
-		if _, err = xfsgo.WriteTestNetGenesisBlock(
-			back.config.StateDB, back.config.ChainDB); err != nil {
-			return nil, err
-		}
-	} else if len(config.GenesisFile) > 0 {
-		var fr *os.File
-		if fr, err = os.Open(config.GenesisFile); err != nil {
-			return nil, err
-		}
-		if _, err = xfsgo.WriteGenesisBlock(
-			back.config.StateDB, back.config.ChainDB, fr); err != nil {
-			return nil, err
-		}
+	if err = WriteGenesis(config); err != nil {
+		return nil, err
 	}
 	if back.blockchain, err = xfsgo.NewBlockChain(
 		back.config.StateDB, back.config.ChainDB, back.config.ExtraDB, back.eventBus); err != nil {
 		return nil, err
 	}
 
+	if config.AddressIndex {
+		if err = back.blockchain.EnableAddressIndex(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !config.StateArchive {
+		back.pruner = xfsgo.NewStatePruner(back.config.StateDB,
+			back.config.ChainDB, back.eventBus, config.StateKeepBlocks)
+	}
+
 	back.wallet = xfsgo.NewWallet(back.config.KeysDB)
 	back.txPool = xfsgo.NewTxPool(back.blockchain.CurrentStateTree, back.eventBus)
 
@@ -112,7 +142,7 @@ func NewBackend(stack *node.Node, config *Config) (*Backend, error) {
 	}, back.config.StateDB, back.blockchain, back.eventBus, back.txPool)
 	//Node resgisters apis of baclend on the node  for RPC service.
 	if err = stack.RegisterBackend(
-		back.config.StateDB, back.blockchain, back.miner, back.wallet, back.txPool); err != nil {
+		back.config.StateDB, back.blockchain, back.miner, back.wallet, back.txPool, back.eventBus); err != nil {
 		return nil, err
 	}
 
@@ -121,15 +151,22 @@ func NewBackend(stack *node.Node, config *Config) (*Backend, error) {
 		return nil, err
 	}
 	back.p2pServer.Bind(&p2p.SimpleProtocol{
+		Name:      ProtocolName,
+		Version:   uint8(back.config.ProtocolVersion),
+		MsgLength: ProtocolLength,
 		Func: func(p p2p.Peer) error {
 			return back.handler.handleNewPeer(p)
 		},
+		MsgSizeLimits: msgSizeLimits,
 	})
 	return back, nil
 }
 
 func (b *Backend) Start() error {
 	b.handler.Start()
+	if b.pruner != nil {
+		b.pruner.Start()
+	}
 	return nil
 }
 
@@ -140,7 +177,7 @@ func (b *Backend) EventBus() *xfsgo.EventBus {
 	return b.eventBus
 }
 
-func (b *Backend) StateDB() *badger.Storage {
+func (b *Backend) StateDB() storage.Database {
 	return b.config.StateDB
 }
 
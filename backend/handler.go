@@ -18,12 +18,12 @@ package backend
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
 	"sync"
 	"time"
 	"xfsgo"
 	"xfsgo/common"
+	"xfsgo/common/rawencode"
 	"xfsgo/p2p"
 	"xfsgo/p2p/discover"
 
@@ -126,8 +126,8 @@ func (h *handler) handleMsg(p *peer) error {
 	switch msgCode {
 	case GetBlockHashesFromNumberMsg:
 		// Get local block Hash list
-		var data *getBlockHashesFromNumberData = nil
-		if err := json.Unmarshal(bodyBs, &data); err != nil {
+		data := &getBlockHashesFromNumberData{}
+		if err := rawencode.Decode(bodyBs, data); err != nil {
 			logrus.Warnf("handle GetBlockHashesFromNumberMsg msg err: %s", err)
 			return err
 		}
@@ -140,8 +140,8 @@ func (h *handler) handleMsg(p *peer) error {
 		}
 	case BlockHashesMsg:
 		// Accept block Hash list message
-		var data []common.Hash = nil
-		if err := json.Unmarshal(bodyBs, &data); err != nil {
+		var data remoteHashes = nil
+		if err := rawencode.Decode(bodyBs, &data); err != nil {
 			logrus.Warnf("handle BlockHashesMsg msg err: %s", err)
 			return err
 		}
@@ -151,8 +151,8 @@ func (h *handler) handleMsg(p *peer) error {
 		}
 	case GetBlocksMsg:
 		// Process get block list request
-		var data []common.Hash = nil
-		if err := json.Unmarshal(bodyBs, &data); err != nil {
+		var data remoteHashes = nil
+		if err := rawencode.Decode(bodyBs, &data); err != nil {
 			logrus.Warnf("handle GetBlocksMsg msg err: %s", err)
 			return err
 		}
@@ -171,7 +171,7 @@ func (h *handler) handleMsg(p *peer) error {
 	case BlocksMsg: // Accept block list message
 		// Accept block list message
 		var data remoteBlocks = nil
-		if err := json.Unmarshal(bodyBs, &data); err != nil {
+		if err := rawencode.Decode(bodyBs, &data); err != nil {
 			logrus.Warnf("handle BlocksMsg msg err: %s", err)
 			return err
 		}
@@ -181,8 +181,8 @@ func (h *handler) handleMsg(p *peer) error {
 		}
 	case NewBlockMsg: // Processing block broadcasting
 		// Processing block broadcasting
-		var data *xfsgo.Block = nil
-		if err := json.Unmarshal(bodyBs, &data); err != nil {
+		data := &xfsgo.Block{}
+		if err := rawencode.Decode(bodyBs, data); err != nil {
 			logrus.Warnf("handle NewBlockMsg err: %s", err)
 			return err
 		}
@@ -193,7 +193,7 @@ func (h *handler) handleMsg(p *peer) error {
 	case TxMsg: // Process transaction broadcast
 		// Process transaction broadcast
 		var txs remoteTxs = nil
-		if err := json.Unmarshal(bodyBs, &txs); err != nil {
+		if err := rawencode.Decode(bodyBs, &txs); err != nil {
 			logrus.Warnf("handle TxMsg msg err: %s", err)
 			return err
 		}
@@ -203,8 +203,8 @@ func (h *handler) handleMsg(p *peer) error {
 			}
 		}
 	case AllSyncMsg:
-		var txsr *AllSyncData = nil
-		if err := json.Unmarshal(bodyBs, &txsr); err != nil {
+		txsr := &AllSyncData{}
+		if err := rawencode.Decode(bodyBs, txsr); err != nil {
 			logrus.Warnf("handle AllSyncData msg err: %s", err)
 			return err
 		}
@@ -276,7 +276,7 @@ func (h *handler) synchronise(p *peer) {
 		return
 	}
 	h.syncLock.Lock()
-	h.eventBus.Publish(xfsgo.SyncStartEvent{})
+	h.eventBus.Publish(xfsgo.SyncStartEvent{PeerHeight: p.height})
 	defer func() {
 		h.eventBus.Publish(xfsgo.SyncDoneEvent{})
 		h.syncLock.Unlock()
@@ -17,11 +17,11 @@
 package backend
 
 import (
-	"encoding/json"
 	"errors"
 	"time"
 	"xfsgo"
 	"xfsgo/common"
+	"xfsgo/common/rawencode"
 	"xfsgo/p2p"
 
 	"github.com/sirupsen/logrus"
@@ -35,17 +35,34 @@ type peer struct {
 	height  uint64
 }
 
+// ProtocolName is the capability name of the chain protocol.
+const ProtocolName = "xfs"
+
+// Message codes of the chain protocol, relative to its offset on the connection.
 const (
-	MsgCodeVersion              uint8 = 5
-	GetBlockHashesFromNumberMsg uint8 = 6
-	BlockHashesMsg              uint8 = 7
-	GetBlocksMsg                uint8 = 8
-	BlocksMsg                   uint8 = 9
-	NewBlockMsg                 uint8 = 10
-	TxMsg                       uint8 = 11
-	AllSyncMsg                  uint8 = 12
+	MsgCodeVersion              uint8 = 0
+	GetBlockHashesFromNumberMsg uint8 = 1
+	BlockHashesMsg              uint8 = 2
+	GetBlocksMsg                uint8 = 3
+	BlocksMsg                   uint8 = 4
+	NewBlockMsg                 uint8 = 5
+	TxMsg                       uint8 = 6
+	AllSyncMsg                  uint8 = 7
+
+	// ProtocolLength is the number of message codes of the chain protocol.
+	ProtocolLength uint8 = 8
 )
 
+// msgSizeLimits bounds the messages of the protocol that never carry
+// blocks or transactions, so that a peer can't make us buffer large payloads.
+var msgSizeLimits = map[uint8]uint32{
+	MsgCodeVersion:              1024,
+	GetBlockHashesFromNumberMsg: 1024,
+	BlockHashesMsg:              256 * 1024,
+	GetBlocksMsg:                256 * 1024,
+	AllSyncMsg:                  1024,
+}
+
 func newPeer(p p2p.Peer, version uint32, network uint32) *peer {
 	pt := &peer{
 		p2pPeer: p,
@@ -80,6 +97,182 @@ type remoteTxs []*xfsgo.Transaction
 type remoteHashes []common.Hash
 type remoteBlocks []*xfsgo.Block
 
+// msgFormatV1 is the leading byte of the binary encoding of protocol messages.
+const msgFormatV1 uint8 = 1
+
+func newMsgReader(data []byte) (*rawencode.BinaryReader, error) {
+	r := rawencode.NewBinaryReader(data)
+	if r.ReadUint8() != msgFormatV1 {
+		return nil, rawencode.ErrFormatVersion
+	}
+	return r, nil
+}
+
+func newMsgWriter() *rawencode.BinaryWriter {
+	w := rawencode.NewBinaryWriter()
+	w.WriteUint8(msgFormatV1)
+	return w
+}
+
+// status = format(1byte)+version(4byte)+network(4byte)+head(32byte)+height(8byte)
+func (s *statusData) Encode() ([]byte, error) {
+	w := newMsgWriter()
+	w.WriteUint32(s.Version)
+	w.WriteUint32(s.Network)
+	w.WriteFixed(s.Head[:])
+	w.WriteUint64(s.Height)
+	return w.Bytes(), nil
+}
+
+func (s *statusData) Decode(data []byte) error {
+	r, err := newMsgReader(data)
+	if err != nil {
+		return err
+	}
+	s.Version = r.ReadUint32()
+	s.Network = r.ReadUint32()
+	r.ReadFixed(s.Head[:])
+	s.Height = r.ReadUint64()
+	return r.Finish()
+}
+
+// request = format(1byte)+from(8byte)+count(8byte)
+func (d *getBlockHashesFromNumberData) Encode() ([]byte, error) {
+	w := newMsgWriter()
+	w.WriteUint64(d.From)
+	w.WriteUint64(d.Count)
+	return w.Bytes(), nil
+}
+
+func (d *getBlockHashesFromNumberData) Decode(data []byte) error {
+	r, err := newMsgReader(data)
+	if err != nil {
+		return err
+	}
+	d.From = r.ReadUint64()
+	d.Count = r.ReadUint64()
+	return r.Finish()
+}
+
+// sync = format(1byte)+id+head(32byte)+height(8byte)
+func (d *AllSyncData) Encode() ([]byte, error) {
+	w := newMsgWriter()
+	w.WriteBytes([]byte(d.ID))
+	w.WriteFixed(d.Head[:])
+	w.WriteUint64(d.Height)
+	return w.Bytes(), nil
+}
+
+func (d *AllSyncData) Decode(data []byte) error {
+	r, err := newMsgReader(data)
+	if err != nil {
+		return err
+	}
+	d.ID = string(r.ReadBytes())
+	r.ReadFixed(d.Head[:])
+	d.Height = r.ReadUint64()
+	return r.Finish()
+}
+
+// hashes = format(1byte)+count(4byte)+hash(32byte)*count
+func (hs remoteHashes) Encode() ([]byte, error) {
+	w := newMsgWriter()
+	w.WriteUint32(uint32(len(hs)))
+	for _, h := range hs {
+		w.WriteFixed(h[:])
+	}
+	return w.Bytes(), nil
+}
+
+func (hs *remoteHashes) Decode(data []byte) error {
+	r, err := newMsgReader(data)
+	if err != nil {
+		return err
+	}
+	n := r.ReadUint32()
+	if uint64(n)*uint64(len(common.Hash{})) > uint64(r.Remaining()) {
+		return rawencode.ErrShortData
+	}
+	result := make(remoteHashes, n)
+	for i := range result {
+		r.ReadFixed(result[i][:])
+	}
+	*hs = result
+	return r.Finish()
+}
+
+// blocks = format(1byte)+count(4byte)+(length(4byte)+block)*count
+func (bs remoteBlocks) Encode() ([]byte, error) {
+	w := newMsgWriter()
+	w.WriteUint32(uint32(len(bs)))
+	for _, b := range bs {
+		data, err := b.Encode()
+		if err != nil {
+			return nil, err
+		}
+		w.WriteBytes(data)
+	}
+	return w.Bytes(), nil
+}
+
+func (bs *remoteBlocks) Decode(data []byte) error {
+	r, err := newMsgReader(data)
+	if err != nil {
+		return err
+	}
+	n := r.ReadUint32()
+	result := make(remoteBlocks, 0)
+	for i := uint32(0); i < n; i++ {
+		bData := r.ReadBytes()
+		if err = r.Err(); err != nil {
+			return err
+		}
+		b := &xfsgo.Block{}
+		if err = b.Decode(bData); err != nil {
+			return err
+		}
+		result = append(result, b)
+	}
+	*bs = result
+	return r.Finish()
+}
+
+// txs = format(1byte)+count(4byte)+(length(4byte)+tx)*count
+func (txs remoteTxs) Encode() ([]byte, error) {
+	w := newMsgWriter()
+	w.WriteUint32(uint32(len(txs)))
+	for _, tx := range txs {
+		data, err := tx.Encode()
+		if err != nil {
+			return nil, err
+		}
+		w.WriteBytes(data)
+	}
+	return w.Bytes(), nil
+}
+
+func (txs *remoteTxs) Decode(data []byte) error {
+	r, err := newMsgReader(data)
+	if err != nil {
+		return err
+	}
+	n := r.ReadUint32()
+	result := make(remoteTxs, 0)
+	for i := uint32(0); i < n; i++ {
+		txData := r.ReadBytes()
+		if err = r.Err(); err != nil {
+			return err
+		}
+		tx := &xfsgo.Transaction{}
+		if err = tx.Decode(txData); err != nil {
+			return err
+		}
+		result = append(result, tx)
+	}
+	*txs = result
+	return r.Finish()
+}
+
 // Handshake runs the protocol handshake using messages(hash value and height of current block).
 // to verifies whether the peer matchs the prptocol that attempts to add the connection as a peer.
 func (p *peer) Handshake(head common.Hash, height uint64) error {
@@ -105,7 +298,7 @@ func (p *peer) Handshake(head common.Hash, height uint64) error {
 				data, _ := msg.ReadAll()
 				logrus.Infof("handle message type: %d, data: %s", msgCode, string(data))
 				status := statusData{}
-				if err := json.Unmarshal(data, &status); err != nil {
+				if err := rawencode.Decode(data, &status); err != nil {
 					return err
 				}
 				if status.Version != p.version {
@@ -140,7 +333,7 @@ func (p *peer) RequestHashesFromNumber(from uint64, count uint64) error {
 }
 
 func (p *peer) SendAllSync(allMsg *AllSyncData) error {
-	if err := p2p.SendMsgData(p.p2pPeer, AllSyncMsg, &allMsg); err != nil {
+	if err := p2p.SendMsgData(p.p2pPeer, AllSyncMsg, allMsg); err != nil {
 		return err
 	}
 	return nil
@@ -30,6 +30,18 @@ var (
 	noneAddress = common.Bytes2Address([]byte{})
 )
 
+// binaryFormatV1 is the leading byte of the binary encoding of blocks,
+// headers, transactions and receipts.
+const binaryFormatV1 uint8 = 1
+
+// BlockVersion is the version of the headers created by this node.
+// Headers from version 1 on are hashed over their binary encoding,
+// older headers keep the hash of their legacy JSON encoding so that
+// existing chains stay valid. The transactions and receipts of the older
+// blocks likewise keep their JSON encoding, and so their hashes, signatures
+// and roots.
+const BlockVersion int32 = 1
+
 // BlockHeader represents a block header in the xfs blockchain.
 // It is importance to note that the BlockHeader includes StateRoot,TransactionsRoot
 // and ReceiptsRoot fields which implement the state management of the xfs blockchain.
@@ -48,12 +60,55 @@ type BlockHeader struct {
 	Nonce uint64 `json:"nonce"`
 }
 
+// Encode returns the canonical binary encoding of the header.
+// header = format(1byte)+height(8byte)+version(4byte)+hash_prev_block(32byte)+timestamp(8byte)+
+// coinbase(25byte)+state_root(32byte)+transactions_root(32byte)+receipts_root(32byte)+bits(4byte)+nonce(8byte)
 func (header *BlockHeader) Encode() ([]byte, error) {
-	return json.Marshal(header)
+	w := rawencode.NewBinaryWriter()
+	w.WriteUint8(binaryFormatV1)
+	w.WriteUint64(header.Height)
+	w.WriteInt32(header.Version)
+	w.WriteFixed(header.HashPrevBlock[:])
+	w.WriteUint64(header.Timestamp)
+	w.WriteFixed(header.Coinbase[:])
+	w.WriteFixed(header.StateRoot[:])
+	w.WriteFixed(header.TransactionsRoot[:])
+	w.WriteFixed(header.ReceiptsRoot[:])
+	w.WriteUint32(header.Bits)
+	w.WriteUint64(header.Nonce)
+	return w.Bytes(), nil
 }
 
+// Decode parses the binary encoding of the header, or its legacy JSON encoding.
 func (header *BlockHeader) Decode(data []byte) error {
-	return json.Unmarshal(data, header)
+	if rawencode.IsLegacyJSON(data) {
+		return json.Unmarshal(data, header)
+	}
+	r := rawencode.NewBinaryReader(data)
+	if r.ReadUint8() != binaryFormatV1 {
+		return rawencode.ErrFormatVersion
+	}
+	header.Height = r.ReadUint64()
+	header.Version = r.ReadInt32()
+	r.ReadFixed(header.HashPrevBlock[:])
+	header.Timestamp = r.ReadUint64()
+	r.ReadFixed(header.Coinbase[:])
+	r.ReadFixed(header.StateRoot[:])
+	r.ReadFixed(header.TransactionsRoot[:])
+	r.ReadFixed(header.ReceiptsRoot[:])
+	header.Bits = r.ReadUint32()
+	header.Nonce = r.ReadUint64()
+	return r.Finish()
+}
+
+// hashData returns the bytes the header hash is computed over.
+func (header *BlockHeader) hashData() []byte {
+	if header.Version < BlockVersion {
+		data, _ := json.Marshal(header)
+		return data
+	}
+	data, _ := header.Encode()
+	return data
 }
 func (header *BlockHeader) clone() *BlockHeader {
 	p := *header
@@ -87,23 +142,50 @@ func NewBlock(header *BlockHeader, txs []*Transaction, receipts []*Receipt) *Blo
 	b := &Block{
 		Header: header,
 	}
+	legacy := header.Version < BlockVersion
 	if len(txs) == 0 {
 		b.Header.TransactionsRoot = emptyHash
 	} else {
-		b.Header.TransactionsRoot = CalcTxsRootHash(txs)
 		b.Transactions = make([]*Transaction, len(txs))
 		copy(b.Transactions, txs)
+		for i, tx := range b.Transactions {
+			if tx.legacy != legacy {
+				b.Transactions[i] = tx.clone()
+				b.Transactions[i].legacy = legacy
+			}
+		}
+		b.Header.TransactionsRoot = CalcTxsRootHash(b.Transactions)
 	}
 	if len(receipts) == 0 {
 		b.Header.ReceiptsRoot = emptyHash
 	} else {
-		b.Header.ReceiptsRoot = CalcReceiptRootHash(receipts)
 		b.Receipts = make([]*Receipt, len(receipts))
 		copy(b.Receipts, receipts)
+		for i, rec := range b.Receipts {
+			if rec.legacy != legacy {
+				nr := *rec
+				nr.legacy = legacy
+				b.Receipts[i] = &nr
+			}
+		}
+		b.Header.ReceiptsRoot = CalcReceiptRootHash(b.Receipts)
 	}
 	return b
 }
 
+// markLegacy marks the transactions and receipts of a block before BlockVersion.
+func (b *Block) markLegacy() {
+	if b.Header == nil || b.Header.Version >= BlockVersion {
+		return
+	}
+	for _, tx := range b.Transactions {
+		tx.legacy = true
+	}
+	for _, rec := range b.Receipts {
+		rec.legacy = true
+	}
+}
+
 func (b *Block) GetHeader() *BlockHeader {
 	return b.Header
 }
@@ -133,12 +215,89 @@ func CalcReceiptRootHash(recs []*Receipt) common.Hash {
 	return common.Bytes2Hash(tree.Checksum())
 }
 
+// Encode returns the canonical binary encoding of the block.
+// block = format(1byte)+header+tx_count(4byte)+txs+receipt_count(4byte)+receipts,
+// where header and every transaction and receipt are prefixed by their length.
 func (b *Block) Encode() ([]byte, error) {
-	return json.Marshal(b)
+	w := rawencode.NewBinaryWriter()
+	w.WriteUint8(binaryFormatV1)
+	header, err := b.Header.Encode()
+	if err != nil {
+		return nil, err
+	}
+	w.WriteBytes(header)
+	w.WriteUint32(uint32(len(b.Transactions)))
+	for _, tx := range b.Transactions {
+		data, err := tx.Encode()
+		if err != nil {
+			return nil, err
+		}
+		w.WriteBytes(data)
+	}
+	w.WriteUint32(uint32(len(b.Receipts)))
+	for _, rec := range b.Receipts {
+		data, err := rec.Encode()
+		if err != nil {
+			return nil, err
+		}
+		w.WriteBytes(data)
+	}
+	return w.Bytes(), nil
 }
 
+// Decode parses the binary encoding of the block, or its legacy JSON encoding.
 func (b *Block) Decode(data []byte) error {
-	return json.Unmarshal(data, b)
+	if rawencode.IsLegacyJSON(data) {
+		if err := json.Unmarshal(data, b); err != nil {
+			return err
+		}
+		b.markLegacy()
+		return nil
+	}
+	r := rawencode.NewBinaryReader(data)
+	if r.ReadUint8() != binaryFormatV1 {
+		return rawencode.ErrFormatVersion
+	}
+	b.Header = &BlockHeader{}
+	if err := decodeField(r, b.Header); err != nil {
+		return err
+	}
+	b.Transactions = nil
+	if n := r.ReadUint32(); n > 0 {
+		b.Transactions = make([]*Transaction, 0)
+		for i := uint32(0); i < n; i++ {
+			tx := &Transaction{}
+			if err := decodeField(r, tx); err != nil {
+				return err
+			}
+			b.Transactions = append(b.Transactions, tx)
+		}
+	}
+	b.Receipts = nil
+	if n := r.ReadUint32(); n > 0 {
+		b.Receipts = make([]*Receipt, 0)
+		for i := uint32(0); i < n; i++ {
+			rec := &Receipt{}
+			if err := decodeField(r, rec); err != nil {
+				return err
+			}
+			b.Receipts = append(b.Receipts, rec)
+		}
+	}
+	if err := r.Finish(); err != nil {
+		return err
+	}
+	b.markLegacy()
+	return nil
+}
+
+// decodeField decodes the next length prefixed encoding of r into obj.
+func decodeField(r *rawencode.BinaryReader, obj rawencode.RawEncoder) error {
+	data := r.ReadBytes()
+	if err := r.Err(); err != nil {
+		return err
+	}
+	return obj.Decode(data)
 }
 
 func (b *Block) HashPrevBlock() common.Hash {
@@ -147,14 +306,12 @@ func (b *Block) HashPrevBlock() common.Hash {
 
 func (b *Block) HashNoNonce() common.Hash {
 	header := b.Header.copyTrim()
-	data, _ := rawencode.Encode(header)
-	hash := ahash.SHA256(data)
+	hash := ahash.SHA256(header.hashData())
 	return common.Bytes2Hash(hash)
 }
 
 func (b *Block) Hash() common.Hash {
-	data, _ := rawencode.Encode(b.Header)
-	hash := ahash.SHA256(data)
+	hash := ahash.SHA256(b.Header.hashData())
 	return common.Bytes2Hash(hash)
 }
 func (b *Block) HashHex() string {
@@ -18,15 +18,17 @@ package xfsgo
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"sync"
 	"time"
 	"xfsgo/common"
 	"xfsgo/common/rawencode"
 	"xfsgo/lru"
-	"xfsgo/storage/badger"
+	"xfsgo/storage"
 
 	"github.com/sirupsen/logrus"
 )
@@ -48,7 +50,7 @@ const (
 // in the database as well as blocks that represents the canonical chain.
 
 type BlockChain struct {
-	stateDB       *badger.Storage
+	stateDB       storage.Database
 	chainDB       *chainDB
 	extraDB       *extraDB
 	genesisBlock  *Block
@@ -59,12 +61,14 @@ type BlockChain struct {
 	chainmu       sync.RWMutex
 	orphansCache  *lru.Cache
 	eventBus      *EventBus
+	// addrIndex is set when the transactions are indexed by address.
+	addrIndex bool
 }
 
 //NewBlockChain creates a initialised block chain using information available in the database.
 //this new blockchain includes a stateTree by which the blockchian can manage the whole state of the chainb.
 //such as the account's information of every user.
-func NewBlockChain(stateDB, chainDB, extraDB *badger.Storage, eventBus *EventBus) (*BlockChain, error) {
+func NewBlockChain(stateDB, chainDB, extraDB storage.Database, eventBus *EventBus) (*BlockChain, error) {
 	bc := &BlockChain{
 		chainDB:  newChainDB(chainDB),
 		stateDB:  stateDB,
@@ -158,13 +162,23 @@ func (bc *BlockChain) GetBalance(addr common.Address) *big.Int {
 }
 
 // WriteBlock stores the block inputed to the local database.
+// The block, its transaction index, its receipts and the head pointer when
+// it extends the chain are written at once when the chain and extra databases
+// are tables of the same database, and the block fits in one transaction.
 func (bc *BlockChain) WriteBlock(block *Block) error {
 	cb := bc.currentBlock
-	if block.Height() > cb.Height() {
+	newHead := block.Height() > cb.Height()
+	err := bc.writeBlock(block, newHead)
+	if err == storage.ErrTxnTooBig {
+		logrus.Warnf("Block too large to be written at once: height=%d, hash=%s, txs=%d",
+			block.Height(), block.HashHex(), len(block.Transactions))
+		err = bc.writeLargeBlock(block, newHead)
+	}
+	if err != nil {
+		return err
+	}
+	if newHead {
 		bc.mu.Lock()
-		if err := bc.chainDB.WriteHead(block); err != nil {
-			logrus.Errorf("write head err: %s", err)
-		}
 		bc.currentBlock = block
 		bc.lastBlockHash = block.Hash()
 		lastStateRoot := block.StateRoot()
@@ -172,13 +186,61 @@ func (bc *BlockChain) WriteBlock(block *Block) error {
 		bc.mu.Unlock()
 		bc.eventBus.Publish(ChainHeadEvent{block})
 	}
-	if err := bc.extraDB.WriteBlockTransaction(block); err != nil {
+	return nil
+}
+
+func (bc *BlockChain) writeBlockIndexes(w storage.Writer, block *Block) error {
+	if err := bc.extraDB.writeBlockTransaction(w, block); err != nil {
 		return err
 	}
-	if err := bc.extraDB.WriteBlockReceipts(block); err != nil {
+	if err := bc.extraDB.writeBlockReceipts(w, block); err != nil {
 		return err
 	}
-	return bc.chainDB.WriteBlock(block)
+	if bc.addrIndex {
+		return bc.extraDB.writeAddressIndex(w, block)
+	}
+	return nil
+}
+
+func (bc *BlockChain) writeBlockData(w storage.Writer, block *Block, newHead bool) error {
+	if err := bc.chainDB.writeBlock(w, block); err != nil {
+		return err
+	}
+	if newHead {
+		return bc.chainDB.writeHead(w, block)
+	}
+	return nil
+}
+
+func (bc *BlockChain) writeBlock(block *Block, newHead bool) error {
+	batch := storage.NewBatch()
+	defer batch.Discard()
+	if err := bc.writeBlockIndexes(batch.Writer(bc.extraDB.storage), block); err != nil {
+		return err
+	}
+	if err := bc.writeBlockData(batch.Writer(bc.chainDB.storage), block, newHead); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
+// writeLargeBlock writes the indexes of a block in as many transactions as
+// needed, then the block and the head pointer at once, so that the block is
+// not stored before its indexes. The indexes written before a failure are
+// written again with the block.
+func (bc *BlockChain) writeLargeBlock(block *Block, newHead bool) error {
+	if err := storage.UpdateChunked(bc.extraDB.storage, func(w storage.Writer) error {
+		return bc.writeBlockIndexes(w, block)
+	}); err != nil {
+		return err
+	}
+	err := storage.Update(bc.chainDB.storage, func(w storage.Writer) error {
+		return bc.writeBlockData(w, block, newHead)
+	})
+	if err == storage.ErrTxnTooBig {
+		return fmt.Errorf("block %s too large to be stored: %v", block.HashHex(), err)
+	}
+	return err
 }
 
 func (bc *BlockChain) WriteBlockTransaction(block *Block) error {
@@ -193,6 +255,32 @@ func (bc *BlockChain) GetTransaction(Hash common.Hash) *Transaction {
 	return bc.extraDB.GetTransactionByHash(Hash)
 }
 
+// TxLookup is the position of a stored transaction, Canonical is set when
+// its block is in the canonical chain.
+type TxLookup struct {
+	BlockHash   common.Hash
+	BlockHeight uint64
+	Index       uint64
+	Canonical   bool
+}
+
+// GetTransactionLookup returns the position of the transaction with the hash,
+// nil when it is not stored. A transaction of several blocks has the position
+// in the last written one.
+func (bc *BlockChain) GetTransactionLookup(hash common.Hash) *TxLookup {
+	index := bc.extraDB.getTxIndex(hash)
+	if index == nil {
+		return nil
+	}
+	canonical, ok := bc.chainDB.getBlockHashByNumber(index.BlockIndex)
+	return &TxLookup{
+		BlockHash:   index.BlockHash,
+		BlockHeight: index.BlockIndex,
+		Index:       index.Index,
+		Canonical:   ok && canonical == index.BlockHash,
+	}
+}
+
 // calculate rewards for packing the block by miners
 func calcBlockSubsidy(currentHeight uint64) *big.Int {
 	// reduce the reward by half
@@ -237,7 +325,11 @@ func (bc *BlockChain) InsertChain(block *Block) error {
 		return fmt.Errorf("check transaction root err")
 	}
 	parentStateRoot := parent.StateRoot()
-	stateTree := NewStateTree(bc.stateDB, parentStateRoot.Bytes())
+	// the state of the parent may have been pruned
+	stateTree, err := openStateTree(bc.stateDB, parentStateRoot.Bytes())
+	if err != nil {
+		return fmt.Errorf("open state of parent block %v err: %v", parent.Hash(), err)
+	}
 	rs, err := bc.ApplyTransactions(stateTree, txs)
 	if err != nil {
 		return err
@@ -291,6 +383,9 @@ func (bc *BlockChain) checkBlockHeaderSanity(header *BlockHeader, blockHash comm
 }
 
 func (bc *BlockChain) checkTransactionSanity(tx *Transaction) error {
+	if tx.Value == nil {
+		return errNoValue
+	}
 	if !tx.VerifySignature() {
 		return fmt.Errorf("VerifySignature err")
 	}
@@ -312,6 +407,7 @@ func (bc *BlockChain) applyTransaction(stateTree *StateTree, tx *Transaction) (*
 	stateTree.UpdateAll()
 	receipt := &Receipt{
 		TxHash: tx.Hash(),
+		legacy: tx.legacy,
 	}
 	return receipt, nil
 }
@@ -356,14 +452,16 @@ func (bc *BlockChain) GetBlockHashes(from uint64, count uint64) []common.Hash {
 	return hashes
 }
 
-func (bc *BlockChain) GetBlockSection(from uint64, count uint64) []*Block {
+// GetBlockSection returns the canonical blocks from the height from, it stops
+// early when ctx is done.
+func (bc *BlockChain) GetBlockSection(ctx context.Context, from uint64, count uint64) []*Block {
 	head := bc.currentBlock.Height()
 	result := int(from) + int(count)
 	if uint64(result) > head {
 		return nil
 	}
 	hashes := make([]*Block, 0)
-	for h := uint64(0); from+h <= count; h++ {
+	for h := uint64(0); from+h <= count && ctx.Err() == nil; h++ {
 		block := bc.GetBlockByNumber(from + h)
 		hashes = append(hashes, block)
 	}
@@ -458,3 +556,37 @@ func (bc *BlockChain) CalcNextRequiredDifficulty() (uint32, error) {
 func (bc *BlockChain) CurrentStateTree() *StateTree {
 	return bc.stateTree
 }
+
+// StateAt opens the state with the given root, such as the state root
+// of a block, for reading. See OpenStateTree.
+func (bc *BlockChain) StateAt(root common.Hash) (*StateTree, error) {
+	return OpenStateTree(bc.stateDB, root.Bytes())
+}
+
+// PendingState returns the head state with the transactions txs applied
+// in the order of their nonces, the transactions which can't be applied
+// are skipped. It is only kept in memory.
+func (bc *BlockChain) PendingState(txs []*Transaction) (*StateTree, error) {
+	stateTree, err := bc.StateAt(bc.CurrentBlock().Header.StateRoot)
+	if err != nil {
+		return nil, err
+	}
+	sorted := make([]*Transaction, len(txs))
+	copy(sorted, txs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Nonce < sorted[j].Nonce
+	})
+	for _, tx := range sorted {
+		sender, err := tx.FromAddr()
+		if err != nil || tx.Value == nil {
+			continue
+		}
+		if stateTree.GetBalance(sender).Cmp(tx.Value) < 0 {
+			continue
+		}
+		if _, err = bc.applyTransaction(stateTree, tx); err != nil {
+			continue
+		}
+	}
+	return stateTree, nil
+}
@@ -17,9 +17,14 @@
 package xfsgo
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"xfsgo/assert"
+	"xfsgo/common"
+	"xfsgo/storage"
 	"xfsgo/storage/badger"
+	"xfsgo/storage/memdb"
 )
 
 func TestBlockChain_GetBlockHashes(t *testing.T) {
@@ -46,3 +51,189 @@ func TestBlockChain_GetBlockHashes(t *testing.T) {
 	assert.HashEqual(t, genesisBlock.Hash(), last.Hash())
 	t.Logf("%s\n", last)
 }
+
+// failingDB fails the commit of every transaction.
+type failingDB struct {
+	storage.Database
+}
+
+type failingTxn struct {
+	storage.Txn
+}
+
+var errTestCommit = errors.New("commit failed")
+
+func (t *failingTxn) Commit() error {
+	t.Txn.Discard()
+	return errTestCommit
+}
+
+func (db *failingDB) NewTxn() storage.Txn {
+	return &failingTxn{db.Database.NewTxn()}
+}
+
+func TestBlockChain_WriteBlockTables(t *testing.T) {
+	base := &failingDB{memdb.New()}
+	stateDb := storage.NewTable(base.Database, "state:")
+	chainDb := storage.NewTable(base, "chain:")
+	extraDb := storage.NewTable(base, "extra:")
+	genesis, err := WriteTestNetGenesisBlock(stateDb, storage.NewTable(base.Database, "chain:"))
+	assert.Error(t, err)
+	bc, err := NewBlockChain(stateDb, chainDb, extraDb, NewEventBus())
+	assert.Error(t, err)
+	block := NewBlock(&BlockHeader{
+		Height:        1,
+		Version:       BlockVersion,
+		HashPrevBlock: genesis.Hash(),
+		Timestamp:     genesis.Timestamp() + 1,
+		StateRoot:     genesis.StateRoot(),
+		Bits:          genesis.Bits(),
+	}, nil, nil)
+	// nothing of the block is written when the commit fails
+	if err = bc.WriteBlock(block); err != errTestCommit {
+		t.Fatalf("got err %v, want %v", err, errTestCommit)
+	}
+	if got := bc.CurrentBlock(); got.Hash() != genesis.Hash() {
+		t.Fatalf("got head %s, want the genesis block", got.HashHex())
+	}
+	blockHash := block.Hash()
+	for _, key := range [][]byte{
+		append([]byte("chain:"), append(blockHashPre, blockHash[:]...)...),
+		append([]byte("extra:"), append(blockReceiptsPre, blockHash[:]...)...),
+	} {
+		if has, _ := base.HasData(key); has {
+			t.Fatalf("key %q is stored", key)
+		}
+	}
+	// the same tables on a working database
+	bc.chainDB = newChainDB(storage.NewTable(base.Database, "chain:"))
+	bc.extraDB = newExtraDB(storage.NewTable(base.Database, "extra:"))
+	assert.Error(t, bc.WriteBlock(block))
+	assert.HashEqual(t, bc.CurrentBlock().Hash(), blockHash)
+	assert.HashEqual(t, bc.GetHead().Hash(), blockHash)
+	assert.HashEqual(t, bc.GetBlockByNumber(1).Hash(), blockHash)
+}
+
+// smallTxnDB holds at most maxPuts writes in a transaction.
+type smallTxnDB struct {
+	storage.Database
+	maxPuts int
+}
+
+type smallTxn struct {
+	storage.Txn
+	puts, maxPuts int
+}
+
+func (t *smallTxn) Put(key, value []byte) error {
+	if t.puts == t.maxPuts {
+		return storage.ErrTxnTooBig
+	}
+	t.puts++
+	return t.Txn.Put(key, value)
+}
+
+func (db *smallTxnDB) NewTxn() storage.Txn {
+	return &smallTxn{Txn: db.Database.NewTxn(), maxPuts: db.maxPuts}
+}
+
+func TestBlockChain_WriteLargeBlock(t *testing.T) {
+	base := &smallTxnDB{Database: memdb.New(), maxPuts: 4}
+	stateDb := storage.NewTable(base.Database, "state:")
+	genesis, err := WriteTestNetGenesisBlock(stateDb, storage.NewTable(base.Database, "chain:"))
+	assert.Error(t, err)
+	bc, err := NewBlockChain(stateDb, storage.NewTable(base, "chain:"), storage.NewTable(base, "extra:"), NewEventBus())
+	assert.Error(t, err)
+	key, to := newTestAccount(t)
+	txs := make([]*Transaction, 0)
+	for i := uint64(0); i < 4; i++ {
+		txs = append(txs, newTestTransfer(t, key, to, i))
+	}
+	block := writeTestBlock(t, bc, genesis, txs)
+	assert.HashEqual(t, bc.CurrentBlock().Hash(), block.Hash())
+	for i, tx := range txs {
+		lookup := bc.GetTransactionLookup(tx.Hash())
+		if lookup == nil || lookup.BlockHash != block.Hash() || lookup.Index != uint64(i) {
+			t.Fatalf("got lookup %+v of transaction %d", lookup, i)
+		}
+	}
+
+	// the block itself does not fit
+	base.maxPuts = 1
+	if err = bc.WriteBlock(NewBlock(&BlockHeader{
+		Height:        2,
+		Version:       BlockVersion,
+		HashPrevBlock: block.Hash(),
+		Timestamp:     block.Timestamp() + 1,
+		StateRoot:     block.StateRoot(),
+		Bits:          block.Bits(),
+	}, nil, nil)); err == nil {
+		t.Fatalf("wrote a block larger than a transaction")
+	}
+	assert.HashEqual(t, bc.CurrentBlock().Hash(), block.Hash())
+}
+
+func TestBlockChain_GetTransactionLookup(t *testing.T) {
+	bc := newTestChain(t)
+	key, _ := newTestAccount(t)
+	_, to := newTestAccount(t)
+	tx := newTestTransfer(t, key, to, 0)
+	side := newTestTransfer(t, key, to, 1)
+	genesis := bc.CurrentBlock()
+	block := writeTestBlock(t, bc, genesis, []*Transaction{newTestTransfer(t, key, to, 2), tx})
+	// a side block at the same height
+	writeTestBlock(t, bc, genesis, []*Transaction{side})
+
+	lookup := bc.GetTransactionLookup(tx.Hash())
+	if lookup == nil || !lookup.Canonical || lookup.BlockHash != block.Hash() ||
+		lookup.BlockHeight != 1 || lookup.Index != 1 {
+		t.Fatalf("got %+v, want the second transaction of the canonical block 1", lookup)
+	}
+	if lookup = bc.GetTransactionLookup(side.Hash()); lookup == nil || lookup.Canonical {
+		t.Fatalf("got %+v, want a transaction of a side block", lookup)
+	}
+	if lookup = bc.GetTransactionLookup(common.Hash{}); lookup != nil {
+		t.Fatalf("got %+v, want nil", lookup)
+	}
+}
+
+func TestBlockChain_PendingState_NoValue(t *testing.T) {
+	bc := newTestChain(t)
+	key, sender := newTestAccount(t)
+	_, to := newTestAccount(t)
+	assert.Error(t, bc.InsertChain(mineTestBlockOn(t, bc, bc.CurrentBlock(), sender)))
+	noValue := &Transaction{To: to}
+	assert.Error(t, noValue.SignWithPrivateKey(key))
+	tx := newTestTransfer(t, key, to, 0)
+
+	stateTree, err := bc.PendingState([]*Transaction{noValue, tx})
+	assert.Error(t, err)
+	if got := stateTree.GetBalance(to); got.Cmp(tx.Value) != 0 {
+		t.Fatalf("got balance %s, want %s", got, tx.Value)
+	}
+
+	head, err := bc.StateAt(bc.CurrentBlock().StateRoot())
+	assert.Error(t, err)
+	pool := NewTxPool(func() *StateTree {
+		return head
+	}, NewEventBus())
+	if err = pool.Add(noValue); err != errNoValue {
+		t.Fatalf("got err %v, want %v", err, errNoValue)
+	}
+	assert.Error(t, pool.Add(tx))
+}
+
+func TestBlockChain_GetBlockSection(t *testing.T) {
+	bc := newTestChain(t)
+	for i := 0; i < 3; i++ {
+		mineTestBlock(t, bc)
+	}
+	if got := bc.GetBlockSection(context.Background(), 0, 2); len(got) != 3 {
+		t.Fatalf("got %d blocks, want 3", len(got))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := bc.GetBlockSection(ctx, 0, 2); len(got) != 0 {
+		t.Fatalf("got %d blocks after cancel, want 0", len(got))
+	}
+}
@@ -34,9 +34,13 @@ package xfsgo
 
 import (
 	"bytes"
+	"encoding/json"
+	"math/big"
 	"testing"
+	"xfsgo/assert"
 	"xfsgo/common"
 	"xfsgo/common/rawencode"
+	"xfsgo/crypto"
 )
 
 func TestBlock_Hash(t *testing.T) {
@@ -83,3 +87,73 @@ func TestBlock_Decode(t *testing.T) {
 		t.Fatalf("got: %x, want: %x\n", gotHash, wantHash)
 	}
 }
+
+func TestBlock_EncodeBinary(t *testing.T) {
+	key, err := crypto.GenPrvKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := NewTransaction(common.Bytes2Address([]byte{0xff, 0xff}), new(big.Int).SetInt64(100))
+	if err = tx.SignWithPrivateKey(key); err != nil {
+		t.Fatal(err)
+	}
+	block := NewBlock(&BlockHeader{
+		Height:        1,
+		Version:       BlockVersion,
+		HashPrevBlock: common.Bytes2Hash([]byte{0xff, 0xff}),
+		Timestamp:     3,
+		Coinbase:      common.Bytes2Address([]byte{0xff, 0xff}),
+		Bits:          4,
+		Nonce:         5,
+	}, []*Transaction{tx}, []*Receipt{NewReceipt(tx.Hash())})
+	data, err := block.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != binaryFormatV1 {
+		t.Fatalf("got format: %d, want: %d", data[0], binaryFormatV1)
+	}
+	got := &Block{}
+	if err = got.Decode(data); err != nil {
+		t.Fatal(err)
+	}
+	assert.HashEqual(t, got.Hash(), block.Hash())
+	if len(got.Transactions) != 1 || len(got.Receipts) != 1 {
+		t.Fatalf("got txs: %d, receipts: %d, want 1 each", len(got.Transactions), len(got.Receipts))
+	}
+	assert.HashEqual(t, got.Transactions[0].Hash(), tx.Hash())
+	assert.HashEqual(t, got.Receipts[0].TxHash, tx.Hash())
+	if !got.Transactions[0].VerifySignature() {
+		t.Fatalf("decoded transaction signature not verify")
+	}
+	if err = got.Decode(append(data, 0)); err == nil {
+		t.Fatalf("decode with trailing bytes should fail")
+	}
+	if err = got.Decode(data[:len(data)-1]); err == nil {
+		t.Fatalf("decode truncated data should fail")
+	}
+	// the binary hash does not depend on the JSON encoding
+	legacy := block.Header.clone()
+	legacy.Version = 0
+	if bytes.Equal(legacy.hashData(), block.Header.hashData()) {
+		t.Fatalf("binary header hashed like legacy header")
+	}
+}
+
+func TestBlock_DecodeLegacyJSON(t *testing.T) {
+	block := NewBlock(&BlockHeader{
+		Height:    1,
+		Timestamp: 3,
+		Bits:      4,
+	}, nil, []*Receipt{NewReceipt(common.Bytes2Hash([]byte{0x01}))})
+	data, err := json.Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &Block{}
+	if err = got.Decode(data); err != nil {
+		t.Fatal(err)
+	}
+	assert.HashEqual(t, got.Hash(), block.Hash())
+	assert.HashEqual(t, got.Receipts[0].TxHash, block.Receipts[0].TxHash)
+}
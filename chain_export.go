@@ -0,0 +1,166 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The export file starts with chainFileMagic and the format version,
+// followed by the blocks in height order, each one encoded with
+// Block.Encode and prefixed with its length as a big-endian uint32.
+// The whole file may be gzip compressed.
+const (
+	chainFileVersion = uint32(1)
+	// maxExportBlockSize bounds the length read for one block, so that a
+	// corrupted file can't make the import allocate without limit.
+	maxExportBlockSize = 64 * 1024 * 1024
+)
+
+var (
+	chainFileMagic       = []byte("XFSCHAIN")
+	gzipMagic            = []byte{0x1f, 0x8b}
+	errInvalidChainFile  = errors.New("not a chain export file")
+	errChainFileVersion  = errors.New("unsupported chain export file version")
+	errExportBlockTooBig = errors.New("block in export file too big")
+)
+
+// ExportChain writes the canonical blocks with heights from..to to w,
+// to is lowered to the height of the head block. With compress the output
+// is gzip compressed. progress, when not nil, is called after each block.
+// It returns the number of written blocks.
+func ExportChain(bc *BlockChain, w io.Writer, from, to uint64, compress bool, progress func(block *Block)) (int, error) {
+	if head := bc.CurrentBlock().Height(); to > head {
+		to = head
+	}
+	if from > to {
+		return 0, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+	if compress {
+		zw := gzip.NewWriter(w)
+		defer func() {
+			_ = zw.Close()
+		}()
+		w = zw
+	}
+	bw := bufio.NewWriter(w)
+	var version [4]byte
+	binary.BigEndian.PutUint32(version[:], chainFileVersion)
+	if _, err := bw.Write(append(append([]byte{}, chainFileMagic...), version[:]...)); err != nil {
+		return 0, err
+	}
+	n := 0
+	for height := from; height <= to; height++ {
+		block := bc.GetBlockByNumber(height)
+		if block == nil {
+			return n, fmt.Errorf("canonical block %d not found", height)
+		}
+		data, err := block.Encode()
+		if err != nil {
+			return n, err
+		}
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+		if _, err = bw.Write(size[:]); err != nil {
+			return n, err
+		}
+		if _, err = bw.Write(data); err != nil {
+			return n, err
+		}
+		n++
+		if progress != nil {
+			progress(block)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return n, err
+	}
+	if zw, ok := w.(*gzip.Writer); ok {
+		return n, zw.Close()
+	}
+	return n, nil
+}
+
+// ImportChain reads the blocks of an export file from r, compressed or not,
+// and inserts them into the chain with InsertChain, which fully validates
+// them. Blocks the chain already has are skipped. progress, when not nil,
+// is called after each inserted block. It returns the number of inserted blocks.
+func ImportChain(bc *BlockChain, r io.Reader, progress func(block *Block)) (int, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(len(gzipMagic)); err == nil && bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return 0, err
+		}
+		defer func() {
+			_ = zr.Close()
+		}()
+		br = bufio.NewReader(zr)
+	}
+	header := make([]byte, len(chainFileMagic)+4)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return 0, errInvalidChainFile
+	}
+	if !bytes.Equal(header[:len(chainFileMagic)], chainFileMagic) {
+		return 0, errInvalidChainFile
+	}
+	if binary.BigEndian.Uint32(header[len(chainFileMagic):]) != chainFileVersion {
+		return 0, errChainFileVersion
+	}
+	n := 0
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(br, size[:]); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		length := binary.BigEndian.Uint32(size[:])
+		if length > maxExportBlockSize {
+			return n, errExportBlockTooBig
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return n, err
+		}
+		block := &Block{}
+		if err := block.Decode(data); err != nil {
+			return n, err
+		}
+		if bc.GetBlockByHash(block.Hash()) != nil {
+			continue
+		}
+		// InsertChain keeps blocks without a parent as orphans,
+		// an export file must be imported in order.
+		if parent := block.HashPrevBlock(); bc.GetBlockByHash(parent) == nil {
+			return n, fmt.Errorf("parent of block %d not found: %s", block.Height(), parent.Hex())
+		}
+		if err := bc.InsertChain(block); err != nil {
+			return n, fmt.Errorf("import block %d: %v", block.Height(), err)
+		}
+		n++
+		if progress != nil {
+			progress(block)
+		}
+	}
+}
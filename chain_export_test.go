@@ -0,0 +1,111 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"xfsgo/assert"
+	"xfsgo/common"
+	"xfsgo/storage/memdb"
+)
+
+func newTestChain(t *testing.T) *BlockChain {
+	stateDb, chainDb := memdb.New(), memdb.New()
+	_, err := WriteTestNetGenesisBlock(stateDb, chainDb)
+	assert.Error(t, err)
+	bc, err := NewBlockChain(stateDb, chainDb, memdb.New(), NewEventBus())
+	assert.Error(t, err)
+	return bc
+}
+
+// mineTestBlock inserts an empty block on top of the chain.
+func mineTestBlock(t *testing.T, bc *BlockChain) *Block {
+	parent := bc.CurrentBlock()
+	header := &BlockHeader{
+		Height:        parent.Height() + 1,
+		Version:       BlockVersion,
+		HashPrevBlock: parent.Hash(),
+		Timestamp:     parent.Timestamp() + 1,
+		Bits:          parent.Bits(),
+	}
+	stateTree := NewStateTree(bc.stateDB, parent.Header.StateRoot.Bytes())
+	AccumulateRewards(stateTree, header)
+	stateTree.UpdateAll()
+	header.StateRoot = common.Bytes2Hash(stateTree.Root())
+	block := NewBlock(header, nil, nil)
+	target := BitsUnzip(block.Bits())
+	for nonce := uint64(0); ; nonce++ {
+		hash := block.UpdateNonce(nonce)
+		if new(big.Int).SetBytes(hash[:]).Cmp(target) <= 0 {
+			break
+		}
+	}
+	assert.Error(t, bc.InsertChain(block))
+	return block
+}
+
+func TestExportImportChain(t *testing.T) {
+	src := newTestChain(t)
+	for i := 0; i < 3; i++ {
+		mineTestBlock(t, src)
+	}
+	head := src.CurrentBlock()
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		n, err := ExportChain(src, &buf, 0, 100, compress, nil)
+		assert.Error(t, err)
+		if n != 4 {
+			t.Fatalf("got exported: %d, want: 4", n)
+		}
+		dst := newTestChain(t)
+		heights := make([]uint64, 0)
+		n, err = ImportChain(dst, bytes.NewReader(buf.Bytes()), func(block *Block) {
+			heights = append(heights, block.Height())
+		})
+		assert.Error(t, err)
+		// the genesis block is already known
+		if n != 3 || len(heights) != 3 || heights[2] != 3 {
+			t.Fatalf("got imported: %d, heights: %v", n, heights)
+		}
+		assert.HashEqual(t, dst.CurrentBlock().Hash(), head.Hash())
+		// importing again skips the known blocks
+		n, err = ImportChain(dst, bytes.NewReader(buf.Bytes()), nil)
+		assert.Error(t, err)
+		if n != 0 {
+			t.Fatalf("got imported: %d on second run, want: 0", n)
+		}
+	}
+}
+
+func TestImportChain_MissingParent(t *testing.T) {
+	src := newTestChain(t)
+	for i := 0; i < 2; i++ {
+		mineTestBlock(t, src)
+	}
+	var buf bytes.Buffer
+	_, err := ExportChain(src, &buf, 2, 2, false, nil)
+	assert.Error(t, err)
+	dst := newTestChain(t)
+	if _, err = ImportChain(dst, &buf, nil); err == nil {
+		t.Fatalf("block without parent imported")
+	}
+	if _, err = ImportChain(dst, bytes.NewReader([]byte("not a chain file")), nil); err != errInvalidChainFile {
+		t.Fatalf("got err %v, want %v", err, errInvalidChainFile)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"math/big"
+	"sync"
+)
+
+const (
+	// DefaultStatsWindow is the number of blocks averaged by GetStats.
+	DefaultStatsWindow = 100
+	// subsidyHalvingInterval is the number of blocks between the halvings
+	// of the subsidy, see calcBlockSubsidy.
+	subsidyHalvingInterval = 210000
+)
+
+// ChainStats are the statistics of the head block and of the window of
+// canonical blocks ending at it.
+type ChainStats struct {
+	Height uint64
+	// Window is the number of averaged blocks, fewer than asked near the genesis block.
+	Window uint64
+	// AvgBlockTime is the average time between the blocks in seconds.
+	AvgBlockTime float64
+	// NextBits is the difficulty of the next block, Target is its unzipped
+	// form and Difficulty the average number of hashes mining it takes.
+	NextBits   uint32
+	Target     *big.Int
+	Difficulty *big.Int
+	// HashRate is the estimated number of hashes per second of the network.
+	HashRate *big.Int
+	// TotalSupply is the sum of the subsidies of the blocks, the genesis
+	// allocations excluded.
+	TotalSupply    *big.Int
+	Transactions   uint64
+	AvgTxsPerBlock float64
+}
+
+// TotalSubsidy returns the sum of the subsidies of the blocks from height 1 to height.
+func TotalSubsidy(height uint64) *big.Int {
+	total := new(big.Int)
+	for start := uint64(1); start <= height; {
+		// the last height of the halving interval of start
+		end := (start/subsidyHalvingInterval+1)*subsidyHalvingInterval - 1
+		if end > height {
+			end = height
+		}
+		subsidy := calcBlockSubsidy(start)
+		if subsidy.Sign() == 0 {
+			break
+		}
+		n := new(big.Int).SetUint64(end - start + 1)
+		total.Add(total, n.Mul(n, subsidy))
+		start = end + 1
+	}
+	return total
+}
+
+// GetStats returns the statistics of the last window blocks of the chain.
+func (bc *BlockChain) GetStats(window uint64) (*ChainStats, error) {
+	head := bc.CurrentBlock()
+	bits, err := bc.CalcNextRequiredDifficulty()
+	if err != nil {
+		return nil, err
+	}
+	if window == 0 {
+		window = DefaultStatsWindow
+	}
+	if window > head.Height() {
+		window = head.Height()
+	}
+	stats := &ChainStats{
+		Height:      head.Height(),
+		Window:      window,
+		NextBits:    bits,
+		Target:      BitsUnzip(bits),
+		Difficulty:  CalcWorkload(bits),
+		HashRate:    new(big.Int),
+		TotalSupply: TotalSubsidy(head.Height()),
+	}
+	if window == 0 {
+		return stats, nil
+	}
+	work := new(big.Int)
+	block := head
+	for i := uint64(0); i < window; i++ {
+		stats.Transactions += uint64(len(block.Transactions))
+		work.Add(work, CalcWorkload(block.Bits()))
+		if block = bc.GetBlockByHash(block.HashPrevBlock()); block == nil {
+			return nil, errNoHeadBlock
+		}
+	}
+	// block is the parent of the window
+	if head.Timestamp() > block.Timestamp() {
+		timespan := head.Timestamp() - block.Timestamp()
+		stats.AvgBlockTime = float64(timespan) / float64(window)
+		stats.HashRate.Div(work, new(big.Int).SetUint64(timespan))
+	}
+	stats.AvgTxsPerBlock = float64(stats.Transactions) / float64(window)
+	return stats, nil
+}
+
+// SyncProgress is the state of the synchronisation of the chain with the peers.
+type SyncProgress struct {
+	Syncing bool
+	// StartHeight is the head height when the last sync started.
+	StartHeight   uint64
+	CurrentHeight uint64
+	// HighestHeight is the highest height of the peers synced with,
+	// at least the current height.
+	HighestHeight uint64
+}
+
+// SyncTracker follows the syncs of the chain by their events.
+type SyncTracker struct {
+	bc       *BlockChain
+	eventBus *EventBus
+	mu       sync.RWMutex
+	progress SyncProgress
+}
+
+func NewSyncTracker(bc *BlockChain, eventBus *EventBus) *SyncTracker {
+	return &SyncTracker{
+		bc:       bc,
+		eventBus: eventBus,
+	}
+}
+
+// Start follows the sync events.
+func (t *SyncTracker) Start() {
+	startSub := t.eventBus.Subscript(SyncStartEvent{})
+	doneSub := t.eventBus.Subscript(SyncDoneEvent{})
+	go func() {
+		for {
+			select {
+			case e := <-startSub.Chan():
+				t.onSyncStart(e.(SyncStartEvent))
+			case <-doneSub.Chan():
+				t.onSyncDone()
+			}
+		}
+	}()
+}
+
+func (t *SyncTracker) onSyncStart(e SyncStartEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Syncing = true
+	t.progress.StartHeight = t.bc.CurrentBlock().Height()
+	if e.PeerHeight > t.progress.HighestHeight {
+		t.progress.HighestHeight = e.PeerHeight
+	}
+}
+
+func (t *SyncTracker) onSyncDone() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Syncing = false
+}
+
+// Progress returns the state of the last sync.
+func (t *SyncTracker) Progress() SyncProgress {
+	t.mu.RLock()
+	progress := t.progress
+	t.mu.RUnlock()
+	progress.CurrentHeight = t.bc.CurrentBlock().Height()
+	if progress.HighestHeight < progress.CurrentHeight {
+		progress.HighestHeight = progress.CurrentHeight
+	}
+	return progress
+}
@@ -0,0 +1,117 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"math/big"
+	"testing"
+	"time"
+	"xfsgo/assert"
+)
+
+func TestTotalSubsidy(t *testing.T) {
+	subsidy := calcBlockSubsidy(1)
+	tests := []struct {
+		height uint64
+		want   *big.Int
+	}{
+		{0, new(big.Int)},
+		{1, subsidy},
+		{subsidyHalvingInterval - 1, new(big.Int).Mul(subsidy, big.NewInt(subsidyHalvingInterval-1))},
+		{subsidyHalvingInterval + 1, new(big.Int).Add(
+			new(big.Int).Mul(subsidy, big.NewInt(subsidyHalvingInterval-1)),
+			new(big.Int).Mul(calcBlockSubsidy(subsidyHalvingInterval), big.NewInt(2)))},
+	}
+	for _, tt := range tests {
+		if got := TotalSubsidy(tt.height); got.Cmp(tt.want) != 0 {
+			t.Errorf("TotalSubsidy(%d) = %s, want %s", tt.height, got, tt.want)
+		}
+	}
+	// the subsidies end
+	if got, want := TotalSubsidy(^uint64(0)), TotalSubsidy(100*subsidyHalvingInterval); got.Cmp(want) != 0 {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestBlockChain_GetStats(t *testing.T) {
+	bc := newTestChain(t)
+	key, to := newTestAccount(t)
+	genesis := bc.CurrentBlock()
+	// the timestamps are 2, 1 and 3 seconds apart
+	b1 := writeTestBlock(t, bc, genesis, []*Transaction{newTestTransfer(t, key, to, 0)})
+	b2 := writeTestBlock(t, bc, b1, nil)
+	writeTestBlock(t, bc, b2, []*Transaction{
+		newTestTransfer(t, key, to, 1), newTestTransfer(t, key, to, 2)})
+
+	stats, err := bc.GetStats(2)
+	assert.Error(t, err)
+	if stats.Height != 3 || stats.Window != 2 || stats.AvgBlockTime != 2 ||
+		stats.Transactions != 2 || stats.AvgTxsPerBlock != 1 {
+		t.Fatalf("got %+v", stats)
+	}
+	bits, err := bc.CalcNextRequiredDifficulty()
+	assert.Error(t, err)
+	if stats.NextBits != bits || stats.Difficulty.Cmp(CalcWorkload(bits)) != 0 {
+		t.Fatalf("got bits %d, difficulty %s", stats.NextBits, stats.Difficulty)
+	}
+	work := new(big.Int).Mul(CalcWorkload(genesis.Bits()), big.NewInt(2))
+	if want := work.Div(work, big.NewInt(4)); stats.HashRate.Cmp(want) != 0 {
+		t.Fatalf("got hash rate %s, want %s", stats.HashRate, want)
+	}
+	if want := TotalSubsidy(3); stats.TotalSupply.Cmp(want) != 0 {
+		t.Fatalf("got total supply %s, want %s", stats.TotalSupply, want)
+	}
+
+	// the window is clamped to the height
+	stats, err = bc.GetStats(100)
+	assert.Error(t, err)
+	if stats.Window != 3 || stats.AvgBlockTime != 2 || stats.Transactions != 3 {
+		t.Fatalf("got %+v", stats)
+	}
+}
+
+func TestSyncTracker(t *testing.T) {
+	bc := newTestChain(t)
+	eventBus := NewEventBus()
+	tracker := NewSyncTracker(bc, eventBus)
+	tracker.Start()
+	waitProgress := func(syncing bool) SyncProgress {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			progress := tracker.Progress()
+			if progress.Syncing == syncing {
+				return progress
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("got %+v, want syncing %v", progress, syncing)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if got := tracker.Progress(); got != (SyncProgress{}) {
+		t.Fatalf("got %+v before any sync", got)
+	}
+	eventBus.Publish(SyncStartEvent{PeerHeight: 10})
+	if got, want := waitProgress(true), (SyncProgress{Syncing: true, HighestHeight: 10}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	mineTestBlock(t, bc)
+	eventBus.Publish(SyncDoneEvent{})
+	if got, want := waitProgress(false), (SyncProgress{CurrentHeight: 1, HighestHeight: 10}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
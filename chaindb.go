@@ -20,31 +20,34 @@ import (
 	"encoding/binary"
 	"xfsgo/common"
 	"xfsgo/common/rawencode"
-	"xfsgo/storage/badger"
+	"xfsgo/storage"
 )
 
 var (
 	blockHashPre = []byte("bh:")
 	blockNumPre  = []byte("bn:")
 	lastBlockKey = []byte("LastBlock")
+	// blockHeightPre indexes every stored block, canonical or not, by height.
+	// key = blockHeightPre+height(8byte)+block_hash(32byte)
+	blockHeightPre = []byte("hb:")
 )
 
 type chainDB struct {
-	storage *badger.Storage
+	storage storage.Database
 }
 
-func newChainDB(db *badger.Storage) *chainDB {
+func newChainDB(db storage.Database) *chainDB {
 	tdb := &chainDB{
 		storage: db,
 	}
 	return tdb
 }
 
-func (db *chainDB) newWriteBatch() *badger.StorageWriteBatch {
+func (db *chainDB) newWriteBatch() storage.WriteBatch {
 	return db.storage.NewWriteBatch()
 }
 
-func (db *chainDB) commitBatch(batch *badger.StorageWriteBatch) error {
+func (db *chainDB) commitBatch(batch storage.WriteBatch) error {
 	return db.storage.CommitWriteBatch(batch)
 }
 
@@ -62,15 +65,41 @@ func (db *chainDB) GetBlockByHash(hash common.Hash) *Block {
 }
 
 func (db *chainDB) GetBlockByNumber(num uint64) *Block {
+	hash, ok := db.getBlockHashByNumber(num)
+	if !ok {
+		return nil
+	}
+	return db.GetBlockByHash(hash)
+}
+
+// getBlockHashByNumber returns the hash of the canonical block at height num.
+func (db *chainDB) getBlockHashByNumber(num uint64) (common.Hash, bool) {
 	var numBuf [8]byte
 	binary.LittleEndian.PutUint64(numBuf[:], num)
 	key := append(blockNumPre, numBuf[:]...)
 	val, err := db.storage.GetData(key)
 	if err != nil {
-		return nil
+		return common.Hash{}, false
 	}
-	hash := common.Bytes2Hash(val)
-	return db.GetBlockByHash(hash)
+	return common.Bytes2Hash(val), true
+}
+
+func blockHeightPrefix(height uint64) []byte {
+	var numBuf [8]byte
+	binary.BigEndian.PutUint64(numBuf[:], height)
+	return append(append([]byte{}, blockHeightPre...), numBuf[:]...)
+}
+
+// getBlockHashesByHeight returns the hashes of the stored blocks at height,
+// the blocks written before the index existed are missing.
+func (db *chainDB) getBlockHashesByHeight(height uint64) []common.Hash {
+	prefix := blockHeightPrefix(height)
+	hashes := make([]common.Hash, 0, 1)
+	_ = db.storage.PrefixForeachData(prefix, func(k []byte, _ []byte) error {
+		hashes = append(hashes, common.Bytes2Hash(k[len(prefix):]))
+		return nil
+	})
+	return hashes
 }
 
 func (db *chainDB) GetHeadBlock() *Block {
@@ -84,31 +113,49 @@ func (db *chainDB) GetHeadBlock() *Block {
 }
 
 func (db *chainDB) WriteBlock(block *Block) error {
+	return storage.Update(db.storage, func(w storage.Writer) error {
+		return db.writeBlock(w, block)
+	})
+}
+
+func (db *chainDB) writeBlock(w storage.Writer, block *Block) error {
 	hash := block.Hash()
 	key := append(blockHashPre, hash.Bytes()...)
 	val, err := rawencode.Encode(block)
 	if err != nil {
 		return err
 	}
-	return db.storage.SetData(key, val)
+	if err = w.Put(key, val); err != nil {
+		return err
+	}
+	return w.Put(append(blockHeightPrefix(block.Height()), hash.Bytes()...), []byte{})
 }
 
 func (db *chainDB) WriteCanonNumber(block *Block) error {
+	return storage.Update(db.storage, func(w storage.Writer) error {
+		return db.writeCanonNumber(w, block)
+	})
+}
+
+func (db *chainDB) writeCanonNumber(w storage.Writer, block *Block) error {
 	var numBuf [8]byte
 	binary.LittleEndian.PutUint64(numBuf[:], block.Height())
 	key := append(blockNumPre, numBuf[:]...)
 	blockHash := block.Hash()
-	err := db.storage.SetData(key, blockHash.Bytes())
-	if err != nil {
-		return err
-	}
-	return nil
+	return w.Put(key, blockHash.Bytes())
 }
 
+// WriteHead makes block the head of the canonical chain.
 func (db *chainDB) WriteHead(block *Block) error {
-	if err := db.WriteCanonNumber(block); err != nil {
+	return storage.Update(db.storage, func(w storage.Writer) error {
+		return db.writeHead(w, block)
+	})
+}
+
+func (db *chainDB) writeHead(w storage.Writer, block *Block) error {
+	if err := db.writeCanonNumber(w, block); err != nil {
 		return err
 	}
 	blockHash := block.Hash()
-	return db.storage.SetData(lastBlockKey, blockHash.Bytes())
+	return w.Put(lastBlockKey, blockHash.Bytes())
 }
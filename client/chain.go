@@ -0,0 +1,169 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"xfsgo"
+	"xfsgo/api"
+	"xfsgo/common"
+	"xfsgo/common/urlsafeb64"
+)
+
+// ChainClient calls the methods of the Chain namespace.
+type ChainClient struct {
+	c *Client
+}
+
+func number(n uint64) json.Number {
+	return json.Number(strconv.FormatUint(n, 10))
+}
+
+// Head returns the head block.
+func (chain *ChainClient) Head(ctx context.Context) (*api.GetBlockByNumberBlock, error) {
+	block := new(api.GetBlockByNumberBlock)
+	if err := chain.c.Call(ctx, "Chain.Head", nil, block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// GetBlockByNumber returns the canonical block at height.
+func (chain *ChainClient) GetBlockByNumber(ctx context.Context, height uint64) (*api.GetBlockByNumberBlock, error) {
+	block := new(api.GetBlockByNumberBlock)
+	args := &api.GetBlockByIdArgs{Number: number(height)}
+	if err := chain.c.Call(ctx, "Chain.GetBlockByNumber", args, block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// GetBlockByHash returns the block with the hash.
+func (chain *ChainClient) GetBlockByHash(ctx context.Context, hash common.Hash) (*api.GetBlockByNumberBlock, error) {
+	block := new(api.GetBlockByNumberBlock)
+	args := &api.GetBlockByHashArgs{Hash: hash.Hex()}
+	if err := chain.c.Call(ctx, "Chain.GetBlockByHash", args, block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// GetBlockHeaderByNumber returns the header of the canonical block at height.
+func (chain *ChainClient) GetBlockHeaderByNumber(ctx context.Context, height uint64) (*api.GetBlockByNumberBlockHeader, error) {
+	header := new(api.GetBlockByNumberBlockHeader)
+	args := &api.GetBlockHeaderByNumberArgs{Height: number(height)}
+	if err := chain.c.Call(ctx, "Chain.GetBlockHeaderByNumber", args, header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// GetBlockHeaderByHash returns the header of the block with the hash.
+func (chain *ChainClient) GetBlockHeaderByHash(ctx context.Context, hash common.Hash) (*api.GetBlockByNumberBlockHeader, error) {
+	header := new(api.GetBlockByNumberBlockHeader)
+	args := &api.GetBlockHeaderByHashArgs{Hash: hash.Hex()}
+	if err := chain.c.Call(ctx, "Chain.GetBlockHeaderByHash", args, header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// GetBlockSection returns count canonical blocks from the height from.
+func (chain *ChainClient) GetBlockSection(ctx context.Context, from, count uint64) (api.GetBlocks, error) {
+	var blocks api.GetBlocks
+	args := &api.GetBlockSectionArgs{From: number(from), Count: number(count)}
+	if err := chain.c.Call(ctx, "Chain.GetBlockSection", args, &blocks); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// GetTransaction returns the transaction with the hash, mined or in the pool
+// of the node, with its status and the block including it.
+func (chain *ChainClient) GetTransaction(ctx context.Context, hash common.Hash) (*api.TransactionInfo, error) {
+	tx := new(api.TransactionInfo)
+	args := &api.GetTransactionArgs{Hash: hash.Hex()}
+	if err := chain.c.Call(ctx, "Chain.GetTransaction", args, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// GetReceiptByHash returns the receipt of the transaction with the hash.
+func (chain *ChainClient) GetReceiptByHash(ctx context.Context, hash common.Hash) (*xfsgo.Receipt, error) {
+	receipt := new(xfsgo.Receipt)
+	args := &api.GetReceiptByHashArgs{Hash: hash.Hex()}
+	if err := chain.c.Call(ctx, "Chain.GetReceiptByHash", args, receipt); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// SendRawTransaction submits a signed transaction, data is its JSON encoding
+// in url safe base64.
+func (chain *ChainClient) SendRawTransaction(ctx context.Context, data string) (*api.TransferObj, error) {
+	tx := new(api.TransferObj)
+	args := &api.SendRawTransactionArgs{Data: data}
+	if err := chain.c.Call(ctx, "Chain.SendRawTransaction", args, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// SendTransaction submits a signed transaction.
+func (chain *ChainClient) SendTransaction(ctx context.Context, tx *xfsgo.Transaction) (*api.TransferObj, error) {
+	data, err := json.Marshal(tx)
+	if err != nil {
+		return nil, err
+	}
+	return chain.SendRawTransaction(ctx, urlsafeb64.Encode(data))
+}
+
+// GetTransactionsByAddress returns a page of the transactions of the address
+// between the heights in args, see api.GetTransactionsByAddressArgs.
+func (chain *ChainClient) GetTransactionsByAddress(ctx context.Context, args *api.GetTransactionsByAddressArgs) (*api.AddressTransactions, error) {
+	txs := new(api.AddressTransactions)
+	if err := chain.c.Call(ctx, "Chain.GetTransactionsByAddress", args, txs); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// GetStats returns the statistics of the last window blocks, the node
+// default when window is 0.
+func (chain *ChainClient) GetStats(ctx context.Context, window uint64) (*api.ChainStats, error) {
+	args := &api.GetStatsArgs{}
+	if window > 0 {
+		args.Window = number(window)
+	}
+	stats := new(api.ChainStats)
+	if err := chain.c.Call(ctx, "Chain.GetStats", args, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// SyncStatus returns the progress of the sync of the node with its peers.
+func (chain *ChainClient) SyncStatus(ctx context.Context) (*api.SyncProgress, error) {
+	progress := new(api.SyncProgress)
+	if err := chain.c.Call(ctx, "Chain.SyncStatus", nil, progress); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+// Package client is a typed client of the JSON-RPC API of an xfsgo node.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"xfsgo"
+)
+
+const (
+	jsonrpcVersion = "2.0"
+	// ipcScheme prefixes the path of the admin socket of a node.
+	ipcScheme = "ipc://"
+)
+
+var (
+	errUnsupportedURL = errors.New("unsupported url scheme")
+	errClosed         = errors.New("client closed")
+	errNoResult       = errors.New("no result in response")
+)
+
+// Config contains the options of a Client.
+type Config struct {
+	// URL of the node: http(s)://, ws(s)://, or ipc:// followed by the
+	// path of the admin socket.
+	URL string
+	// Token is sent as a bearer token to a node with authentication.
+	Token string
+	// HTTPClient sends the HTTP requests, one reusing the connections
+	// is created when nil. Not used on websocket connections.
+	HTTPClient *http.Client
+}
+
+type request struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      uint64      `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *uint64          `json:"id"`
+	Result  json.RawMessage  `json:"result"`
+	Error   *xfsgo.RPCError  `json:"error"`
+	Method  string           `json:"method"`
+	Params  *json.RawMessage `json:"params"`
+}
+
+// transport sends the requests of a client to the node.
+type transport interface {
+	call(ctx context.Context, req *request) (*response, error)
+	close() error
+}
+
+// Client is a client of a node, safe for concurrent use. Its namespaces
+// have the typed methods of the API.
+type Client struct {
+	transport transport
+	ws        *wsTransport
+	lastID    uint64
+
+	Chain  *ChainClient
+	State  *StateClient
+	Wallet *WalletClient
+	Miner  *MinerClient
+	TxPool *TxPoolClient
+}
+
+// Dial connects to the node at url, see Config.
+func Dial(ctx context.Context, url string) (*Client, error) {
+	return DialConfig(ctx, &Config{URL: url})
+}
+
+// DialConfig connects to the node of the config. Websocket connections
+// are opened at once, the HTTP ones on the first call.
+func DialConfig(ctx context.Context, config *Config) (*Client, error) {
+	c := &Client{}
+	switch {
+	case strings.HasPrefix(config.URL, "http://"), strings.HasPrefix(config.URL, "https://"):
+		c.transport = newHTTPTransport(config.URL, config.Token, config.HTTPClient)
+	case strings.HasPrefix(config.URL, ipcScheme):
+		c.transport = newIPCTransport(strings.TrimPrefix(config.URL, ipcScheme), config.Token)
+	case strings.HasPrefix(config.URL, "ws://"), strings.HasPrefix(config.URL, "wss://"):
+		ws, err := dialWebsocket(ctx, config.URL, config.Token)
+		if err != nil {
+			return nil, err
+		}
+		c.transport = ws
+		c.ws = ws
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedURL, config.URL)
+	}
+	c.Chain = &ChainClient{c}
+	c.State = &StateClient{c}
+	c.Wallet = &WalletClient{c}
+	c.Miner = &MinerClient{c}
+	c.TxPool = &TxPoolClient{c}
+	return c, nil
+}
+
+// Close closes the connections of the client.
+func (c *Client) Close() error {
+	return c.transport.close()
+}
+
+func (c *Client) newRequest(method string, params interface{}) *request {
+	return &request{
+		JSONRPC: jsonrpcVersion,
+		ID:      atomic.AddUint64(&c.lastID, 1),
+		Method:  method,
+		Params:  params,
+	}
+}
+
+// Call calls method with params, given as a struct or an array, and decodes
+// its result into out unless nil. Errors of the node are *xfsgo.RPCError.
+func (c *Client) Call(ctx context.Context, method string, params interface{}, out interface{}) error {
+	resp, err := c.transport.call(ctx, c.newRequest(method, params))
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil {
+		return nil
+	}
+	if len(resp.Result) == 0 {
+		return errNoResult
+	}
+	return json.Unmarshal(resp.Result, out)
+}
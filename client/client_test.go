@@ -0,0 +1,199 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"xfsgo"
+	"xfsgo/api"
+	"xfsgo/common"
+)
+
+// testChain serves the Chain methods used by the tests.
+type testChain struct{}
+
+func (*testChain) Head(_ interface{}, block *api.GetBlockByNumberBlock) error {
+	block.Header = &api.GetBlockByNumberBlockHeader{Height: 7, Hash: common.Hex2Hash("0x07")}
+	return nil
+}
+
+func (*testChain) GetBlockByNumber(args api.GetBlockByIdArgs, block *api.GetBlockByNumberBlock) error {
+	height, err := common.Uint64s(args.Number)
+	if err != nil {
+		return xfsgo.NewRPCErrorCause(-32602, err)
+	}
+	if height > 7 {
+		return xfsgo.NewRPCError(-32001, "block not found")
+	}
+	block.Header = &api.GetBlockByNumberBlockHeader{Height: height}
+	return nil
+}
+
+// startTestServer starts an RPC server, it returns its address and admin socket.
+func startTestServer(t *testing.T) (*xfsgo.RPCServer, string, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	dir, err := ioutil.TempDir("", "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	socket := filepath.Join(dir, "admin.sock")
+	server := xfsgo.NewRPCServer(&xfsgo.RPCConfig{ListenAddr: addr, AdminSocket: socket})
+	if err = server.RegisterName("Chain", &testChain{}); err != nil {
+		t.Fatal(err)
+	}
+	server.RegisterTopic(api.TopicNewHeads, "Chain")
+	go func() {
+		_ = server.Start()
+	}()
+	for i := 0; ; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			_ = conn.Close()
+			break
+		}
+		if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return server, addr, socket
+}
+
+func testCalls(t *testing.T, c *Client) {
+	ctx := context.Background()
+	head, err := c.Chain.Head(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Header.Height != 7 || head.Header.Hash != common.Hex2Hash("0x07") {
+		t.Fatalf("got head %+v, want the block 7", head.Header)
+	}
+	block, err := c.Chain.GetBlockByNumber(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Header.Height != 3 {
+		t.Fatalf("got block %d, want 3", block.Header.Height)
+	}
+	_, err = c.Chain.GetBlockByNumber(ctx, 8)
+	var rpcErr *xfsgo.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32001 {
+		t.Fatalf("got err %v, want the error of the node", err)
+	}
+	if err = c.Call(ctx, "Chain.Nothing", nil, nil); !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Fatalf("got err %v, want method not found", err)
+	}
+}
+
+func TestClient_HTTP(t *testing.T) {
+	_, addr, socket := startTestServer(t)
+	for _, url := range []string{"http://" + addr, ipcScheme + socket} {
+		c, err := Dial(context.Background(), url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testCalls(t, c)
+		if err = c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Dial(context.Background(), "ftp://"+addr); !errors.Is(err, errUnsupportedURL) {
+		t.Fatalf("got err %v, want %v", err, errUnsupportedURL)
+	}
+}
+
+func TestClient_RequestID(t *testing.T) {
+	c := &Client{}
+	for want := uint64(1); want <= 3; want++ {
+		if got := c.newRequest("Chain.Head", nil).ID; got != want {
+			t.Fatalf("got id %d, want %d", got, want)
+		}
+	}
+}
+
+func TestClient_WebSocket(t *testing.T) {
+	server, addr, _ := startTestServer(t)
+	ctx := context.Background()
+	c, err := Dial(ctx, "ws://"+addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCalls(t, c)
+
+	heads := make(chan *api.GetBlockByNumberBlockHeader, 1)
+	sub, err := c.SubscribeNewHeads(ctx, heads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = server.Notify(api.TopicNewHeads, &api.GetBlockByNumberBlockHeader{Height: 8}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case head := <-heads:
+		if head.Height != 8 {
+			t.Fatalf("got head %d, want 8", head.Height)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification")
+	}
+	sub.Unsubscribe()
+	if err, ok := <-sub.Err(); ok {
+		t.Fatalf("got err %v after unsubscribing, want a closed channel", err)
+	}
+
+	sub, err = c.SubscribeNewHeads(ctx, heads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-sub.Err(); err != errClosed {
+		t.Fatalf("got err %v, want %v", err, errClosed)
+	}
+	if _, err = c.Chain.Head(ctx); err != errClosed {
+		t.Fatalf("got err %v after closing, want %v", err, errClosed)
+	}
+}
+
+func TestClient_SubscribeHTTP(t *testing.T) {
+	_, addr, _ := startTestServer(t)
+	c, err := Dial(context.Background(), "http://"+addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.SubscribeNewHeads(context.Background(), make(chan *api.GetBlockByNumberBlockHeader))
+	if err == nil || !strings.Contains(err.Error(), "websocket") {
+		t.Fatalf("got err %v, want %v", err, errNotificationsUnsupported)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+)
+
+// httpTransport posts each request, the connections are reused
+// by the http.Client.
+type httpTransport struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newHTTPTransport(url, token string, client *http.Client) *httpTransport {
+	if client == nil {
+		client = &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+	}
+	return &httpTransport{url: url, token: token, client: client}
+}
+
+// newIPCTransport returns a transport posting to the admin socket at path.
+func newIPCTransport(path, token string) *httpTransport {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		},
+	}
+	// the host is not used to connect
+	return newHTTPTransport("http://ipc/", token, client)
+}
+
+func (t *httpTransport) call(ctx context.Context, req *request) (*response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, "POST", t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	if t.token != "" {
+		hreq.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	hresp, err := t.client.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = hresp.Body.Close()
+	}()
+	data, err := ioutil.ReadAll(hresp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp := new(response)
+	// the limits of the server are JSON-RPC errors with an HTTP error status
+	if err = json.Unmarshal(data, resp); err != nil || (resp.Error == nil && hresp.StatusCode != http.StatusOK) {
+		return nil, fmt.Errorf("rpc request err: %s", hresp.Status)
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package client
+
+import "context"
+
+// MinerClient calls the methods of the Miner namespace, which nodes
+// serve on their admin socket.
+type MinerClient struct {
+	c *Client
+}
+
+// Start starts mining.
+func (miner *MinerClient) Start(ctx context.Context) error {
+	return miner.c.Call(ctx, "Miner.Start", nil, nil)
+}
+
+// Stop stops mining.
+func (miner *MinerClient) Stop(ctx context.Context) error {
+	return miner.c.Call(ctx, "Miner.Stop", nil, nil)
+}
+
+// WorkersAdd adds a mining worker.
+func (miner *MinerClient) WorkersAdd(ctx context.Context) error {
+	return miner.c.Call(ctx, "Miner.WorkersAdd", nil, nil)
+}
+
+// WorkersDown removes a mining worker.
+func (miner *MinerClient) WorkersDown(ctx context.Context) error {
+	return miner.c.Call(ctx, "Miner.WorkersDown", nil, nil)
+}
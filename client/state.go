@@ -0,0 +1,82 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package client
+
+import (
+	"context"
+	"math/big"
+	"xfsgo/api"
+	"xfsgo/common"
+)
+
+// StateClient calls the methods of the State namespace. The block of the
+// state is a height, a block hash, api.BlockLatest or api.BlockPending, the
+// latest block when empty.
+type StateClient struct {
+	c *Client
+}
+
+// GetBalance returns the balance of the address.
+func (state *StateClient) GetBalance(ctx context.Context, address common.Address, block string) (*big.Int, error) {
+	balance := new(big.Int)
+	args := &api.GetAccountArgs{Address: address.B58String(), Block: block}
+	if err := state.c.Call(ctx, "State.GetBalance", args, balance); err != nil {
+		return nil, err
+	}
+	return balance, nil
+}
+
+// GetNonce returns the nonce of the address.
+func (state *StateClient) GetNonce(ctx context.Context, address common.Address, block string) (uint64, error) {
+	var nonce uint64
+	args := &api.GetAccountArgs{Address: address.B58String(), Block: block}
+	if err := state.c.Call(ctx, "State.GetNonce", args, &nonce); err != nil {
+		return 0, err
+	}
+	return nonce, nil
+}
+
+// GetStateObj returns the account of the address, in the state with the
+// root hash when not empty.
+func (state *StateClient) GetStateObj(ctx context.Context, address common.Address, rootHash, block string) (*api.StateObj, error) {
+	obj := new(api.StateObj)
+	args := &api.GetStateObjArgs{RootHash: rootHash, Address: address.B58String(), Block: block}
+	if err := state.c.Call(ctx, "State.GetStateObj", args, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// ListAccounts returns a page of the accounts, see api.ListAccountsArgs.
+func (state *StateClient) ListAccounts(ctx context.Context, args *api.ListAccountsArgs) (*api.AccountList, error) {
+	list := new(api.AccountList)
+	if err := state.c.Call(ctx, "State.ListAccounts", args, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetProof returns the account of the address with the proof of its state
+// at the block at height.
+func (state *StateClient) GetProof(ctx context.Context, address common.Address, height uint64) (*api.StateProof, error) {
+	proof := new(api.StateProof)
+	args := &api.GetProofArgs{Address: address.B58String(), Height: number(height)}
+	if err := state.c.Call(ctx, "State.GetProof", args, proof); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"xfsgo/api"
+	"xfsgo/common"
+)
+
+// Subscribe subscribes to the notifications of topic over a websocket
+// connection, handle is called with each of them in order until it
+// returns an error, which ends the subscription.
+func (c *Client) Subscribe(ctx context.Context, topic string, handle func(result json.RawMessage) error) (*Subscription, error) {
+	return c.subscribe(ctx, topic, func(result json.RawMessage, _ <-chan struct{}) error {
+		return handle(result)
+	})
+}
+
+func (c *Client) subscribe(ctx context.Context, topic string, deliver func(result json.RawMessage, quit <-chan struct{}) error) (*Subscription, error) {
+	if c.ws == nil {
+		return nil, errNotificationsUnsupported
+	}
+	return c.ws.subscribe(ctx, c, c.newRequest(subscribeMethod, []string{topic}), deliver)
+}
+
+// SubscribeNewHeads sends the headers of the new head blocks to ch.
+func (c *Client) SubscribeNewHeads(ctx context.Context, ch chan<- *api.GetBlockByNumberBlockHeader) (*Subscription, error) {
+	return c.subscribeHeaders(ctx, api.TopicNewHeads, ch)
+}
+
+// SubscribeMinedBlocks sends the headers of the blocks mined by the node to ch.
+func (c *Client) SubscribeMinedBlocks(ctx context.Context, ch chan<- *api.GetBlockByNumberBlockHeader) (*Subscription, error) {
+	return c.subscribeHeaders(ctx, api.TopicMinedBlocks, ch)
+}
+
+func (c *Client) subscribeHeaders(ctx context.Context, topic string, ch chan<- *api.GetBlockByNumberBlockHeader) (*Subscription, error) {
+	return c.subscribe(ctx, topic, func(result json.RawMessage, quit <-chan struct{}) error {
+		header := new(api.GetBlockByNumberBlockHeader)
+		if err := json.Unmarshal(result, header); err != nil {
+			return err
+		}
+		select {
+		case ch <- header:
+		case <-quit:
+		}
+		return nil
+	})
+}
+
+// SubscribeNewPendingTransactions sends the hashes of the transactions
+// added to the pool of the node to ch.
+func (c *Client) SubscribeNewPendingTransactions(ctx context.Context, ch chan<- common.Hash) (*Subscription, error) {
+	return c.subscribe(ctx, api.TopicNewPendingTransactions, func(result json.RawMessage, quit <-chan struct{}) error {
+		var hash string
+		if err := json.Unmarshal(result, &hash); err != nil {
+			return err
+		}
+		select {
+		case ch <- common.Hex2Hash(hash):
+		case <-quit:
+		}
+		return nil
+	})
+}
+
+// SubscribeSyncStatus sends the starts and ends of the syncs of the node to ch.
+func (c *Client) SubscribeSyncStatus(ctx context.Context, ch chan<- *api.SyncStatus) (*Subscription, error) {
+	return c.subscribe(ctx, api.TopicSyncStatus, func(result json.RawMessage, quit <-chan struct{}) error {
+		status := new(api.SyncStatus)
+		if err := json.Unmarshal(result, status); err != nil {
+			return err
+		}
+		select {
+		case ch <- status:
+		case <-quit:
+		}
+		return nil
+	})
+}
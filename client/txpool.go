@@ -0,0 +1,45 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package client
+
+import (
+	"context"
+	"xfsgo"
+)
+
+// TxPoolClient calls the methods of the TxPool namespace.
+type TxPoolClient struct {
+	c *Client
+}
+
+// GetPending returns the transactions of the pool.
+func (pool *TxPoolClient) GetPending(ctx context.Context) ([]*xfsgo.Transaction, error) {
+	var txs []*xfsgo.Transaction
+	if err := pool.c.Call(ctx, "TxPool.GetPending", nil, &txs); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// GetPendingSize returns the number of transactions in the pool.
+func (pool *TxPoolClient) GetPendingSize(ctx context.Context) (int, error) {
+	var size int
+	if err := pool.c.Call(ctx, "TxPool.GetPendingSize", nil, &size); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package client
+
+import (
+	"context"
+	"xfsgo/api"
+	"xfsgo/common"
+)
+
+// WalletClient calls the methods of the Wallet namespace, which nodes
+// serve on their admin socket.
+type WalletClient struct {
+	c *Client
+}
+
+// Create adds a new key to the wallet and returns its address.
+func (wallet *WalletClient) Create(ctx context.Context) (string, error) {
+	var address string
+	if err := wallet.c.Call(ctx, "Wallet.Create", nil, &address); err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// Del deletes the key of the address.
+func (wallet *WalletClient) Del(ctx context.Context, address common.Address) error {
+	args := &api.GetWalletByAddressArgs{Address: address.B58String()}
+	return wallet.c.Call(ctx, "Wallet.Del", args, nil)
+}
+
+// List returns the addresses of the keys.
+func (wallet *WalletClient) List(ctx context.Context) ([]common.Address, error) {
+	var addresses []common.Address
+	if err := wallet.c.Call(ctx, "Wallet.List", nil, &addresses); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// GetDefaultAddress returns the default address of the wallet.
+func (wallet *WalletClient) GetDefaultAddress(ctx context.Context) (string, error) {
+	var address string
+	if err := wallet.c.Call(ctx, "Wallet.GetDefaultAddress", nil, &address); err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// SetDefaultAddress sets the default address of the wallet.
+func (wallet *WalletClient) SetDefaultAddress(ctx context.Context, address common.Address) error {
+	args := &api.SetDefaultAddrArgs{Address: address.B58String()}
+	return wallet.c.Call(ctx, "Wallet.SetDefaultAddress", args, nil)
+}
+
+// ExportByAddress returns the private key of the address in url safe base64.
+func (wallet *WalletClient) ExportByAddress(ctx context.Context, address common.Address) (string, error) {
+	var key string
+	args := &api.GetWalletByAddressArgs{Address: address.B58String()}
+	if err := wallet.c.Call(ctx, "Wallet.ExportByAddress", args, &key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// ImportByPrivateKey adds the private key, given in url safe base64, and
+// returns its address.
+func (wallet *WalletClient) ImportByPrivateKey(ctx context.Context, key string) (string, error) {
+	var address string
+	args := &api.WalletImportArgs{Key: key}
+	if err := wallet.c.Call(ctx, "Wallet.ImportByPrivateKey", args, &address); err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// Transfer sends value, in decimal, from the default address to the address to.
+func (wallet *WalletClient) Transfer(ctx context.Context, to common.Address, value string) (*api.TransferObj, error) {
+	tx := new(api.TransferObj)
+	args := &api.TransferArgs{To: to.B58String(), Value: value}
+	if err := wallet.c.Call(ctx, "Wallet.Transfer", args, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// TransferFrom sends value, in decimal, from the address from to the address to.
+func (wallet *WalletClient) TransferFrom(ctx context.Context, from, to common.Address, value string) (*api.TransferObj, error) {
+	tx := new(api.TransferObj)
+	args := &api.TransferFromArgs{From: from.B58String(), To: to.B58String(), Value: value}
+	if err := wallet.c.Call(ctx, "Wallet.TransferFrom", args, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
@@ -0,0 +1,320 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	subscribeMethod    = "Subscribe"
+	unsubscribeMethod  = "Unsubscribe"
+	notificationMethod = "subscription"
+
+	// subscriptionBuffer is the number of notifications queued for a
+	// subscription, like the server a subscriber further behind fails.
+	subscriptionBuffer = 256
+	// unsubscribeTimeout limits the call telling the node to stop
+	// sending the notifications of a subscription.
+	unsubscribeTimeout = 10 * time.Second
+)
+
+var (
+	errNotificationsUnsupported = errors.New("notifications not supported, dial a websocket url")
+	errSubscriptionOverflow     = errors.New("subscription queue overflow")
+)
+
+// wsTransport sends the requests over a websocket connection and
+// dispatches the responses by id and the notifications by subscription.
+type wsTransport struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[uint64]chan *response
+	subs    map[string]*Subscription
+	// early holds the notifications received before the response of the
+	// Subscribe call, kept only while such calls are running.
+	early       map[string][]json.RawMessage
+	subscribing int
+	err         error
+	closed      chan struct{}
+}
+
+type notification struct {
+	Subscription string          `json:"subscription"`
+	Result       json.RawMessage `json:"result"`
+}
+
+func dialWebsocket(ctx context.Context, url, token string) (*wsTransport, error) {
+	header := make(http.Header)
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	t := &wsTransport{
+		conn:    conn,
+		pending: make(map[uint64]chan *response),
+		subs:    make(map[string]*Subscription),
+		early:   make(map[string][]json.RawMessage),
+		closed:  make(chan struct{}),
+	}
+	go t.readLoop()
+	return t, nil
+}
+
+func (t *wsTransport) readLoop() {
+	for {
+		_, msg, err := t.conn.ReadMessage()
+		if err != nil {
+			t.fail(err)
+			return
+		}
+		resp := new(response)
+		if err = json.Unmarshal(msg, resp); err != nil {
+			continue
+		}
+		if resp.Method == notificationMethod && resp.Params != nil {
+			n := new(notification)
+			if err = json.Unmarshal(*resp.Params, n); err == nil {
+				t.dispatch(n)
+			}
+			continue
+		}
+		if resp.ID == nil {
+			continue
+		}
+		t.mu.Lock()
+		ch := t.pending[*resp.ID]
+		delete(t.pending, *resp.ID)
+		t.mu.Unlock()
+		if ch != nil {
+			ch <- resp
+		}
+	}
+}
+
+func (t *wsTransport) dispatch(n *notification) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if sub := t.subs[n.Subscription]; sub != nil {
+		sub.push(n.Result)
+		return
+	}
+	if t.subscribing > 0 && len(t.early[n.Subscription]) < subscriptionBuffer {
+		t.early[n.Subscription] = append(t.early[n.Subscription], n.Result)
+	}
+}
+
+// fail ends the pending calls and the subscriptions with err.
+func (t *wsTransport) fail(err error) {
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return
+	}
+	t.err = err
+	close(t.closed)
+	subs := t.subs
+	t.subs = make(map[string]*Subscription)
+	t.mu.Unlock()
+	for _, sub := range subs {
+		sub.end(err)
+	}
+}
+
+func (t *wsTransport) call(ctx context.Context, req *request) (*response, error) {
+	ch := make(chan *response, 1)
+	t.mu.Lock()
+	if t.err != nil {
+		err := t.err
+		t.mu.Unlock()
+		return nil, err
+	}
+	t.pending[req.ID] = ch
+	t.mu.Unlock()
+	removePending := func() {
+		t.mu.Lock()
+		delete(t.pending, req.ID)
+		t.mu.Unlock()
+	}
+	t.writeMu.Lock()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = t.conn.SetWriteDeadline(deadline)
+	} else {
+		_ = t.conn.SetWriteDeadline(time.Time{})
+	}
+	err := t.conn.WriteJSON(req)
+	t.writeMu.Unlock()
+	if err != nil {
+		removePending()
+		return nil, err
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-ctx.Done():
+		removePending()
+		return nil, ctx.Err()
+	case <-t.closed:
+		return nil, t.err
+	}
+}
+
+func (t *wsTransport) close() error {
+	t.fail(errClosed)
+	return t.conn.Close()
+}
+
+// subscribe calls Subscribe with req and starts the subscription,
+// deliver decodes its notifications.
+func (t *wsTransport) subscribe(ctx context.Context, c *Client, req *request, deliver func(result json.RawMessage, quit <-chan struct{}) error) (*Subscription, error) {
+	t.mu.Lock()
+	t.subscribing++
+	t.mu.Unlock()
+	done := func() {
+		t.subscribing--
+		if t.subscribing == 0 {
+			t.early = make(map[string][]json.RawMessage)
+		}
+	}
+	resp, err := t.call(ctx, req)
+	if err == nil && resp.Error != nil {
+		err = resp.Error
+	}
+	var id string
+	if err == nil {
+		err = json.Unmarshal(resp.Result, &id)
+	}
+	if err != nil {
+		t.mu.Lock()
+		done()
+		t.mu.Unlock()
+		return nil, err
+	}
+	sub := &Subscription{
+		client: c,
+		id:     id,
+		queue:  make(chan json.RawMessage, subscriptionBuffer),
+		err:    make(chan error, 1),
+		quit:   make(chan struct{}),
+		decode: deliver,
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer done()
+	if t.err != nil {
+		return nil, t.err
+	}
+	t.subs[id] = sub
+	for _, result := range t.early[id] {
+		sub.push(result)
+	}
+	delete(t.early, id)
+	go sub.loop()
+	return sub, nil
+}
+
+func (t *wsTransport) removeSubscription(id string) {
+	t.mu.Lock()
+	delete(t.subs, id)
+	t.mu.Unlock()
+}
+
+// Subscription delivers the notifications of a topic to a channel.
+type Subscription struct {
+	client *Client
+	id     string
+	queue  chan json.RawMessage
+	err    chan error
+	quit   chan struct{}
+	once   sync.Once
+	decode func(result json.RawMessage, quit <-chan struct{}) error
+}
+
+// ID returns the id of the subscription given by the node.
+func (s *Subscription) ID() string {
+	return s.id
+}
+
+// Err returns a channel receiving the error which ended the subscription,
+// such as a closed connection. It is closed when the subscription ends.
+func (s *Subscription) Err() <-chan error {
+	return s.err
+}
+
+// Unsubscribe stops the notifications of the subscription.
+func (s *Subscription) Unsubscribe() {
+	s.stop(nil)
+}
+
+// stop ends the subscription with err and tells the node to stop it.
+func (s *Subscription) stop(err error) {
+	if s.end(err) {
+		s.client.ws.removeSubscription(s.id)
+		ctx, cancel := context.WithTimeout(context.Background(), unsubscribeTimeout)
+		defer cancel()
+		_ = s.client.Call(ctx, unsubscribeMethod, []string{s.id}, nil)
+	}
+}
+
+// end stops the subscription with err unless it has already ended.
+func (s *Subscription) end(err error) bool {
+	ended := false
+	s.once.Do(func() {
+		if err != nil {
+			s.err <- err
+		}
+		close(s.err)
+		close(s.quit)
+		ended = true
+	})
+	return ended
+}
+
+// push queues a notification, it is called with the lock of the transport.
+func (s *Subscription) push(result json.RawMessage) {
+	select {
+	case s.queue <- result:
+	default:
+		go s.stop(errSubscriptionOverflow)
+	}
+}
+
+func (s *Subscription) loop() {
+	for {
+		select {
+		case result := <-s.queue:
+			if err := s.decode(result, s.quit); err != nil {
+				s.stop(err)
+				return
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
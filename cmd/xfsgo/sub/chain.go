@@ -19,7 +19,11 @@ package sub
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
 	"xfsgo"
+	"xfsgo/backend"
 
 	"github.com/spf13/cobra"
 )
@@ -62,8 +66,223 @@ var (
 		Short: "get receipt <hash>",
 		RunE:  getReceipt,
 	}
+	chainExportCommand = &cobra.Command{
+		Use:   "export <file> [from] [to]",
+		Short: "write the canonical blocks to a file, gzip compressed when it ends with .gz; the daemon must be stopped",
+		RunE:  runChainExport,
+	}
+	chainImportCommand = &cobra.Command{
+		Use:   "import <file>",
+		Short: "validate and insert the blocks of an exported file; the daemon must be stopped",
+		RunE:  runChainImport,
+	}
+	chainTxsCommand = &cobra.Command{
+		Use:   "txs <address>",
+		Short: "print the transactions sent or received by an address as JSON lines, the node needs the address index",
+		RunE:  runChainTxs,
+	}
+	chainStatsCommand = &cobra.Command{
+		Use:   "stats",
+		Short: "print the block time, difficulty, hash rate and supply of the chain",
+		RunE:  runChainStats,
+	}
+	chainSyncStatusCommand = &cobra.Command{
+		Use:   "syncstatus",
+		Short: "print the progress of the sync with the peers",
+		RunE:  runChainSyncStatus,
+	}
+	txsFromHeight uint64
+	txsToHeight   uint64
+	txsLimit      int
+	statsWindow   uint64
 )
 
+// openChain opens the local databases and the block chain stored in them,
+// writing the genesis block of the configured network when they are new.
+func openChain() (*xfsgo.BlockChain, *databases, error) {
+	config, err := parseDaemonConfig(cfgFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	dbs := openDatabases(config.storageParams)
+	backConfig := &backend.Config{
+		Params:  &config.backendParams,
+		ChainDB: dbs.chain,
+		KeysDB:  dbs.keys,
+		StateDB: dbs.state,
+		ExtraDB: dbs.extra,
+	}
+	if err = xfsgo.CheckSchema(&xfsgo.Stores{
+		ChainDB: dbs.chain,
+		KeysDB:  dbs.keys,
+		StateDB: dbs.state,
+		ExtraDB: dbs.extra,
+	}); err != nil {
+		_ = dbs.Close()
+		return nil, nil, err
+	}
+	if err = backend.WriteGenesis(backConfig); err != nil {
+		_ = dbs.Close()
+		return nil, nil, err
+	}
+	bc, err := xfsgo.NewBlockChain(dbs.state, dbs.chain, dbs.extra, xfsgo.NewEventBus())
+	if err != nil {
+		_ = dbs.Close()
+		return nil, nil, err
+	}
+	if config.backendParams.AddressIndex {
+		if err = bc.EnableAddressIndex(); err != nil {
+			_ = dbs.Close()
+			return nil, nil, err
+		}
+	}
+	return bc, dbs, nil
+}
+
+// addressTxsPage is the number of transactions asked at once by chain txs.
+const addressTxsPage = 1000
+
+func runChainTxs(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return cmd.Help()
+	}
+	config, err := parseClientConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	cli := newRPCClient(config)
+	req := &getTransactionsByAddressArgs{
+		Address:    args[0],
+		FromHeight: txsFromHeight,
+		ToHeight:   txsToHeight,
+	}
+	enc := json.NewEncoder(os.Stdout)
+	printed := 0
+	for {
+		req.Limit = addressTxsPage
+		if txsLimit > 0 && txsLimit-printed < req.Limit {
+			req.Limit = txsLimit - printed
+		}
+		var page addressTxList
+		if err = cli.CallMethod(1, "Chain.GetTransactionsByAddress", &req, &page); err != nil {
+			return err
+		}
+		if printed == 0 && page.IndexedFrom > txsFromHeight {
+			fmt.Fprintf(os.Stderr, "blocks before %d are not indexed\n", page.IndexedFrom)
+		}
+		for _, tx := range page.Transactions {
+			if err = enc.Encode(tx); err != nil {
+				return err
+			}
+		}
+		printed += len(page.Transactions)
+		if page.Next == "" || (txsLimit > 0 && printed >= txsLimit) {
+			return nil
+		}
+		req.Cursor = page.Next
+	}
+}
+
+func runChainStats(_ *cobra.Command, _ []string) error {
+	config, err := parseClientConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	cli := newRPCClient(config)
+	req := &getStatsArgs{Window: statsWindow}
+	stats := make(map[string]interface{})
+	if err = cli.CallMethod(1, "Chain.GetStats", &req, &stats); err != nil {
+		return err
+	}
+	jsonStr, err := json.MarshalIndent(stats, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonStr))
+	return nil
+}
+
+func runChainSyncStatus(_ *cobra.Command, _ []string) error {
+	config, err := parseClientConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	cli := newRPCClient(config)
+	status := make(map[string]interface{})
+	if err = cli.CallMethod(1, "Chain.SyncStatus", nil, &status); err != nil {
+		return err
+	}
+	jsonStr, err := json.MarshalIndent(status, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonStr))
+	return nil
+}
+
+func runChainExport(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 || len(args) > 3 {
+		return cmd.Help()
+	}
+	var err error
+	from, to := uint64(0), ^uint64(0)
+	if len(args) > 1 {
+		if from, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+			return fmt.Errorf("invalid from height: %s", args[1])
+		}
+	}
+	if len(args) > 2 {
+		if to, err = strconv.ParseUint(args[2], 10, 64); err != nil {
+			return fmt.Errorf("invalid to height: %s", args[2])
+		}
+	}
+	bc, dbs, err := openChain()
+	if err != nil {
+		return err
+	}
+	defer safeclose(dbs.Close)
+	f, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	defer safeclose(f.Close)
+	n, err := xfsgo.ExportChain(bc, f, from, to, strings.HasSuffix(args[0], ".gz"), func(block *xfsgo.Block) {
+		fmt.Printf("\rexported height: %d", block.Height())
+	})
+	fmt.Println()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported blocks: %d\n", n)
+	return nil
+}
+
+func runChainImport(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return cmd.Help()
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer safeclose(f.Close)
+	bc, dbs, err := openChain()
+	if err != nil {
+		return err
+	}
+	defer safeclose(dbs.Close)
+	n, err := xfsgo.ImportChain(bc, f, func(block *xfsgo.Block) {
+		fmt.Printf("\rimported height: %d", block.Height())
+	})
+	fmt.Println()
+	if err != nil {
+		return err
+	}
+	head := bc.CurrentBlock()
+	fmt.Printf("imported blocks: %d, head height: %d, hash: %s\n", n, head.Height(), head.HashHex())
+	return nil
+}
+
 func getBlockNum(cmd *cobra.Command, args []string) error {
 	if len(args) < 1 {
 		return cmd.Help()
@@ -83,7 +302,7 @@ func getBlockNum(cmd *cobra.Command, args []string) error {
 		CountStr = args[1]
 	}
 
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	receipt := make([]map[string]interface{}, 1)
 	req := &getBlockNumArgs{
 		From:  json.Number(FormStr),
@@ -109,7 +328,7 @@ func getReceipt(cmd *cobra.Command, args []string) error {
 		fmt.Println(err)
 		return nil
 	}
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	receipt := make(map[string]interface{}, 1)
 	req := &getReceiptArgs{
 		Hash: args[0],
@@ -134,7 +353,7 @@ func getTransaction(cmd *cobra.Command, args []string) error {
 		fmt.Println(err)
 		return nil
 	}
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	tran := make(map[string]interface{}, 1)
 	req := &getTransactionArgs{
 		Hash: args[0],
@@ -150,7 +369,7 @@ func getTransaction(cmd *cobra.Command, args []string) error {
 		fmt.Println(err)
 		return err
 	}
-	fmt.Println(jsonStr)
+	fmt.Println(string(jsonStr))
 	return nil
 }
 
@@ -160,7 +379,7 @@ func getBlockHash(cmd *cobra.Command, args []string) error {
 		fmt.Println(err)
 		return nil
 	}
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	block := make(map[string]interface{}, 1)
 	req := &getBlockHashArgs{
 		Address: args[0],
@@ -180,7 +399,7 @@ func getHead() error {
 		fmt.Println(err)
 		return nil
 	}
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	block := make(map[string]interface{}, 1)
 	err = cli.CallMethod(1, "Chain.Head", nil, &block)
 	if err != nil {
@@ -213,5 +432,16 @@ func init() {
 	chainCommand.AddCommand(chainGetTransactionCommand)
 	chainCommand.AddCommand(chainGetReceiptCommand)
 	chainGetBlockCommond.AddCommand(chainGetBlockHashCommond)
+	chainCommand.AddCommand(chainExportCommand)
+	chainCommand.AddCommand(chainImportCommand)
+	mFlags := chainTxsCommand.Flags()
+	mFlags.Uint64Var(&txsFromHeight, "from", 0, "first block height")
+	mFlags.Uint64Var(&txsToHeight, "to", 0, "last block height (default the head)")
+	mFlags.IntVar(&txsLimit, "limit", 0, "maximum number of transactions, all when 0")
+	chainCommand.AddCommand(chainTxsCommand)
+	chainStatsCommand.Flags().Uint64Var(&statsWindow, "window", 0,
+		fmt.Sprintf("number of recent blocks averaged (default %d)", xfsgo.DefaultStatsWindow))
+	chainCommand.AddCommand(chainStatsCommand)
+	chainCommand.AddCommand(chainSyncStatusCommand)
 
 }
@@ -18,10 +18,12 @@ package sub
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 	"xfsgo"
 	"xfsgo/backend"
 	"xfsgo/common"
@@ -40,6 +42,10 @@ const (
 	defaultKeysDir           = "keys"
 	defaultExtraDir          = "extra"
 	defaultNodesDir          = "nodes"
+	defaultDBDir             = "db"
+	defaultAdminSocket       = "admin.sock"
+	ipcScheme                = "ipc://"
+	ipcDialTimeout           = 200 * time.Millisecond
 	defaultRPCClientAPIHost  = "127.0.0.1:9002"
 	defaultNodeRPCListenAddr = "127.0.0.1:9001"
 	defaultNodeP2PListenAddr = "127.0.0.1:9002"
@@ -55,8 +61,16 @@ type storageParams struct {
 	stateDir string
 	extraDir string
 	nodesDir string
+	// singleDB hosts the chain, keys, state and extra tables in one
+	// database at dbDir instead of one database per directory.
+	singleDB bool
+	dbDir    string
 }
 
+// defaultRPCModules are the namespaces served on the RPC listen address,
+// the others are only served on the admin socket.
+var defaultRPCModules = []string{"Chain", "State", "TxPool"}
+
 type loggerParams struct {
 	level string
 }
@@ -70,6 +84,7 @@ type daemonConfig struct {
 
 type clientConfig struct {
 	rpcClientApiHost string
+	rpcClientToken   string
 }
 
 func readFromConfigPath(v *viper.Viper, customFile string) error {
@@ -97,19 +112,35 @@ func parseConfigLoggerParams(v *viper.Viper) loggerParams {
 	return params
 }
 
+func configDataDir(v *viper.Viper) string {
+	dataDir := v.GetString("storage.datadir")
+	if dataDir == "" {
+		home := os.Getenv("HOME")
+		dataDir = path.Join(home, defaultStorageDir)
+	}
+	return dataDir
+}
+
+// configAdminSocket returns the path of the admin socket of the daemon,
+// which is its IPC endpoint.
+func configAdminSocket(v *viper.Viper) string {
+	socket := v.GetString("rpcserver.adminsocket")
+	if socket == "" {
+		socket = path.Join(configDataDir(v), defaultAdminSocket)
+	}
+	return socket
+}
+
 func parseConfigStorageParams(v *viper.Viper) storageParams {
 	storageParams := storageParams{}
-	storageParams.dataDir = v.GetString("storage.datadir")
+	storageParams.dataDir = configDataDir(v)
 	storageParams.chainDir = v.GetString("storage.chaindir")
 	storageParams.stateDir = v.GetString("storage.statedir")
 	storageParams.keysDir = v.GetString("storage.keysdir")
 	storageParams.extraDir = v.GetString("storage.extradir")
 	storageParams.nodesDir = v.GetString("storage.nodesdir")
-	if storageParams.dataDir == "" {
-		home := os.Getenv("HOME")
-		storageParams.dataDir = path.Join(
-			home, defaultStorageDir)
-	}
+	storageParams.singleDB = v.GetBool("storage.singledb")
+	storageParams.dbDir = v.GetString("storage.dbdir")
 	if storageParams.chainDir == "" {
 		storageParams.chainDir = path.Join(
 			storageParams.dataDir, defaultChainDir)
@@ -130,11 +161,18 @@ func parseConfigStorageParams(v *viper.Viper) storageParams {
 		storageParams.nodesDir = path.Join(
 			storageParams.dataDir, defaultNodesDir)
 	}
+	if storageParams.dbDir == "" {
+		storageParams.dbDir = path.Join(
+			storageParams.dataDir, defaultDBDir)
+	}
 	logrus.Infof("chainDir: %s", storageParams.chainDir)
 	logrus.Infof("stateDir: %s", storageParams.stateDir)
 	logrus.Infof("keysDir: %s", storageParams.keysDir)
 	logrus.Infof("extraDir: %s", storageParams.extraDir)
 	logrus.Infof("nodesDir: %s", storageParams.nodesDir)
+	if storageParams.singleDB {
+		logrus.Infof("dbDir: %s", storageParams.dbDir)
+	}
 	return storageParams
 }
 func defaultBootstrapNodes(netid uint32) []string {
@@ -153,13 +191,33 @@ func parseConfigNodeParams(v *viper.Viper, netid uint32) node.Config {
 		RPCConfig: new(xfsgo.RPCConfig),
 	}
 	config.RPCConfig.ListenAddr = v.GetString("rpcserver.listen")
+	config.RPCConfig.HTTPModules = v.GetStringSlice("rpcserver.http.modules")
+	config.RPCConfig.WSModules = v.GetStringSlice("rpcserver.ws.modules")
+	config.RPCConfig.JWTSecret = []byte(v.GetString("rpcserver.auth.jwtsecret"))
+	config.RPCConfig.Tokens = v.GetStringSlice("rpcserver.auth.tokens")
+	config.RPCConfig.AdminSocket = configAdminSocket(v)
+	config.RPCConfig.MaxBodySize = v.GetInt64("rpcserver.limits.bodysize")
+	config.RPCConfig.MaxFrameSize = v.GetInt64("rpcserver.limits.framesize")
+	config.RPCConfig.MethodTimeout = v.GetDuration("rpcserver.limits.timeout")
+	config.RPCConfig.MaxConcurrency = v.GetInt("rpcserver.limits.concurrency")
+	config.RPCConfig.MaxBatchSize = v.GetInt("rpcserver.limits.batchsize")
+	config.RPCConfig.RateLimit = v.GetFloat64("rpcserver.limits.ratelimit")
+	config.RPCConfig.RateBurst = v.GetInt("rpcserver.limits.burst")
+	config.RPCConfig.TrustedProxies = v.GetStringSlice("rpcserver.trustedproxies")
 	config.P2PListenAddress = v.GetString("p2pnode.listen")
 	config.P2PBootstraps = v.GetStringSlice("p2pnode.bootstrap")
 	config.P2PStaticNodes = v.GetStringSlice("p2pnode.static")
+	config.P2PDisableCompression = v.GetBool("p2pnode.nocompression")
 	config.ProtocolVersion = uint8(v.GetUint64("protocol.version"))
 	if config.RPCConfig.ListenAddr == "" {
 		config.RPCConfig.ListenAddr = defaultNodeRPCListenAddr
 	}
+	if len(config.RPCConfig.HTTPModules) == 0 {
+		config.RPCConfig.HTTPModules = defaultRPCModules
+	}
+	if len(config.RPCConfig.WSModules) == 0 {
+		config.RPCConfig.WSModules = defaultRPCModules
+	}
 	if config.P2PListenAddress == "" {
 		config.P2PListenAddress = defaultNodeP2PListenAddr
 	}
@@ -177,6 +235,9 @@ func parseConfigBackendParams(v *viper.Viper) backend.Params {
 	}
 	config.ProtocolVersion = v.GetUint32("protocol.version")
 	config.NetworkID = v.GetUint32("protocol.networkid")
+	config.StateKeepBlocks = v.GetUint64("storage.statekeepblocks")
+	config.StateArchive = v.GetBool("storage.statearchive")
+	config.AddressIndex = v.GetBool("storage.addressindex")
 	if config.ProtocolVersion == 0 {
 		config.ProtocolVersion = defaultProtocolVersion
 	}
@@ -213,7 +274,56 @@ func parseClientConfig(configFilePath string) (clientConfig, error) {
 	if mRpcClientApiHost == "" {
 		mRpcClientApiHost = defaultRPCClientAPIHost
 	}
+	// prefer the IPC endpoint of a daemon running on this host, unless
+	// apihost names a remote node and the user did not opt in
+	preferIPC := isLoopbackHost(mRpcClientApiHost) || config.GetBool("rpclient.preferipc")
+	if preferIPC && !config.GetBool("rpclient.noipc") && !strings.HasPrefix(mRpcClientApiHost, ipcScheme) {
+		if socket := configAdminSocket(config); ipcAvailable(socket) {
+			mRpcClientApiHost = ipcScheme + socket
+		}
+	}
 	return clientConfig{
 		rpcClientApiHost: mRpcClientApiHost,
+		rpcClientToken:   config.GetString("rpclient.token"),
 	}, nil
 }
+
+// isLoopbackHost reports whether the API host is on this host.
+func isLoopbackHost(apiHost string) bool {
+	if i := strings.Index(apiHost, "://"); i >= 0 {
+		apiHost = apiHost[i+3:]
+	}
+	if i := strings.IndexAny(apiHost, "/?"); i >= 0 {
+		apiHost = apiHost[:i]
+	}
+	if host, _, err := net.SplitHostPort(apiHost); err == nil {
+		apiHost = host
+	}
+	apiHost = strings.Trim(apiHost, "[]")
+	if strings.EqualFold(apiHost, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(apiHost)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ipcAvailable reports whether a daemon accepts connections on socket.
+func ipcAvailable(socket string) bool {
+	info, err := os.Stat(socket)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return false
+	}
+	conn, err := net.DialTimeout("unix", socket, ipcDialTimeout)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// newRPCClient returns a client of the configured RPC server.
+func newRPCClient(config clientConfig) *xfsgo.Client {
+	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli.SetToken(config.rpcClientToken)
+	return cli
+}
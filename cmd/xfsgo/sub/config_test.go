@@ -17,6 +17,8 @@
 package sub
 
 import (
+	"net"
+	"path/filepath"
 	"testing"
 	"xfsgo/assert"
 )
@@ -26,3 +28,35 @@ func TestParse(t *testing.T) {
 	assert.Error(t, err)
 	_ = mConfig
 }
+
+func TestIPCAvailable(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	if ipcAvailable(socket) {
+		t.Fatalf("missing socket available")
+	}
+	listener, err := net.Listen("unix", socket)
+	assert.Error(t, err)
+	if !ipcAvailable(socket) {
+		t.Fatalf("listening socket not available")
+	}
+	_ = listener.Close()
+	if ipcAvailable(socket) {
+		t.Fatalf("closed socket available")
+	}
+}
+
+func TestIsLoopbackHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		defaultRPCClientAPIHost:       true,
+		"http://127.0.0.1:9001":       true,
+		"http://localhost:9001/":      true,
+		"ws://[::1]:9001":             true,
+		"http://10.0.0.5:9001":        false,
+		"https://node.example.com":    false,
+		"http://192.168.1.2:9001/rpc": false,
+	} {
+		if got := isLoopbackHost(host); got != want {
+			t.Fatalf("isLoopbackHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
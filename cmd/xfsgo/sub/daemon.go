@@ -23,6 +23,7 @@ import (
 	"syscall"
 	"xfsgo/backend"
 	"xfsgo/node"
+	"xfsgo/storage"
 	"xfsgo/storage/badger"
 
 	"github.com/spf13/cobra"
@@ -43,6 +44,53 @@ func safeclose(fn func() error) {
 		panic(err)
 	}
 }
+
+// databases are the stores of the node, either one badger database each
+// or tables of a single one.
+type databases struct {
+	chain storage.Database
+	keys  storage.Database
+	state storage.Database
+	extra storage.Database
+	dbs   []*badger.Storage
+}
+
+func openDatabases(params storageParams) *databases {
+	if params.singleDB {
+		db := badger.New(params.dbDir)
+		return &databases{
+			chain: storage.NewTable(db, "chain:"),
+			keys:  storage.NewTable(db, "keys:"),
+			state: storage.NewTable(db, "state:"),
+			extra: storage.NewTable(db, "extra:"),
+			dbs:   []*badger.Storage{db},
+		}
+	}
+	dbs := &databases{}
+	for _, item := range []struct {
+		db  *storage.Database
+		dir string
+	}{
+		{&dbs.chain, params.chainDir},
+		{&dbs.keys, params.keysDir},
+		{&dbs.state, params.stateDir},
+		{&dbs.extra, params.extraDir},
+	} {
+		db := badger.New(item.dir)
+		*item.db = db
+		dbs.dbs = append(dbs.dbs, db)
+	}
+	return dbs
+}
+
+func (dbs *databases) Close() error {
+	for _, db := range dbs.dbs {
+		if err := db.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 func runDaemon() error {
 	var (
 		err   error            = nil
@@ -61,22 +109,14 @@ func runDaemon() error {
 	if stack, err = node.New(&config.nodeConfig); err != nil {
 		return err
 	}
-	chainDb := badger.New(config.storageParams.chainDir)
-	keysDb := badger.New(config.storageParams.keysDir)
-	stateDB := badger.New(config.storageParams.stateDir)
-	extraDB := badger.New(config.storageParams.extraDir)
-	defer func() {
-		safeclose(chainDb.Close)
-		safeclose(keysDb.Close)
-		safeclose(stateDB.Close)
-		safeclose(extraDB.Close)
-	}()
+	dbs := openDatabases(config.storageParams)
+	defer safeclose(dbs.Close)
 	if back, err = backend.NewBackend(stack, &backend.Config{
 		Params:  &config.backendParams,
-		ChainDB: chainDb,
-		KeysDB:  keysDb,
-		StateDB: stateDB,
-		ExtraDB: extraDB,
+		ChainDB: dbs.chain,
+		KeysDB:  dbs.keys,
+		StateDB: dbs.state,
+		ExtraDB: dbs.extra,
 	}); err != nil {
 		return err
 	}
@@ -0,0 +1,158 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package sub
+
+import (
+	"fmt"
+	"xfsgo"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbCommand = &cobra.Command{
+		Use:   "db",
+		Short: "maintain the local databases, the daemon must be stopped",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	dbConvertCommand = &cobra.Command{
+		Use:   "convert",
+		Short: "convert blocks and transactions stored as JSON to the binary encoding",
+		RunE:  runDBConvert,
+	}
+	dbPruneStateCommand = &cobra.Command{
+		Use:   "prune-state",
+		Short: "delete the world state of the blocks before the last kept ones",
+		RunE:  runDBPruneState,
+	}
+	dbMigrateCommand = &cobra.Command{
+		Use:   "migrate",
+		Short: "upgrade the database to the schema version of this program",
+		RunE:  runDBMigrate,
+	}
+	dbRebuildAddressIndexCommand = &cobra.Command{
+		Use:   "rebuild-address-index",
+		Short: "index the transactions of the stored blocks by address",
+		RunE:  runDBRebuildAddressIndex,
+	}
+	pruneStateKeep uint64
+	migrateDryRun  bool
+)
+
+func runDBConvert(_ *cobra.Command, _ []string) error {
+	config, err := parseDaemonConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	dbs := openDatabases(config.storageParams)
+	defer safeclose(dbs.Close)
+	n, err := xfsgo.ConvertLegacyEncoding(dbs.chain, dbs.extra)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("converted blocks: %d\n", n)
+	return nil
+}
+
+func runDBMigrate(_ *cobra.Command, _ []string) error {
+	config, err := parseDaemonConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	dbs := openDatabases(config.storageParams)
+	defer safeclose(dbs.Close)
+	stores := &xfsgo.Stores{
+		ChainDB: dbs.chain,
+		KeysDB:  dbs.keys,
+		StateDB: dbs.state,
+		ExtraDB: dbs.extra,
+	}
+	version, err := xfsgo.ReadSchemaVersion(stores)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("schema version: %d, current: %d\n", version, xfsgo.SchemaVersion)
+	return xfsgo.Migrate(stores, migrateDryRun, func(m *xfsgo.Migration, n int) {
+		items := "unknown"
+		if n >= 0 {
+			items = fmt.Sprintf("%d", n)
+		}
+		if migrateDryRun {
+			fmt.Printf("would migrate to version %d: %s, items: %s\n", m.Version, m.Name, items)
+		} else {
+			fmt.Printf("migrated to version %d: %s, items: %s\n", m.Version, m.Name, items)
+		}
+	})
+}
+
+func runDBPruneState(_ *cobra.Command, _ []string) error {
+	config, err := parseDaemonConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	if config.backendParams.StateArchive {
+		return fmt.Errorf("state archive mode is enabled in the config")
+	}
+	keep := pruneStateKeep
+	if keep == 0 {
+		keep = config.backendParams.StateKeepBlocks
+	}
+	dbs := openDatabases(config.storageParams)
+	defer safeclose(dbs.Close)
+	n, err := xfsgo.PruneStateDB(dbs.state, dbs.chain, keep)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("deleted state nodes: %d\n", n)
+	return nil
+}
+
+func runDBRebuildAddressIndex(_ *cobra.Command, _ []string) error {
+	config, err := parseDaemonConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	dbs := openDatabases(config.storageParams)
+	defer safeclose(dbs.Close)
+	n, err := xfsgo.RebuildAddressIndex(dbs.chain, dbs.extra, func(block *xfsgo.Block) {
+		fmt.Printf("\rindexed height: %d", block.Height())
+	})
+	fmt.Println()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("indexed blocks: %d\n", n)
+	if !config.backendParams.AddressIndex {
+		fmt.Println("set storage.addressindex to keep the index up to date")
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(dbCommand)
+	dbCommand.AddCommand(dbConvertCommand)
+	mFlags := dbPruneStateCommand.Flags()
+	mFlags.Uint64Var(&pruneStateKeep, "keep", 0,
+		fmt.Sprintf("number of recent blocks whose state is kept (default %d)", xfsgo.DefaultStateKeepBlocks))
+	dbCommand.AddCommand(dbPruneStateCommand)
+	mFlags = dbMigrateCommand.Flags()
+	mFlags.BoolVar(&migrateDryRun, "dry-run", false, "list the pending migrations without writing")
+	dbCommand.AddCommand(dbMigrateCommand)
+	dbCommand.AddCommand(dbRebuildAddressIndexCommand)
+}
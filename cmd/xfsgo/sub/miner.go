@@ -18,7 +18,6 @@ package sub
 
 import (
 	"fmt"
-	"xfsgo"
 
 	"github.com/spf13/cobra"
 )
@@ -52,7 +51,7 @@ func runMinerStart(_ *cobra.Command, _ []string) error {
 		return err
 	}
 	var res *string = nil
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	if err = cli.CallMethod(1, "Miner.Start", nil, &res); err != nil {
 		return nil
 	}
@@ -66,7 +65,7 @@ func runMinerStop(_ *cobra.Command, _ []string) error {
 		return err
 	}
 	var res *string = nil
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	err = cli.CallMethod(1, "Miner.Stop", nil, &res)
 	if err != nil {
 		fmt.Println(err.Error())
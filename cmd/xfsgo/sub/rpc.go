@@ -0,0 +1,76 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package sub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"xfsgo"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rpcCommand = &cobra.Command{
+		Use:   "rpc",
+		Short: "inspect the RPC server",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	rpcMethodsCommand = &cobra.Command{
+		Use:   "methods",
+		Short: "list the methods served to this client with their params",
+		RunE:  runRPCMethods,
+	}
+	rpcMethodsJSON bool
+)
+
+func runRPCMethods(_ *cobra.Command, _ []string) error {
+	config, err := parseClientConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	cli := newRPCClient(config)
+	doc := new(xfsgo.OpenRPCDoc)
+	if err = cli.CallMethod(1, "rpc.discover", nil, doc); err != nil {
+		return err
+	}
+	if rpcMethodsJSON {
+		out, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	for _, m := range doc.Methods {
+		params := make([]string, len(m.Params))
+		for i, p := range m.Params {
+			params[i] = p.Name
+		}
+		fmt.Printf("%s(%s)\n", m.Name, strings.Join(params, ", "))
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(rpcCommand)
+	rpcMethodsCommand.Flags().BoolVar(&rpcMethodsJSON, "json", false, "print the OpenRPC document")
+	rpcCommand.AddCommand(rpcMethodsCommand)
+}
@@ -17,9 +17,12 @@
 package sub
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
-	"xfsgo"
+	"math/big"
+	"os"
+	"strconv"
 
 	"github.com/spf13/cobra"
 )
@@ -33,14 +36,32 @@ var (
 		},
 	}
 	getStateObjCommand = &cobra.Command{
-		Use:   "getstateobj <roothash> <address>",
-		Short: "get state object  <roothash>  <address>",
+		Use:   "getstateobj [roothash] <address>",
+		Short: "get state object  [roothash]  <address>",
 		RunE:  getStateObj,
 	}
+	getStateBalanceCommand = &cobra.Command{
+		Use:   "getbalance <address>",
+		Short: "get balance of the account <address>",
+		RunE:  getStateBalance,
+	}
+	getStateNonceCommand = &cobra.Command{
+		Use:   "getnonce <address>",
+		Short: "get nonce of the account <address>",
+		RunE:  getStateNonce,
+	}
+	getStateDumpCommand = &cobra.Command{
+		Use:   "dump",
+		Short: "print every account of the state as JSON lines",
+		RunE:  dumpState,
+	}
+	dumpHeight uint64
+	// stateBlock selects the state read by the commands, see api.GetAccountArgs.
+	stateBlock string
 )
 
 func getStateObj(cmd *cobra.Command, args []string) error {
-	if len(args) != 2 {
+	if len(args) != 1 && len(args) != 2 {
 		return cmd.Help()
 	}
 
@@ -49,11 +70,14 @@ func getStateObj(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	balance := make(map[string]interface{}, 1)
 	req := &getStateObjArgs{
-		RootHash: args[0],
-		Address:  args[1],
+		Address: args[len(args)-1],
+		Block:   stateBlock,
+	}
+	if len(args) == 2 {
+		req.RootHash = args[0]
 	}
 	err = cli.CallMethod(1, "State.GetStateObj", &req, &balance)
 	if err != nil {
@@ -80,7 +104,96 @@ func getStateObj(cmd *cobra.Command, args []string) error {
 
 }
 
+func getStateBalance(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return cmd.Help()
+	}
+	config, err := parseClientConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	cli := newRPCClient(config)
+	req := &getAccountArgs{
+		Address: args[0],
+		Block:   stateBlock,
+	}
+	var balance *big.Int
+	if err = cli.CallMethod(1, "State.GetBalance", &req, &balance); err != nil {
+		fmt.Println(err)
+		return err
+	}
+	fmt.Println(balance)
+	return nil
+}
+
+func getStateNonce(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return cmd.Help()
+	}
+	config, err := parseClientConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	cli := newRPCClient(config)
+	req := &getAccountArgs{
+		Address: args[0],
+		Block:   stateBlock,
+	}
+	var nonce uint64
+	if err = cli.CallMethod(1, "State.GetNonce", &req, &nonce); err != nil {
+		fmt.Println(err)
+		return err
+	}
+	fmt.Println(nonce)
+	return nil
+}
+
+func dumpState(cmd *cobra.Command, _ []string) error {
+	config, err := parseClientConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	cli := newRPCClient(config)
+	req := &listAccountsArgs{
+		Block: stateBlock,
+		Limit: 1000,
+	}
+	// the pages must come from the same state
+	if cmd.Flags().Changed("height") {
+		req.Block = strconv.FormatUint(dumpHeight, 10)
+	} else if req.Block == "" || req.Block == "latest" {
+		head := make(map[string]interface{})
+		if err = cli.CallMethod(1, "Chain.Head", nil, &head); err != nil {
+			return err
+		}
+		req.Block = head["header"].(map[string]interface{})["hash"].(string)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		var page accountList
+		if err = cli.CallMethod(1, "State.ListAccounts", &req, &page); err != nil {
+			return err
+		}
+		for _, account := range page.Accounts {
+			if err = enc.Encode(account); err != nil {
+				return err
+			}
+		}
+		if page.Next == "" {
+			return nil
+		}
+		req.Start = page.Next
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(getStateCommand)
+	mFlags := getStateCommand.PersistentFlags()
+	mFlags.StringVarP(&stateBlock, "block", "b", "",
+		"state after the block: latest, pending, a height or a block hash (default latest)")
 	getStateCommand.AddCommand(getStateObjCommand)
+	getStateCommand.AddCommand(getStateBalanceCommand)
+	getStateCommand.AddCommand(getStateNonceCommand)
+	getStateDumpCommand.Flags().Uint64Var(&dumpHeight, "height", 0, "dump the state after the block at height")
+	getStateCommand.AddCommand(getStateDumpCommand)
 }
@@ -54,7 +54,7 @@ func runTxPoolList() error {
 		fmt.Println(err)
 		return err
 	}
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	tran := make([]xfsgo.Transaction, 1)
 	err = cli.CallMethod(1, "TxPool.GetPending", nil, &tran)
 	if err != nil {
@@ -76,7 +76,7 @@ func runTxPoolCount() error {
 		fmt.Println(err)
 		return err
 	}
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	var txPoolCount int
 	err = cli.CallMethod(1, "TxPool.GetPendingSize", nil, &txPoolCount)
 	if err != nil {
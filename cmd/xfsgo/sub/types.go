@@ -33,6 +33,45 @@ type getReceiptArgs struct {
 type getStateObjArgs struct {
 	RootHash string `json:"root_hash"`
 	Address  string `json:"address"`
+	Block    string `json:"block,omitempty"`
+}
+
+type listAccountsArgs struct {
+	Block string `json:"block,omitempty"`
+	Start string `json:"start,omitempty"`
+	Limit int    `json:"limit"`
+}
+
+type getStatsArgs struct {
+	Window uint64 `json:"window,omitempty"`
+}
+
+type getTransactionsByAddressArgs struct {
+	Address    string `json:"address"`
+	FromHeight uint64 `json:"from_height"`
+	ToHeight   uint64 `json:"to_height,omitempty"`
+	Limit      int    `json:"limit"`
+	Cursor     string `json:"cursor,omitempty"`
+}
+
+type addressTxList struct {
+	Transactions []json.RawMessage `json:"transactions"`
+	Next         string            `json:"next"`
+	IndexedFrom  uint64            `json:"indexed_from"`
+}
+
+type accountList struct {
+	Accounts []struct {
+		Address string `json:"address"`
+		Balance string `json:"balance"`
+		Nonce   uint64 `json:"nonce"`
+	} `json:"accounts"`
+	Next string `json:"next"`
+}
+
+type getAccountArgs struct {
+	Address string `json:"address"`
+	Block   string `json:"block,omitempty"`
 }
 
 type getWalletByAddressArgs struct {
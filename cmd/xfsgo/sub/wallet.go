@@ -20,7 +20,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
-	"xfsgo"
 	"xfsgo/common"
 
 	"github.com/spf13/cobra"
@@ -91,7 +90,7 @@ func runWalletTransfer(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	result := make(map[string]interface{}, 1)
 	req := &transferFromArgs{
 		From:  args[0],
@@ -119,7 +118,7 @@ func runWalletNew() error {
 	if err != nil {
 		return err
 	}
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	var addr *string = nil
 	err = cli.CallMethod(1, "Wallet.Create", nil, &addr)
 	if err != nil {
@@ -139,7 +138,7 @@ func runWalletDel(cmd *cobra.Command, args []string) error {
 	addrq := &getWalletByAddressArgs{
 		Address: addr,
 	}
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	var r *interface{} = nil
 	err = cli.CallMethod(1, "Wallet.Del", addrq, &r)
 	if err != nil {
@@ -159,7 +158,7 @@ func runWalletExport(cmd *cobra.Command, args []string) error {
 	addrq := &getWalletByAddressArgs{
 		Address: addr,
 	}
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	var r *string = nil
 	err = cli.CallMethod(1, "Wallet.ExportByAddress", addrq, &r)
 	if err != nil {
@@ -180,7 +179,7 @@ func runWalletImport(cmd *cobra.Command, args []string) error {
 	importrq := &walletImportArgs{
 		Key: addr,
 	}
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	var r *string = nil
 	err = cli.CallMethod(1, "Wallet.ImportByPrivateKey", importrq, &r)
 	if err != nil {
@@ -196,7 +195,7 @@ func setWalletAddrDef(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	addr := args[0]
 	req := &setWalletAddrDefArgs{
 		Address: addr,
@@ -216,7 +215,7 @@ func getWalletAddrDef() error {
 	if err != nil {
 		return err
 	}
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	var defStr *string = nil
 	err = cli.CallMethod(1, "Wallet.GetDefaultAddress", nil, &defStr)
 	if err != nil {
@@ -234,7 +233,7 @@ func runWalletList() error {
 	}
 	//Get wallet default address
 	var defAddr common.Address
-	cli := xfsgo.NewClient(config.rpcClientApiHost)
+	cli := newRPCClient(config)
 	err = cli.CallMethod(1, "Wallet.GetDefaultAddress", nil, &defAddr)
 	if err != nil {
 		fmt.Println(err)
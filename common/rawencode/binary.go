@@ -0,0 +1,215 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package rawencode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+var (
+	ErrShortData     = errors.New("binary data too short")
+	ErrTrailingData  = errors.New("binary data has trailing bytes")
+	ErrNonCanonical  = errors.New("binary data not canonical")
+	ErrFormatVersion = errors.New("unknown binary format version")
+)
+
+// IsLegacyJSON reports whether data was written by the former JSON encoding.
+// Binary encodings start with a format version byte and never with '{' or '['.
+func IsLegacyJSON(data []byte) bool {
+	return len(data) > 0 && (data[0] == '{' || data[0] == '[')
+}
+
+// BinaryWriter builds canonical binary encodings. Integers are written
+// big endian with a fixed width, variable length fields are prefixed
+// by their length as a uint32, so every value has exactly one encoding.
+type BinaryWriter struct {
+	buf bytes.Buffer
+}
+
+func NewBinaryWriter() *BinaryWriter {
+	return &BinaryWriter{}
+}
+
+func (w *BinaryWriter) WriteUint8(v uint8) {
+	w.buf.WriteByte(v)
+}
+
+func (w *BinaryWriter) WriteUint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	w.buf.Write(b[:])
+}
+
+func (w *BinaryWriter) WriteUint64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	w.buf.Write(b[:])
+}
+
+func (w *BinaryWriter) WriteInt32(v int32) {
+	w.WriteUint32(uint32(v))
+}
+
+// WriteFixed writes b as is, the reader must know its length.
+func (w *BinaryWriter) WriteFixed(b []byte) {
+	w.buf.Write(b)
+}
+
+// WriteBytes writes b prefixed by its length.
+func (w *BinaryWriter) WriteBytes(b []byte) {
+	w.WriteUint32(uint32(len(b)))
+	w.buf.Write(b)
+}
+
+// WriteBigInt writes a non-negative integer as its minimal big endian bytes,
+// a nil value is distinguished from zero by a leading flag.
+func (w *BinaryWriter) WriteBigInt(v *big.Int) {
+	if v == nil {
+		w.WriteUint8(0)
+		return
+	}
+	w.WriteUint8(1)
+	w.WriteBytes(v.Bytes())
+}
+
+func (w *BinaryWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// BinaryReader reads data written by BinaryWriter. The first error is
+// sticky: once a read fails, every following read returns zero values
+// and Err reports the failure.
+type BinaryReader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func NewBinaryReader(data []byte) *BinaryReader {
+	return &BinaryReader{data: data}
+}
+
+func (r *BinaryReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data)-r.off < n {
+		r.err = ErrShortData
+		return nil
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *BinaryReader) ReadUint8() uint8 {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *BinaryReader) ReadUint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *BinaryReader) ReadUint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (r *BinaryReader) ReadInt32() int32 {
+	return int32(r.ReadUint32())
+}
+
+// ReadFixed fills dst with the next len(dst) bytes.
+func (r *BinaryReader) ReadFixed(dst []byte) {
+	copy(dst, r.next(len(dst)))
+}
+
+// ReadBytes reads a length prefixed field, the result is a copy.
+func (r *BinaryReader) ReadBytes() []byte {
+	n := r.ReadUint32()
+	if uint64(n) > uint64(r.Remaining()) {
+		r.setErr(ErrShortData)
+		return nil
+	}
+	b := r.next(int(n))
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func (r *BinaryReader) ReadBigInt() *big.Int {
+	flag := r.ReadUint8()
+	if r.err != nil {
+		return nil
+	}
+	switch flag {
+	case 0:
+		return nil
+	case 1:
+	default:
+		r.setErr(ErrNonCanonical)
+		return nil
+	}
+	b := r.ReadBytes()
+	if len(b) > 0 && b[0] == 0 {
+		r.setErr(ErrNonCanonical)
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+// Remaining returns the number of unread bytes.
+func (r *BinaryReader) Remaining() int {
+	return len(r.data) - r.off
+}
+
+func (r *BinaryReader) setErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *BinaryReader) Err() error {
+	return r.err
+}
+
+// Finish returns the read error, or ErrTrailingData when
+// the data was not consumed completely.
+func (r *BinaryReader) Finish() error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.Remaining() != 0 {
+		return ErrTrailingData
+	}
+	return nil
+}
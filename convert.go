@@ -0,0 +1,74 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"xfsgo/common/rawencode"
+	"xfsgo/storage"
+
+	"github.com/sirupsen/logrus"
+)
+
+// legacyBlockKeys returns the keys of the blocks stored with the JSON encoding.
+func legacyBlockKeys(chainDB storage.Database) ([][]byte, error) {
+	keys := make([][]byte, 0)
+	if err := chainDB.PrefixForeachData(blockHashPre, func(k []byte, v []byte) error {
+		if rawencode.IsLegacyJSON(v) {
+			keys = append(keys, append([]byte{}, k...))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ConvertLegacyEncoding rewrites the blocks of chainDB and the transaction
+// indexes of extraDB stored with the former JSON encoding into the binary encoding.
+// Legacy blocks keep their hashes: headers, transactions and receipts are still
+// hashed over their JSON encoding, so block keys, links, transaction hashes and
+// receipts are unchanged. It returns the number of converted blocks and can be
+// run again safely after an interruption.
+func ConvertLegacyEncoding(chainDB, extraDB storage.Database) (int, error) {
+	keys, err := legacyBlockKeys(chainDB)
+	if err != nil {
+		return 0, err
+	}
+	chain := newChainDB(chainDB)
+	extra := newExtraDB(extraDB)
+	for _, key := range keys {
+		val, err := chainDB.GetData(key)
+		if err != nil {
+			return 0, err
+		}
+		block := &Block{}
+		if err = block.Decode(val); err != nil {
+			return 0, err
+		}
+		if err = extra.WriteBlockTransaction(block); err != nil {
+			return 0, err
+		}
+		if err = extra.WriteBlockReceipts(block); err != nil {
+			return 0, err
+		}
+		if err = chain.WriteBlock(block); err != nil {
+			return 0, err
+		}
+		logrus.Debugf("convert block height: %d, hash: %s", block.Height(), block.HashHex())
+	}
+	return len(keys), nil
+}
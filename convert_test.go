@@ -0,0 +1,164 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"encoding/json"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"xfsgo/assert"
+	"xfsgo/common"
+	"xfsgo/common/rawencode"
+	"xfsgo/crypto"
+	"xfsgo/storage/badger"
+)
+
+func TestConvertLegacyEncoding(t *testing.T) {
+	dir := t.TempDir()
+	chainDb := badger.New(filepath.Join(dir, "chain"))
+	extraDb := badger.New(filepath.Join(dir, "extra"))
+	defer func() {
+		_ = chainDb.Close()
+		_ = extraDb.Close()
+	}()
+	key, err := crypto.GenPrvKey()
+	assert.Error(t, err)
+	tx := NewTransaction(common.Bytes2Address([]byte{0xff}), new(big.Int).SetInt64(1))
+	tx.legacy = true
+	assert.Error(t, tx.SignWithPrivateKey(key))
+	oldTxHash := tx.Hash()
+	block := NewBlock(&BlockHeader{Height: 1, Bits: 4}, []*Transaction{tx}, []*Receipt{NewReceipt(oldTxHash)})
+	blockHash := block.Hash()
+
+	// write the block as the JSON encoding did
+	blockData, err := json.Marshal(block)
+	assert.Error(t, err)
+	assert.Error(t, chainDb.SetData(append(blockHashPre, blockHash[:]...), blockData))
+	txData, err := json.Marshal(tx)
+	assert.Error(t, err)
+	assert.Error(t, extraDb.SetData(append(txPre, oldTxHash[:]...), txData))
+
+	n, err := ConvertLegacyEncoding(chainDb, extraDb)
+	assert.Error(t, err)
+	if n != 1 {
+		t.Fatalf("got converted: %d, want: 1", n)
+	}
+	val, err := chainDb.GetData(append(blockHashPre, blockHash[:]...))
+	assert.Error(t, err)
+	if rawencode.IsLegacyJSON(val) {
+		t.Fatalf("block not converted")
+	}
+	got := newChainDB(chainDb).GetBlockByHash(blockHash)
+	assert.HashEqual(t, got.Hash(), blockHash)
+	// the legacy transactions and receipts keep their hashes
+	assert.HashEqual(t, got.Transactions[0].Hash(), oldTxHash)
+	assert.HashEqual(t, got.Receipts[0].TxHash, oldTxHash)
+	assert.HashEqual(t, CalcTxsRootHash(got.Transactions), block.TransactionRoot())
+	assert.HashEqual(t, CalcReceiptRootHash(got.Receipts), block.ReceiptsRoot())
+	extra := newExtraDB(extraDb)
+	if gotTx := extra.GetTransactionByHash(oldTxHash); gotTx == nil || gotTx.Hash() != oldTxHash {
+		t.Fatalf("got transaction %v, want the transaction indexed by its legacy hash", gotTx)
+	}
+	n, err = ConvertLegacyEncoding(chainDb, extraDb)
+	assert.Error(t, err)
+	if n != 0 {
+		t.Fatalf("got converted: %d on second run, want: 0", n)
+	}
+}
+
+// mineLegacyTestBlock mines a block of the version before BlockVersion with
+// the legacy transactions on top of the chain, without inserting it.
+func mineLegacyTestBlock(t *testing.T, bc *BlockChain, coinbase common.Address, txs []*Transaction) *Block {
+	parent := bc.CurrentBlock()
+	header := &BlockHeader{
+		Height:        parent.Height() + 1,
+		Version:       BlockVersion - 1,
+		HashPrevBlock: parent.Hash(),
+		Timestamp:     parent.Timestamp() + 1,
+		Coinbase:      coinbase,
+		Bits:          parent.Bits(),
+	}
+	stateTree := NewStateTree(bc.stateDB, parent.Header.StateRoot.Bytes())
+	receipts, err := bc.ApplyTransactions(stateTree, txs)
+	assert.Error(t, err)
+	AccumulateRewards(stateTree, header)
+	stateTree.UpdateAll()
+	header.StateRoot = common.Bytes2Hash(stateTree.Root())
+	block := NewBlock(header, txs, receipts)
+	target := BitsUnzip(block.Bits())
+	for nonce := uint64(0); ; nonce++ {
+		hash := block.UpdateNonce(nonce)
+		if new(big.Int).SetBytes(hash[:]).Cmp(target) <= 0 {
+			return block
+		}
+	}
+}
+
+func TestConvertLegacyEncoding_Reinsert(t *testing.T) {
+	src := newTestChain(t)
+	key, sender := newTestAccount(t)
+	_, to := newTestAccount(t)
+	b1 := mineLegacyTestBlock(t, src, sender, nil)
+	assert.Error(t, src.InsertChain(b1))
+	tx := NewTransaction(to, big.NewInt(1))
+	tx.legacy = true
+	assert.Error(t, tx.SignWithPrivateKey(key))
+	b2 := mineLegacyTestBlock(t, src, sender, []*Transaction{tx})
+	assert.Error(t, src.InsertChain(b2))
+
+	// write the blocks as the JSON encoding did
+	dir := t.TempDir()
+	chainDb := badger.New(filepath.Join(dir, "chain"))
+	extraDb := badger.New(filepath.Join(dir, "extra"))
+	defer func() {
+		_ = chainDb.Close()
+		_ = extraDb.Close()
+	}()
+	for _, block := range []*Block{b1, b2} {
+		blockHash := block.Hash()
+		blockData, err := json.Marshal(block)
+		assert.Error(t, err)
+		assert.Error(t, chainDb.SetData(append(blockHashPre, blockHash[:]...), blockData))
+	}
+	_, err := ConvertLegacyEncoding(chainDb, extraDb)
+	assert.Error(t, err)
+
+	dst := newTestChain(t)
+	chain := newChainDB(chainDb)
+	for _, block := range []*Block{b1, b2} {
+		got := chain.GetBlockByHash(block.Hash())
+		if got == nil {
+			t.Fatalf("block %d not converted", block.Height())
+		}
+		for _, gotTx := range got.Transactions {
+			if !gotTx.VerifySignature() {
+				t.Fatalf("signature of the legacy transaction %s not valid", gotTx.Hash())
+			}
+		}
+		assert.Error(t, dst.InsertChain(got))
+	}
+	assert.HashEqual(t, dst.CurrentBlock().Hash(), b2.Hash())
+	if lookup := dst.GetTransactionLookup(tx.Hash()); lookup == nil || lookup.BlockHash != b2.Hash() {
+		t.Fatalf("got lookup %+v, want the transaction in block 2", lookup)
+	}
+	recs := dst.extraDB.GetBlockReceipts(b2.Hash())
+	if len(recs) != 1 || recs[0].TxHash != tx.Hash() {
+		t.Fatalf("got receipts %v, want the receipt of the legacy transaction", recs)
+	}
+	assert.HashEqual(t, CalcReceiptRootHash(recs), b2.ReceiptsRoot())
+}
@@ -30,7 +30,7 @@ var (
 	ffLsh256  = new(big.Int).Lsh(big0xff, 256)
 )
 
-// BigByZip zips 256 bit difficulty to uint32
+// BigByZip zips 256 bit difficulty to uint32, target is not modified.
 func BigByZip(target *big.Int) uint32 {
 	if target.Sign() == 0 {
 		return 0
@@ -44,7 +44,7 @@ func BigByZip(target *big.Int) uint32 {
 		mantissa <<= shift
 	} else {
 		shift := 8 * (e - c)
-		mantissaNum := target.Rsh(target, shift)
+		mantissaNum := new(big.Int).Rsh(target, shift)
 		mantissa = uint(mantissaNum.Bits()[0])
 	}
 	mantissa <<= 8
@@ -38,6 +38,19 @@ func TestBigByZip(t *testing.T) {
 	assert.Equal(t, len(bn.Bytes()), 32-3)
 }
 
+func TestBigByZip_KeepsTarget(t *testing.T) {
+	target := new(big.Int).Set(maxTarget)
+	bits := BigByZip(target)
+	if target.Cmp(maxTarget) != 0 {
+		t.Fatalf("got target %x after zipping, want %x", target, maxTarget)
+	}
+	// the genesis blocks written by a process get the same bits
+	if again := BigByZip(target); again != bits {
+		t.Fatalf("got bits %d on the second call, want %d", again, bits)
+	}
+	assert.Equal(t, BitsUnzip(bits).Cmp(maxTarget) <= 0, true)
+}
+
 func TestA(t *testing.T) {
 	a := new(big.Int).Lsh(big0xff, 256-(8*2))
 	aBs := a.Bytes()
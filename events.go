@@ -16,7 +16,10 @@
 
 package xfsgo
 
-type SyncStartEvent struct{}
+// SyncStartEvent is published when a sync with the peer at PeerHeight starts.
+type SyncStartEvent struct {
+	PeerHeight uint64
+}
 type SyncDoneEvent struct{}
 
 type TxPreEvent struct {
@@ -20,9 +20,10 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"io"
 	"xfsgo/common"
 	"xfsgo/common/rawencode"
-	"xfsgo/storage/badger"
+	"xfsgo/storage"
 )
 
 var (
@@ -33,21 +34,21 @@ var (
 )
 
 type extraDB struct {
-	storage *badger.Storage
+	storage storage.Database
 }
 
-func newExtraDB(db *badger.Storage) *extraDB {
+func newExtraDB(db storage.Database) *extraDB {
 	tdb := &extraDB{
 		storage: db,
 	}
 	return tdb
 }
 
-func (db *extraDB) newWriteBatch() *badger.StorageWriteBatch {
+func (db *extraDB) newWriteBatch() storage.WriteBatch {
 	return db.storage.NewWriteBatch()
 }
 
-func (db *extraDB) commitBatch(batch *badger.StorageWriteBatch) error {
+func (db *extraDB) commitBatch(batch storage.WriteBatch) error {
 	return db.storage.CommitWriteBatch(batch)
 }
 
@@ -57,12 +58,28 @@ type txIndex struct {
 	Index      uint64      `json:"index"`
 }
 
+// index = format(1byte)+block_hash(32byte)+block_index(8byte)+index(8byte)
 func (t *txIndex) Encode() ([]byte, error) {
-	return json.Marshal(t)
+	w := rawencode.NewBinaryWriter()
+	w.WriteUint8(binaryFormatV1)
+	w.WriteFixed(t.BlockHash[:])
+	w.WriteUint64(t.BlockIndex)
+	w.WriteUint64(t.Index)
+	return w.Bytes(), nil
 }
 
 func (t *txIndex) Decode(data []byte) error {
-	return json.Unmarshal(data, t)
+	if rawencode.IsLegacyJSON(data) {
+		return json.Unmarshal(data, t)
+	}
+	r := rawencode.NewBinaryReader(data)
+	if r.ReadUint8() != binaryFormatV1 {
+		return rawencode.ErrFormatVersion
+	}
+	r.ReadFixed(t.BlockHash[:])
+	t.BlockIndex = r.ReadUint64()
+	t.Index = r.ReadUint64()
+	return r.Finish()
 }
 
 func (db *extraDB) GetTransactionByHash(txHash common.Hash) *Transaction {
@@ -75,16 +92,37 @@ func (db *extraDB) GetTransactionByHash(txHash common.Hash) *Transaction {
 	if err = rawencode.Decode(txData, tx); err != nil {
 		return nil
 	}
+	// only the transactions of legacy blocks are stored as JSON
+	tx.legacy = rawencode.IsLegacyJSON(txData)
 	return tx
 }
+func (db *extraDB) getTxIndex(txHash common.Hash) *txIndex {
+	key := append(txIndexPre, txHash.Bytes()...)
+	data, err := db.storage.GetData(key)
+	if err != nil {
+		return nil
+	}
+	index := &txIndex{}
+	if err = rawencode.Decode(data, index); err != nil {
+		return nil
+	}
+	return index
+}
+
 func (db *extraDB) WriteBlockTransaction(block *Block) error {
+	return storage.Update(db.storage, func(w storage.Writer) error {
+		return db.writeBlockTransaction(w, block)
+	})
+}
+
+func (db *extraDB) writeBlockTransaction(w storage.Writer, block *Block) error {
 	for i, tx := range block.Transactions {
 		txData, txHash, err := common.ObjSHA256(tx)
 		if err != nil {
 			return err
 		}
 		txKey := append(txPre, txHash...)
-		if err = db.storage.SetData(txKey, txData); err != nil {
+		if err = w.Put(txKey, txData); err != nil {
 			return err
 		}
 		index := &txIndex{
@@ -97,7 +135,7 @@ func (db *extraDB) WriteBlockTransaction(block *Block) error {
 			return err
 		}
 		indexKey := append(txIndexPre, txHash...)
-		if err = db.storage.SetData(indexKey, indexData); err != nil {
+		if err = w.Put(indexKey, indexData); err != nil {
 			return err
 		}
 	}
@@ -128,10 +166,17 @@ func (db *extraDB) GetReceipt(txHash common.Hash) *Receipt {
 	if err = rawencode.Decode(data, r); err != nil {
 		return nil
 	}
+	r.legacy = rawencode.IsLegacyJSON(data)
 	return r
 }
 
 func (db *extraDB) WriteBlockReceipts(block *Block) error {
+	return storage.Update(db.storage, func(w storage.Writer) error {
+		return db.writeBlockReceipts(w, block)
+	})
+}
+
+func (db *extraDB) writeBlockReceipts(w storage.Writer, block *Block) error {
 	buf := bytes.NewBuffer(nil)
 	for _, receipt := range block.Receipts {
 		data, err := rawencode.Encode(receipt)
@@ -146,10 +191,7 @@ func (db *extraDB) WriteBlockReceipts(block *Block) error {
 	}
 	blockHash := block.Hash()
 	key := append(blockReceiptsPre, blockHash.Bytes()...)
-	if err := db.storage.SetData(key, buf.Bytes()); err != nil {
-		return err
-	}
-	return nil
+	return w.Put(key, buf.Bytes())
 }
 
 func (db *extraDB) GetBlockReceipts(hash common.Hash) []*Receipt {
@@ -168,10 +210,14 @@ func (db *extraDB) GetBlockReceipts(hash common.Hash) []*Receipt {
 		}
 		dataLen := binary.LittleEndian.Uint32(dataLenBuf[:])
 		var dataBuf = make([]byte, dataLen)
+		if _, err = io.ReadFull(buf, dataBuf); err != nil {
+			return nil
+		}
 		r := &Receipt{}
 		if err = rawencode.Decode(dataBuf, r); err != nil {
 			return nil
 		}
+		r.legacy = rawencode.IsLegacyJSON(dataBuf)
 		tmp = append(tmp, r)
 	}
 	return tmp
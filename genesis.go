@@ -24,7 +24,7 @@ import (
 	"math/big"
 	"strings"
 	"xfsgo/common"
-	"xfsgo/storage/badger"
+	"xfsgo/storage"
 
 	"github.com/sirupsen/logrus"
 )
@@ -34,7 +34,7 @@ var (
 )
 
 // WriteGenesisBlock constructs the genesis blcok for the blockchain and stores it in the hd.
-func WriteGenesisBlock(stateDB, chainDB *badger.Storage, reader io.Reader) (*Block, error) {
+func WriteGenesisBlock(stateDB, chainDB storage.Database, reader io.Reader) (*Block, error) {
 	contents, err := ioutil.ReadAll(reader)
 	if err != nil {
 		return nil, err
@@ -72,6 +72,7 @@ func WriteGenesisBlock(stateDB, chainDB *badger.Storage, reader io.Reader) (*Blo
 	rootHash := common.Bytes2Hash(stateTree.Root())
 	HashPrevBlock := common.Hex2Hash(genesis.HashPrevBlock)
 	block := NewBlock(&BlockHeader{
+		Version:       genesis.Version,
 		Nonce:         genesis.Nonce,
 		HashPrevBlock: HashPrevBlock,
 		Timestamp:     timestamp.Uint64(),
@@ -99,7 +100,7 @@ func WriteGenesisBlock(stateDB, chainDB *badger.Storage, reader io.Reader) (*Blo
 
 // WriteMainNetGenesisBlock constructs and stores a genesis blcok with default for xfs blockchain in mainnet model.
 //
-func WriteMainNetGenesisBlock(stateDB, blockDB *badger.Storage) (*Block, error) {
+func WriteMainNetGenesisBlock(stateDB, blockDB storage.Database) (*Block, error) {
 	bits := BigByZip(maxTarget)
 	jsonStr := fmt.Sprintf(`{
 	"nonce": 0,
@@ -113,7 +114,7 @@ func WriteMainNetGenesisBlock(stateDB, blockDB *badger.Storage) (*Block, error)
 }
 
 // WriteMainNetGenesisBlock constructs and stores a genesis blcok with default for xfs blockchain in testnet model.
-func WriteTestNetGenesisBlock(stateDB, blockDB *badger.Storage) (*Block, error) {
+func WriteTestNetGenesisBlock(stateDB, blockDB storage.Database) (*Block, error) {
 	bits := BigByZip(maxTarget)
 	jsonStr := fmt.Sprintf(`{
 	"nonce": 0,
@@ -126,7 +127,7 @@ func WriteTestNetGenesisBlock(stateDB, blockDB *badger.Storage) (*Block, error)
 	return WriteGenesisBlock(stateDB, blockDB, strings.NewReader(jsonStr))
 }
 
-func WriteTestGenesisBlock(stateDB, blockDB *badger.Storage) (*Block, error) {
+func WriteTestGenesisBlock(stateDB, blockDB storage.Database) (*Block, error) {
 	bits := BigByZip(maxTarget)
 	jsonStr := fmt.Sprintf(`{
 	"nonce": 0,
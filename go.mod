@@ -8,6 +8,7 @@ require (
 	github.com/gin-gonic/gin v1.7.2
 	github.com/go-resty/resty/v2 v2.6.0
 	github.com/golang/groupcache v0.0.0-20200121045136-8c9f03a8e57e // indirect
+	github.com/golang/snappy v0.0.1
 	github.com/google/flatbuffers v2.0.0+incompatible // indirect
 	github.com/gorilla/websocket v1.4.2
 	github.com/magiconair/properties v1.8.1
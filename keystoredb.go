@@ -20,7 +20,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/x509"
 	"xfsgo/common"
-	"xfsgo/storage/badger"
+	"xfsgo/storage"
 )
 
 var (
@@ -29,10 +29,10 @@ var (
 )
 
 type keyStoreDB struct {
-	storage *badger.Storage
+	storage storage.Database
 }
 
-func newKeyStoreDB(storage *badger.Storage) *keyStoreDB {
+func newKeyStoreDB(storage storage.Database) *keyStoreDB {
 	return &keyStoreDB{
 		storage: storage,
 	}
@@ -25,7 +25,7 @@ import (
 	"time"
 	"xfsgo"
 	"xfsgo/common"
-	"xfsgo/storage/badger"
+	"xfsgo/storage"
 
 	"github.com/sirupsen/logrus"
 )
@@ -56,10 +56,10 @@ type Miner struct {
 	canStart         bool
 	pool             *xfsgo.TxPool
 	chain            *xfsgo.BlockChain
-	stateDb          *badger.Storage
+	stateDb          storage.Database
 }
 
-func NewMiner(config *Config, stateDb *badger.Storage, chain *xfsgo.BlockChain, eventBus *xfsgo.EventBus, pool *xfsgo.TxPool) *Miner {
+func NewMiner(config *Config, stateDb storage.Database, chain *xfsgo.BlockChain, eventBus *xfsgo.EventBus, pool *xfsgo.TxPool) *Miner {
 	m := &Miner{
 		Config:           config,
 		chain:            chain,
@@ -132,6 +132,7 @@ func (m *Miner) mimeBlockWithParent(
 	lastGenerated := time.Now().Unix()
 	header := &xfsgo.BlockHeader{
 		Height:        parentBlock.Height() + 1,
+		Version:       xfsgo.BlockVersion,
 		HashPrevBlock: parentBlock.Hash(),
 		Timestamp:     uint64(lastGenerated),
 		Coinbase:      coinbase,
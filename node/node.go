@@ -28,7 +28,7 @@ import (
 	"xfsgo/miner"
 	"xfsgo/p2p"
 	"xfsgo/p2p/discover"
-	"xfsgo/storage/badger"
+	"xfsgo/storage"
 
 	"github.com/sirupsen/logrus"
 )
@@ -46,8 +46,10 @@ type Config struct {
 	ProtocolVersion  uint8
 	P2PBootstraps    []string
 	P2PStaticNodes   []string
-	NodeDBPath       string
-	RPCConfig        *xfsgo.RPCConfig
+	// P2PDisableCompression turns off snappy compression of p2p messages.
+	P2PDisableCompression bool
+	NodeDBPath            string
+	RPCConfig             *xfsgo.RPCConfig
 }
 
 const nodedbKeyName = "/dbkey"
@@ -100,6 +102,8 @@ func New(config *Config) (*Node, error) {
 		Discover:        true,
 		MaxPeers:        10,
 		NodeDBPath:      config.NodeDBPath,
+
+		DisableCompression: config.P2PDisableCompression,
 	})
 	n := &Node{
 		config:    config,
@@ -162,14 +166,18 @@ func (n *Node) Start() error {
 
 //RegisterBackend registers built-in APIs.
 func (n *Node) RegisterBackend(
-	stateDb *badger.Storage,
+	stateDb storage.Database,
 	bc *xfsgo.BlockChain,
 	miner *miner.Miner,
 	wallet *xfsgo.Wallet,
-	txPool *xfsgo.TxPool) error {
+	txPool *xfsgo.TxPool,
+	eventBus *xfsgo.EventBus) error {
+	syncTracker := xfsgo.NewSyncTracker(bc, eventBus)
+	syncTracker.Start()
 	chainApiHandler := &api.ChainAPIHandler{
 		BlockChain:    bc,
 		TxPendingPool: txPool,
+		SyncTracker:   syncTracker,
 	}
 	minerApiHandler := &api.MinerAPIHandler{
 		Miner: miner,
@@ -185,7 +193,9 @@ func (n *Node) RegisterBackend(
 		TxPool: txPool,
 	}
 	stateHandler := &api.StateAPIHandler{
-		StateDb: stateDb,
+		StateDb:    stateDb,
+		BlockChain: bc,
+		TxPool:     txPool,
 	}
 	if err := n.rpcServer.RegisterName("Chain", chainApiHandler); err != nil {
 		log.Fatalf("RPC service register error: %s", err)
@@ -208,6 +218,12 @@ func (n *Node) RegisterBackend(
 		log.Fatalf("RPC service register error: %s", err)
 		return err
 	}
+	feed := &api.SubscriptionFeed{
+		EventBus:   eventBus,
+		BlockChain: bc,
+		Server:     n.rpcServer,
+	}
+	feed.Start()
 	return nil
 }
 
@@ -0,0 +1,72 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package p2p
+
+import (
+	"errors"
+
+	"github.com/golang/snappy"
+)
+
+const (
+	// helloFlagSnappy is announced in the hello exchange by a node able
+	// to read snappy compressed messages.
+	helloFlagSnappy uint8 = 1 << 0
+
+	// msgFlagSnappy is set in the version byte of a message whose data
+	// is snappy compressed, it is only used once both sides announced it.
+	msgFlagSnappy uint8 = 1 << 7
+
+	// compressThreshold is the data size from which messages are compressed,
+	// smaller messages do not gain enough to pay for it.
+	compressThreshold = 256
+)
+
+var (
+	errUnexpectedCompression = errors.New("compressed message not negotiated")
+	errCompressedData        = errors.New("compressed message data corrupt")
+)
+
+// compressData returns the snappy encoding of data if it is worth sending
+// instead of data, or nil.
+func compressData(data []byte) []byte {
+	if len(data) < compressThreshold {
+		return nil
+	}
+	enc := snappy.Encode(nil, data)
+	if len(enc) >= len(data) {
+		return nil
+	}
+	return enc
+}
+
+// decompressData decodes a snappy compressed message data,
+// refusing to allocate more than limit bytes.
+func decompressData(mType uint8, data []byte, limit uint32) ([]byte, error) {
+	n, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, errCompressedData
+	}
+	if uint64(n) > uint64(limit) {
+		return nil, &ErrMsgTooLarge{Type: mType, Size: uint32(n), Limit: limit}
+	}
+	dec, err := snappy.Decode(nil, data)
+	if err != nil {
+		return nil, errCompressedData
+	}
+	return dec, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package p2p
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"xfsgo/crypto"
+	"xfsgo/p2p/discover"
+)
+
+// testMsgType is a protocol message type without a p2p level limit.
+const testMsgType uint8 = 20
+
+func testCompressHandshake(t *testing.T, clientOff, serverOff bool) (*peerConn, *peerConn) {
+	clientKey, _ := crypto.GenPrvKey()
+	serverKey, _ := crypto.GenPrvKey()
+	serverId := discover.PubKey2NodeId(serverKey.PublicKey)
+	cRw, sRw := net.Pipe()
+	client := newTestPeerConn(t, cRw, clientKey, &serverId)
+	client.noCompression = clientOff
+	server := newTestPeerConn(t, sRw, serverKey, nil)
+	server.noCompression = serverOff
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.serverHandshake()
+	}()
+	if err := client.clientHandshake(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func TestPeerConn_NegotiateCompression(t *testing.T) {
+	tests := []struct {
+		clientOff bool
+		serverOff bool
+		want      bool
+	}{
+		{false, false, true},
+		{true, false, false},
+		{false, true, false},
+	}
+	for _, tt := range tests {
+		client, server := testCompressHandshake(t, tt.clientOff, tt.serverOff)
+		if client.snappy != tt.want || server.snappy != tt.want {
+			t.Fatalf("disabled client %v server %v: got snappy client %v server %v, want %v",
+				tt.clientOff, tt.serverOff, client.snappy, server.snappy, tt.want)
+		}
+	}
+}
+
+func TestPeerConn_CompressedMessage(t *testing.T) {
+	client, server := testCompressHandshake(t, false, false)
+	small := []byte("hello")
+	large := bytes.Repeat([]byte("block data "), 1024)
+	reader := newMsgReader(server.rw, defaultMsgSizeLimit, &server.meter, server.snappy)
+	for _, want := range [][]byte{small, large} {
+		errc := make(chan error, 1)
+		go func(data []byte) {
+			errc <- client.writeMessage(testMsgType, data)
+		}(want)
+		msg, err := reader.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = <-errc; err != nil {
+			t.Fatal(err)
+		}
+		got, _ := msg.ReadAll()
+		if !bytes.Equal(got, want) {
+			t.Fatalf("got data len %d, want len %d", len(got), len(want))
+		}
+		raw := new(bytes.Buffer)
+		_, _ = raw.ReadFrom(msg.RawReader())
+		if raw.Bytes()[0] != version1 {
+			t.Fatalf("got raw version %d, want %d", raw.Bytes()[0], version1)
+		}
+	}
+	sent := client.meter.stats()
+	received := server.meter.stats()
+	if sent.EgressBytes != received.IngressBytes ||
+		sent.EgressUncompressedBytes != received.IngressUncompressedBytes {
+		t.Fatalf("egress %+v not match ingress %+v", sent, received)
+	}
+	wantRaw := uint64(2*headerLen + len(small) + len(large))
+	if received.IngressUncompressedBytes != wantRaw {
+		t.Fatalf("got uncompressed bytes %d, want %d", received.IngressUncompressedBytes, wantRaw)
+	}
+	if received.IngressBytes >= received.IngressUncompressedBytes {
+		t.Fatalf("got wire bytes %d, want less than %d", received.IngressBytes, received.IngressUncompressedBytes)
+	}
+}
+
+func TestReadMessage_UnexpectedCompression(t *testing.T) {
+	data := compressData(bytes.Repeat([]byte{1}, 1024))
+	msg := newTestMsg(testMsgType, data)
+	msg[0] |= msgFlagSnappy
+	if _, err := ReadMessage(bytes.NewReader(msg)); err != errUnexpectedCompression {
+		t.Fatalf("got err %v, want %v", err, errUnexpectedCompression)
+	}
+}
+
+func TestReadMessage_CompressedTooLarge(t *testing.T) {
+	data := compressData(bytes.Repeat([]byte{1}, 4096))
+	msg := newTestMsg(testMsgType, data)
+	msg[0] |= msgFlagSnappy
+	limit := func(uint8) uint32 { return 1024 }
+	_, _, err := readMessage(bytes.NewReader(msg), limit, true)
+	if _, ok := err.(*ErrMsgTooLarge); !ok {
+		t.Fatalf("got err %v, want ErrMsgTooLarge", err)
+	}
+}
@@ -142,7 +142,7 @@ func newDialState(static []*discover.Node, table discoverTable, maxdyn int) *dia
 	}
 	return d
 }
-func (d *dialstate) newTasks(nRunning int, peers map[discover.NodeId]Peer, now time.Time) []task {
+func (d *dialstate) newTasks(nRunning int, peers map[discover.NodeId]*peer, now time.Time) []task {
 	var tasks []task
 	addDial := func(flag int, n *discover.Node) bool {
 		//the connection established needn't to join the pool
@@ -32,7 +32,7 @@ func Test_dialtask_newDialState(t *testing.T) {
 	}
 	dynPeers := 10 / 2
 	ds := newDialState(bootNs, tab, dynPeers)
-	ps := make(map[discover.NodeId]Peer)
+	ps := make(map[discover.NodeId]*peer)
 	for {
 		now := time.Now()
 		ts := ds.newTasks(1, ps, now)
@@ -8,16 +8,17 @@ import (
 	"time"
 	"xfsgo/common/rawencode"
 	"xfsgo/crypto"
+	"xfsgo/storage"
 	"xfsgo/storage/badger"
 )
 
 type nodeDB struct {
-	storage *badger.Storage
+	storage storage.Database
 	version uint32
 	self    NodeId
 	quit    chan struct{}
 	runner  sync.Once
-	seeder  badger.Iterator
+	seeder  storage.Iterator
 }
 
 var (
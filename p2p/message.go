@@ -17,8 +17,10 @@
 package p2p
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"xfsgo/p2p/discover"
 )
@@ -41,6 +43,7 @@ type messageReader struct {
 	mType   uint8
 	raw     io.Reader
 	data    io.Reader
+	size    int // uncompressed length of the message
 }
 
 // Type returns message type
@@ -68,104 +71,266 @@ func (m *messageReader) DataReader() io.Reader {
 	return m.data
 }
 
+// DefaultMaxMsgSize is the size limit of a message whose type has no explicit limit.
+const DefaultMaxMsgSize uint32 = 8 * 1024 * 1024
+
+// p2p level messages are small, so they are limited tightly.
+var baseMsgSizeLimits = map[uint8]uint32{
+	typeHelloRequest:   1024,
+	typeReHelloRequest: 1024,
+	typePingMsg:        64,
+	typePongMsg:        64,
+	typeGetAddrRequest: 1024,
+}
+
+// defaultMsgSizeLimit returns the limit of p2p level messages, or
+// DefaultMaxMsgSize for any other type.
+func defaultMsgSizeLimit(mType uint8) uint32 {
+	if limit, ok := baseMsgSizeLimits[mType]; ok {
+		return limit
+	}
+	return DefaultMaxMsgSize
+}
+
+// ErrMsgTooLarge is returned when a peer announces a message above the limit of its type.
+type ErrMsgTooLarge struct {
+	Type  uint8
+	Size  uint32
+	Limit uint32
+}
+
+func (e *ErrMsgTooLarge) Error() string {
+	return fmt.Sprintf("message type %d too large: size %d, limit %d", e.Type, e.Size, e.Limit)
+}
+
 // ReadMessage reads message from other peer and returns MessageReader by header of message.
+// It never reads past the end of the message, so it is safe on an unbuffered
+// connection that is handed over to another reader afterwards.
 // message = version(1byte)+type(1byte)+length(4byte)+data
 func ReadMessage(reader io.Reader) (MessageReader, error) {
-	mBuffer := bytes.NewBuffer(nil)
+	msg, _, err := readMessage(reader, defaultMsgSizeLimit, false)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
 
-	//vertion and type
-	for mBuffer.Len() < 6 {
-		b := make([]byte, 1)
-		if _, err := reader.Read(b); err != nil {
-			return nil, err
-		}
-		mBuffer.Write(b)
+// readMessage returns the message and the number of bytes it took on the wire.
+// When snappy is set, messages flagged as compressed are decompressed and
+// returned as if they had been sent plain.
+func readMessage(reader io.Reader, limit func(mType uint8) uint32, snappy bool) (*messageReader, int, error) {
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, 0, err
 	}
-	header := mBuffer.Bytes()
 	//length of data in message.4 bytes stored by LittleEndian model.
 	n := binary.LittleEndian.Uint32(header[2:])
+	max := limit(header[1])
+	if n > max {
+		return nil, 0, &ErrMsgTooLarge{Type: header[1], Size: n, Limit: max}
+	}
+	data := make([]byte, headerLen+int(n))
+	copy(data, header)
+	if _, err := io.ReadFull(reader, data[headerLen:]); err != nil {
+		return nil, 0, err
+	}
+	wireLen := len(data)
+	if data[0]&msgFlagSnappy != 0 {
+		if !snappy {
+			return nil, 0, errUnexpectedCompression
+		}
+		dec, err := decompressData(header[1], data[headerLen:], max)
+		if err != nil {
+			return nil, 0, err
+		}
+		data = make([]byte, headerLen+len(dec))
+		data[0] = header[0] &^ msgFlagSnappy
+		data[1] = header[1]
+		binary.LittleEndian.PutUint32(data[2:headerLen], uint32(len(dec)))
+		copy(data[headerLen:], dec)
+	}
+	return &messageReader{
+		version: data[0],
+		mType:   data[1],
+		raw:     bytes.NewReader(data),
+		data:    bytes.NewReader(data[headerLen:]),
+		size:    len(data),
+	}, wireLen, nil
+}
 
-	for mBuffer.Len() < 6+int(n) {
-		b := make([]byte, 1)
+// msgReader reads framed messages from a buffered connection,
+// enforcing the size limit of each message type and accounting
+// the received bytes in the meter of the peer.
+type msgReader struct {
+	r      *bufio.Reader
+	limit  func(mType uint8) uint32
+	meter  *meter
+	snappy bool
+}
 
-		if _, err := reader.Read(b); err != nil {
-			return nil, err
-		}
+func newMsgReader(r io.Reader, limit func(mType uint8) uint32, m *meter, snappy bool) *msgReader {
+	return &msgReader{
+		r:      bufio.NewReader(r),
+		limit:  limit,
+		meter:  m,
+		snappy: snappy,
+	}
+}
+
+func (mr *msgReader) ReadMessage() (MessageReader, error) {
+	msg, size, err := readMessage(mr.r, mr.limit, mr.snappy)
+	if err != nil {
+		return nil, err
+	}
+	if mr.meter != nil {
+		mr.meter.markIngress(size, msg.size)
+	}
+	return msg, nil
+}
+
+// helloBody is the payload shared by the hello request and its reply.
+// body = id(64byte)+receiveId(64byte)+ephemeral(65byte)+nonce(32byte)+flags(1byte)+caps+sign
+// caps = count(1byte)+count*(nameLen(1byte)+name+version(1byte))
+type helloBody struct {
+	id        discover.NodeId
+	receiveId discover.NodeId
+	ephemeral []byte
+	nonce     [handshakeNonceLen]byte
+	flags     uint8 // features supported by the sender, such as helloFlagSnappy
+	caps      []Cap // protocols run by the sender
+	sign      []byte
+}
 
-		mBuffer.Write(b)
+func (b *helloBody) marshalCaps() []byte {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte(uint8(len(b.caps)))
+	for _, c := range b.caps {
+		buf.WriteByte(uint8(len(c.Name)))
+		buf.WriteString(c.Name)
+		buf.WriteByte(c.Version)
 	}
+	return buf.Bytes()
+}
 
-	data := mBuffer.Bytes()
+// unmarshalCaps parses the caps at the start of data and returns their length.
+func (b *helloBody) unmarshalCaps(data []byte) (int, bool) {
+	if len(data) < 1 {
+		return 0, false
+	}
+	n := int(data[0])
+	offset := 1
+	caps := make([]Cap, 0, n)
+	for i := 0; i < n; i++ {
+		if len(data) <= offset {
+			return 0, false
+		}
+		nameLen := int(data[offset])
+		offset++
+		if nameLen > maxCapNameLen || len(data) < offset+nameLen+1 {
+			return 0, false
+		}
+		caps = append(caps, Cap{
+			Name:    string(data[offset : offset+nameLen]),
+			Version: data[offset+nameLen],
+		})
+		offset += nameLen + 1
+	}
+	b.caps = caps
+	return offset, true
+}
 
-	return &messageReader{
-		raw:   mBuffer,
-		mType: data[1],
-		data:  bytes.NewReader(data[6:]),
-	}, nil
+func (b *helloBody) marshal(version uint8, mType uint8) []byte {
+	body := bytes.NewBuffer(nil)
+	body.Write(b.id[:])
+	body.Write(b.receiveId[:])
+	body.Write(b.ephemeral)
+	body.Write(b.nonce[:])
+	body.WriteByte(b.flags)
+	body.Write(b.marshalCaps())
+	body.Write(b.sign)
+	cLen := body.Len()
+	val := make([]byte, headerLen+cLen)
+	val[0] = version
+	val[1] = mType
+	binary.LittleEndian.PutUint32(val[2:headerLen], uint32(cLen))
+	copy(val[headerLen:], body.Bytes())
+	return val
+}
+
+func (b *helloBody) unmarshal(data []byte, mType uint8) (uint8, bool) {
+	if len(data) < headerLen || data[1] != mType {
+		return 0, false
+	}
+	cLen := binary.LittleEndian.Uint32(data[2:headerLen])
+	if uint64(len(data)) < uint64(headerLen)+uint64(cLen) {
+		return 0, false
+	}
+	body := data[headerLen : headerLen+int(cLen)]
+	fixedLen := len(b.id) + len(b.receiveId) + ephemeralKeyLen + handshakeNonceLen + 1
+	if len(body) <= fixedLen {
+		return 0, false
+	}
+	offset := 0
+	offset += copy(b.id[:], body[offset:])
+	offset += copy(b.receiveId[:], body[offset:])
+	b.ephemeral = make([]byte, ephemeralKeyLen)
+	offset += copy(b.ephemeral, body[offset:])
+	offset += copy(b.nonce[:], body[offset:])
+	b.flags = body[offset]
+	offset++
+	capsLen, ok := b.unmarshalCaps(body[offset:])
+	if !ok || len(body) <= offset+capsLen {
+		return 0, false
+	}
+	offset += capsLen
+	b.sign = make([]byte, len(body)-offset)
+	copy(b.sign, body[offset:])
+	return data[0], true
+}
+
+// sigHash returns the digest signed by the node key of the sender.
+// The reply additionally covers the nonce of the request, binding it to this session.
+func (b *helloBody) sigHash(version uint8, mType uint8, remoteNonce []byte) []byte {
+	return kdf([]byte{version, mType}, b.id[:], b.receiveId[:], b.ephemeral, b.nonce[:],
+		[]byte{b.flags}, b.marshalCaps(), remoteNonce)
 }
 
 type helloRequestMsg struct {
-	raw       []byte
-	version   uint8
-	id        discover.NodeId
-	receiveId discover.NodeId
+	raw     []byte
+	version uint8
+	helloBody
 }
 
 func (m *helloRequestMsg) marshal() []byte {
 	if m.raw != nil {
 		return m.raw
 	}
-	cLen := len(m.id) + len(m.receiveId)
-	val := make([]byte, cLen+4)
-	binary.LittleEndian.PutUint32(val, uint32(cLen))
-	copy(val[4:], append(m.id[:], m.receiveId[:]...))
-	base := []byte{m.version, typeHelloRequest}
-	base = append(base, val...)
-	return base
+	return m.helloBody.marshal(m.version, typeHelloRequest)
 }
 
 func (m *helloRequestMsg) unmarshal(data []byte) bool {
 	m.raw = data
-	m.version = data[0]
-	if data[1] != typeHelloRequest {
-		return false
-	}
-	cLen := binary.LittleEndian.Uint32(data[2:headerLen])
-	body := data[headerLen : headerLen+cLen]
-	copy(m.id[:], body[:len(m.id)])
-	copy(m.receiveId[:], body[len(m.id):])
-	return true
+	version, ok := m.helloBody.unmarshal(data, typeHelloRequest)
+	m.version = version
+	return ok
 }
 
 type helloReRequestMsg struct {
-	raw       []byte
-	version   uint8
-	id        discover.NodeId
-	receiveId discover.NodeId
+	raw     []byte
+	version uint8
+	helloBody
 }
 
 func (m *helloReRequestMsg) marshal() []byte {
 	if m.raw != nil {
 		return m.raw
 	}
-	cLen := len(m.id) + len(m.receiveId)
-	val := make([]byte, cLen+4)
-	binary.LittleEndian.PutUint32(val, uint32(cLen))
-	copy(val[4:], append(m.id[:], m.receiveId[:]...))
-	base := []byte{m.version, typeReHelloRequest}
-	base = append(base, val...)
-	return base
+	return m.helloBody.marshal(m.version, typeReHelloRequest)
 }
 
 func (m *helloReRequestMsg) unmarshal(data []byte) bool {
 	m.raw = data
-	m.version = data[0]
-	if data[1] != typeReHelloRequest {
-		return false
-	}
-	cLen := binary.LittleEndian.Uint32(data[2:headerLen])
-	body := data[headerLen : headerLen+cLen]
-	copy(m.id[:], body[:len(m.id)])
-	copy(m.receiveId[:], body[len(m.id):])
-	return true
+	version, ok := m.helloBody.unmarshal(data, typeReHelloRequest)
+	m.version = version
+	return ok
 }
@@ -17,6 +17,8 @@
 package p2p
 
 import (
+	"bytes"
+	"encoding/binary"
 	"testing"
 	"xfsgo/crypto"
 	"xfsgo/p2p/discover"
@@ -30,8 +32,8 @@ func TestHello(t *testing.T) {
 	pubKey := key.PublicKey
 	id := discover.PubKey2NodeId(pubKey)
 	hello := &helloRequestMsg{
-		version: 1,
-		id:      id,
+		version:   1,
+		helloBody: helloBody{id: id},
 	}
 	data := hello.marshal()
 	t.Logf("data: %v\n", data)
@@ -48,3 +50,61 @@ func TestHello2(t *testing.T) {
 	n := uint32(data[2]) | uint32(data[3])<<8 | uint32(data[4])<<16 | uint32(data[5])<<24
 	t.Logf("n: %v\n", n)
 }
+
+func newTestMsg(mType uint8, data []byte) []byte {
+	msg := make([]byte, headerLen+len(data))
+	msg[0] = version1
+	msg[1] = mType
+	binary.LittleEndian.PutUint32(msg[2:], uint32(len(data)))
+	copy(msg[headerLen:], data)
+	return msg
+}
+
+func TestReadMessage_TooLarge(t *testing.T) {
+	// header only: announces a 4 GB payload that never follows
+	header := []byte{version1, 20, 0xff, 0xff, 0xff, 0xff}
+	_, err := ReadMessage(bytes.NewReader(header))
+	if _, ok := err.(*ErrMsgTooLarge); !ok {
+		t.Fatalf("got err %v, want ErrMsgTooLarge", err)
+	}
+	ping := newTestMsg(typePingMsg, make([]byte, 65))
+	_, err = ReadMessage(bytes.NewReader(ping))
+	if _, ok := err.(*ErrMsgTooLarge); !ok {
+		t.Fatalf("got err %v, want ErrMsgTooLarge", err)
+	}
+}
+
+func TestMsgReader_ReadMessage(t *testing.T) {
+	stream := bytes.NewBuffer(nil)
+	stream.Write(newTestMsg(20, []byte("first")))
+	stream.Write(newTestMsg(21, []byte("second")))
+	stream.Write(newTestMsg(22, make([]byte, 11)))
+	// the protocol codes start at 16, so its code 6 is 22 on the wire
+	ps := []Protocol{&SimpleProtocol{
+		Name:          "test",
+		Version:       1,
+		MsgLength:     8,
+		MsgSizeLimits: map[uint8]uint32{6: 10},
+	}}
+	m := new(meter)
+	protos := matchProtocols(ps, protocolCaps(ps))
+	reader := newMsgReader(stream, msgSizeLimit(protos, 0), m, false)
+	for _, want := range []string{"first", "second"} {
+		msg, err := reader.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := msg.ReadAll()
+		if string(got) != want {
+			t.Fatalf("got data %q, want %q", got, want)
+		}
+	}
+	if _, err := reader.ReadMessage(); err == nil {
+		t.Fatalf("read message above protocol limit should fail")
+	}
+	stats := m.stats()
+	wantBytes := uint64(2*headerLen + len("first") + len("second"))
+	if stats.IngressMsgs != 2 || stats.IngressBytes != wantBytes {
+		t.Fatalf("got stats %+v, want 2 msgs and %d bytes", stats, wantBytes)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package p2p
+
+import "sync/atomic"
+
+// TrafficStats is a snapshot of the messages exchanged with a peer.
+// The bytes count what went over the wire, the uncompressed bytes
+// what the messages would have taken without compression.
+type TrafficStats struct {
+	IngressMsgs              uint64 `json:"ingress_msgs"`
+	IngressBytes             uint64 `json:"ingress_bytes"`
+	IngressUncompressedBytes uint64 `json:"ingress_uncompressed_bytes"`
+	EgressMsgs               uint64 `json:"egress_msgs"`
+	EgressBytes              uint64 `json:"egress_bytes"`
+	EgressUncompressedBytes  uint64 `json:"egress_uncompressed_bytes"`
+}
+
+// meter counts the messages and bytes of a peer connection.
+// It is safe for concurrent use.
+type meter struct {
+	ingressMsgs              uint64
+	ingressBytes             uint64
+	ingressUncompressedBytes uint64
+	egressMsgs               uint64
+	egressBytes              uint64
+	egressUncompressedBytes  uint64
+}
+
+func (m *meter) markIngress(wire int, raw int) {
+	atomic.AddUint64(&m.ingressMsgs, 1)
+	atomic.AddUint64(&m.ingressBytes, uint64(wire))
+	atomic.AddUint64(&m.ingressUncompressedBytes, uint64(raw))
+}
+
+func (m *meter) markEgress(wire int, raw int) {
+	atomic.AddUint64(&m.egressMsgs, 1)
+	atomic.AddUint64(&m.egressBytes, uint64(wire))
+	atomic.AddUint64(&m.egressUncompressedBytes, uint64(raw))
+}
+
+func (m *meter) stats() TrafficStats {
+	return TrafficStats{
+		IngressMsgs:              atomic.LoadUint64(&m.ingressMsgs),
+		IngressBytes:             atomic.LoadUint64(&m.ingressBytes),
+		IngressUncompressedBytes: atomic.LoadUint64(&m.ingressUncompressedBytes),
+		EgressMsgs:               atomic.LoadUint64(&m.egressMsgs),
+		EgressBytes:              atomic.LoadUint64(&m.egressBytes),
+		EgressUncompressedBytes:  atomic.LoadUint64(&m.egressUncompressedBytes),
+	}
+}
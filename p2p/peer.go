@@ -2,10 +2,11 @@ package p2p
 
 import (
 	"bytes"
-	"encoding/json"
+	"fmt"
 	"io"
 	"net"
 	"time"
+	"xfsgo/common/rawencode"
 	"xfsgo/p2p/discover"
 	"xfsgo/p2p/log"
 )
@@ -20,6 +21,8 @@ type Peer interface {
 	WriteMessageObj(mType uint8, data interface{}) error
 	Reader() io.Reader
 	GetProtocolMsgCh() chan MessageReader
+	Stats() TrafficStats
+	Caps() []Cap
 }
 
 type peer struct {
@@ -29,24 +32,42 @@ type peer struct {
 	close    chan struct{}
 	lastTime int64
 	readBuf  bytes.Buffer
-	ps       []Protocol
+	protos   []*protoRW
 	quit     chan struct{}
-	psCh     chan MessageReader
 	logger   log.Logger
+	reader   *msgReader
+}
+
+// protoRW is the view of a peer given to one of the protocols running on it.
+// Message codes are relative to the protocol, they are shifted by
+// its offset on the connection.
+type protoRW struct {
+	*peer
+	proto  Protocol
+	offset uint8
+	in     chan MessageReader
 }
 
 // create peer [Peer to peer connection session,Network protocol]
-func newPeer(conn *peerConn, ps []Protocol) Peer {
+// Only the protocols of ps that were negotiated in the handshake are run.
+func newPeer(conn *peerConn, ps []Protocol, maxMsgSize uint32) *peer {
 	p := &peer{
 		conn:   conn,
 		id:     conn.id,
 		rw:     conn.rw,
 		logger: conn.logger,
-		ps:     ps,
 		close:  make(chan struct{}),
 		quit:   make(chan struct{}),
-		psCh:   make(chan MessageReader),
 	}
+	p.protos = matchProtocols(ps, conn.remoteCaps)
+	for _, proto := range p.protos {
+		proto.peer = p
+	}
+	limit := msgSizeLimit(p.protos, maxMsgSize)
+	if sc, ok := conn.rw.(*secureConn); ok {
+		sc.limit = limit
+	}
+	p.reader = newMsgReader(conn.rw, limit, &conn.meter, conn.snappy)
 	now := time.Now()
 	p.lastTime = now.Unix()
 	return p
@@ -59,6 +80,41 @@ func (p *peer) ID() discover.NodeId {
 func (p *peer) QuitCh() chan struct{} {
 	return p.quit
 }
+
+// msgSizeLimit returns the size limit lookup of a peer: p2p level messages
+// keep their own limits, then the limits declared by the protocol owning
+// the message code apply, and every other message is bounded by maxMsgSize.
+func msgSizeLimit(protos []*protoRW, maxMsgSize uint32) func(mType uint8) uint32 {
+	if maxMsgSize == 0 {
+		maxMsgSize = DefaultMaxMsgSize
+	}
+	return func(mType uint8) uint32 {
+		if limit, ok := baseMsgSizeLimits[mType]; ok {
+			return limit
+		}
+		proto := findProtocol(protos, mType)
+		if proto == nil {
+			return maxMsgSize
+		}
+		if limiter, ok := proto.proto.(MsgSizeLimiter); ok {
+			if limit, ok := limiter.MaxMsgSize(mType - proto.offset); ok {
+				return limit
+			}
+		}
+		return maxMsgSize
+	}
+}
+
+// findProtocol returns the protocol owning the message code mType, or nil.
+func findProtocol(protos []*protoRW, mType uint8) *protoRW {
+	for _, proto := range protos {
+		if mType >= proto.offset && int(mType) < int(proto.offset)+int(proto.proto.Length()) {
+			return proto
+		}
+	}
+	return nil
+}
+
 func (p *peer) Is(flag int) bool {
 	return p.conn.flag&flag != 0
 }
@@ -71,8 +127,12 @@ func (p *peer) readLoop() {
 			return
 		default:
 		}
-		msg, err := ReadMessage(p.rw)
+		msg, err := p.reader.ReadMessage()
 		if err != nil {
+			if _, ok := err.(*ErrMsgTooLarge); ok {
+				p.logger.Warnf("peer %s sent oversize message: %v", p.id, err)
+				p.conn.close()
+			}
 			return
 		}
 		p.handle(msg)
@@ -94,13 +154,21 @@ func (p *peer) handle(msg MessageReader) {
 		now := time.Now()
 		p.lastTime = now.Unix()
 	default:
+		proto := findProtocol(p.protos, msg.Type())
+		if proto == nil {
+			p.logger.Warnf("peer %s sent message type %d of no protocol", p.id, msg.Type())
+			return
+		}
 		bodyBs := msg.RawReader()
 		cpy := &messageReader{
 			raw:   bodyBs,
-			mType: msg.Type(),
+			mType: msg.Type() - proto.offset,
 			data:  bytes.NewReader(data),
 		}
-		p.psCh <- cpy // copy -> ps chan
+		select {
+		case proto.in <- cpy: // copy -> protocol chan
+		case <-p.close:
+		}
 	}
 }
 
@@ -112,21 +180,38 @@ func (p *peer) Read(bs []byte) (int, error) {
 	return p.readBuf.Read(bs)
 }
 
-func (p *peer) GetProtocolMsgCh() chan MessageReader {
-	return p.psCh
+// Stats returns the traffic exchanged with the peer so far.
+func (p *peer) Stats() TrafficStats {
+	return p.conn.meter.stats()
+}
+
+// Caps returns the capabilities announced by the peer.
+func (p *peer) Caps() []Cap {
+	return p.conn.remoteCaps
 }
 
-func (p *peer) WriteMessage(mType uint8, bs []byte) error {
-	return p.conn.writeMessage(mType, bs)
+// GetProtocolMsgCh returns the messages of the protocol, with codes relative to it.
+func (rw *protoRW) GetProtocolMsgCh() chan MessageReader {
+	return rw.in
+}
+
+// WriteMessage writes a message of the protocol, mType is relative to it.
+func (rw *protoRW) WriteMessage(mType uint8, bs []byte) error {
+	if mType >= rw.proto.Length() {
+		return fmt.Errorf("message type %d out of protocol %s range", mType, rw.proto.Cap())
+	}
+	return rw.conn.writeMessage(rw.offset+mType, bs)
 }
 
-func (p *peer) WriteMessageObj(mType uint8, obj interface{}) error {
-	bs, err := json.Marshal(obj)
+// WriteMessageObj encodes obj with its binary encoding when it implements
+// rawencode.RawEncoder, or as JSON otherwise, and writes it to the peer.
+func (rw *protoRW) WriteMessageObj(mType uint8, obj interface{}) error {
+	bs, err := rawencode.Encode(obj)
 	if err != nil {
 		return err
 	}
-	p.logger.Infof("peer write message type: %d, data: %x, obj: %v", mType, bs, obj)
-	return p.WriteMessage(mType, bs)
+	rw.logger.Infof("peer write message type: %d, data: %x, obj: %v", mType, bs, obj)
+	return rw.WriteMessage(mType, bs)
 }
 
 func (p *peer) pingLoop() {
@@ -168,9 +253,9 @@ func (p *peer) Run() {
 	go p.readLoop()
 	go p.pingLoop()
 	runProtocol := func() {
-		for _, item := range p.ps {
-			go func(p *peer, item Protocol) {
-				err := item.Run(p)
+		for _, item := range p.protos {
+			go func(p *peer, item *protoRW) {
+				err := item.proto.Run(item)
 
 				if err != nil {
 					p.Close()
@@ -3,11 +3,14 @@ package p2p
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
+	"xfsgo/crypto"
 	"xfsgo/p2p/discover"
 	"xfsgo/p2p/log"
 )
@@ -24,6 +27,11 @@ type peerConn struct {
 	version         uint8
 	handshakeStatus int
 	flag            int
+	meter           meter
+	noCompression   bool // do not announce snappy support
+	snappy          bool // both sides support snappy compressed messages
+	caps            []Cap
+	remoteCaps      []Cap
 }
 
 func (c *peerConn) serve() {
@@ -44,11 +52,82 @@ func (c *peerConn) serve() {
 		}
 	}
 	c.logger.Infof("p2p handshake success by %s", fromAddr)
+	if len(c.caps) > 0 && len(matchProtocols(c.server.protocols, c.remoteCaps)) == 0 {
+		c.logger.Warnf("no matching protocols with %s, local: %v, remote: %v", fromAddr, c.caps, c.remoteCaps)
+		c.close()
+		return
+	}
 
 	//Join node p2pserver node
 	c.server.addpeer <- c
 }
 
+// newHelloBody creates the handshake payload of the local side,
+// with a fresh ephemeral key and nonce for this session.
+func (c *peerConn) newHelloBody(receiveId discover.NodeId) (*helloBody, *ecdsa.PrivateKey, error) {
+	eph, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	body := &helloBody{
+		id:        c.self,
+		receiveId: receiveId,
+		ephemeral: elliptic.Marshal(elliptic.P256(), eph.X, eph.Y),
+	}
+	if !c.noCompression {
+		body.flags |= helloFlagSnappy
+	}
+	body.caps = c.caps
+	if _, err = rand.Read(body.nonce[:]); err != nil {
+		return nil, nil, err
+	}
+	return body, eph, nil
+}
+
+// signHello signs the handshake payload with the node key.
+func (c *peerConn) signHello(body *helloBody, mType uint8, remoteNonce []byte) error {
+	sig, err := crypto.ECDSASign(body.sigHash(c.version, mType, remoteNonce), c.key)
+	if err != nil {
+		return err
+	}
+	body.sign = sig
+	return nil
+}
+
+// verifyHello checks that the handshake payload was signed by the key
+// behind the node id it claims to come from.
+func verifyHello(version uint8, mType uint8, body *helloBody, remoteNonce []byte) error {
+	if len(body.sign) == 0 || int(body.sign[0]) >= len(body.sign) {
+		return errors.New("handshake signature malformed")
+	}
+	pub, err := crypto.ParsePubKeyFromSignature(body.sign)
+	if err != nil {
+		return fmt.Errorf("parse handshake signature err: %v", err)
+	}
+	signerId := discover.PubKey2NodeId(pub)
+	if !bytes.Equal(signerId[:], body.id[:]) {
+		return fmt.Errorf("handshake signer 0x%x not match node id 0x%x", signerId, body.id)
+	}
+	if !crypto.VerifySignature(body.sigHash(version, mType, remoteNonce), body.sign) {
+		return errors.New("handshake signature verify failed")
+	}
+	return nil
+}
+
+// upgrade switches the connection to the encrypted session derived from the handshake.
+func (c *peerConn) upgrade(eph *ecdsa.PrivateKey, remoteEph []byte, initNonce, respNonce []byte, initiator bool) error {
+	keys, err := deriveSessionKeys(eph, remoteEph, initNonce, respNonce, initiator)
+	if err != nil {
+		return err
+	}
+	sc, err := newSecureConn(c.rw, keys)
+	if err != nil {
+		return err
+	}
+	c.rw = sc
+	return nil
+}
+
 //Client handshake sending method
 func (c *peerConn) clientHandshake() error {
 
@@ -56,15 +135,20 @@ func (c *peerConn) clientHandshake() error {
 	if c.handshakeCompiled() {
 		return nil
 	}
+	body, eph, err := c.newHelloBody(c.id)
+	if err != nil {
+		return err
+	}
+	if err = c.signHello(body, typeHelloRequest, nil); err != nil {
+		return err
+	}
 	request := &helloRequestMsg{
 		version:   c.version,
-		id:        c.self,
-		receiveId: c.id,
+		helloBody: *body,
 	}
 	c.logger.Debugf("send hello request version: %d, id: %s, to receiveId: %s", c.version, c.self, c.id)
 	// send data
-	_, err := c.rw.Write(request.marshal())
-	if err != nil {
+	if _, err = c.rw.Write(request.marshal()); err != nil {
 		return err
 	}
 	// Read reply data
@@ -84,6 +168,18 @@ func (c *peerConn) clientHandshake() error {
 		return fmt.Errorf("handshake check err got my name: 0x%x, my real name: 0x%x",
 			gotId, wantId)
 	}
+	if !bytes.Equal(hello.id[:], c.id[:]) {
+		return fmt.Errorf("handshake check err got remote name: 0x%x, want remote name: 0x%x",
+			hello.id, c.id)
+	}
+	if err = verifyHello(hello.version, typeReHelloRequest, &hello.helloBody, body.nonce[:]); err != nil {
+		return err
+	}
+	if err = c.upgrade(eph, hello.ephemeral, body.nonce[:], hello.nonce[:], true); err != nil {
+		return err
+	}
+	c.snappy = body.flags&hello.flags&helloFlagSnappy != 0
+	c.remoteCaps = hello.caps
 	c.handshakeStatus = 1
 	return nil
 }
@@ -111,18 +207,33 @@ func (c *peerConn) serverHandshake() error {
 		return fmt.Errorf("handshake check err got my name: 0x%x, my real name: 0x%x",
 			gotId, wantId)
 	}
+	if err = verifyHello(hello.version, typeHelloRequest, &hello.helloBody, nil); err != nil {
+		return err
+	}
 	c.id = hello.id
 
+	body, eph, err := c.newHelloBody(hello.id)
+	if err != nil {
+		return err
+	}
+	if err = c.signHello(body, typeReHelloRequest, hello.nonce[:]); err != nil {
+		return err
+	}
 	reply := &helloReRequestMsg{
-		id:        c.self,
-		receiveId: hello.id,
 		version:   c.version,
+		helloBody: *body,
 	}
 
 	c.logger.Debugf("send handshake reply to nodeId %s", reply.receiveId)
 	if _, err = c.rw.Write(reply.marshal()); err != nil {
 		return err
 	}
+	if err = c.upgrade(eph, hello.ephemeral, hello.nonce[:], body.nonce[:], false); err != nil {
+		return err
+	}
+	c.snappy = body.flags&hello.flags&helloFlagSnappy != 0
+	c.remoteCaps = hello.caps
+	c.handshakeStatus = 1
 	return nil
 }
 
@@ -133,7 +244,8 @@ func (c *peerConn) readHelloReRequestMsg() (*helloReRequestMsg, error) {
 		return nil, err
 	}
 	if msg.Type() != typeReHelloRequest {
-		return nil, err
+		return nil, fmt.Errorf("handshake check err, got message type: %d, want type: %d",
+			msg.Type(), typeReHelloRequest)
 	}
 	nMsg := new(helloReRequestMsg)
 	raw, _ := ioutil.ReadAll(msg.RawReader())
@@ -150,7 +262,8 @@ func (c *peerConn) readHelloRequestMsg() (*helloRequestMsg, error) {
 		return nil, err
 	}
 	if msg.Type() != typeHelloRequest {
-		return nil, err
+		return nil, fmt.Errorf("handshake check err, got message type: %d, want type: %d",
+			msg.Type(), typeHelloRequest)
 	}
 	nMsg := new(helloRequestMsg)
 	raw, _ := ioutil.ReadAll(msg.RawReader())
@@ -160,18 +273,28 @@ func (c *peerConn) readHelloRequestMsg() (*helloRequestMsg, error) {
 	return nMsg, nil
 }
 
-// Write peer session messages
+// Write peer session messages, the data is compressed
+// when the peer supports it and it is large enough.
 func (c *peerConn) writeMessage(mType uint8, data []byte) error {
-	cLen := len(data)
+	version := c.version
+	payload := data
+	if c.snappy {
+		if enc := compressData(data); enc != nil {
+			version |= msgFlagSnappy
+			payload = enc
+		}
+	}
+	cLen := len(payload)
 	val := make([]byte, cLen+4)
 	binary.LittleEndian.PutUint32(val, uint32(cLen))
-	copy(val[4:], data)
-	msg := []byte{c.version, mType}
+	copy(val[4:], payload)
+	msg := []byte{version, mType}
 	msg = append(msg, val...)
 	_, err := c.rw.Write(msg)
 	if err != nil {
 		return err
 	}
+	c.meter.markEgress(len(msg), headerLen+len(data))
 	return nil
 }
 
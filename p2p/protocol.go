@@ -1,18 +1,121 @@
 package p2p
 
+import (
+	"fmt"
+	"sort"
+)
+
+// baseProtocolLength is the number of message codes reserved for p2p level
+// messages, the codes of the protocols are assigned after them.
+const baseProtocolLength uint8 = 16
+
+// maxCapNameLen bounds the name of a capability in the hello message.
+const maxCapNameLen = 32
+
+// Cap is a capability of a node, the name and version of a protocol
+// it runs. Capabilities are announced in the hello exchange.
+type Cap struct {
+	Name    string
+	Version uint8
+}
+
+func (c Cap) String() string {
+	return fmt.Sprintf("%s/%d", c.Name, c.Version)
+}
+
 // network protocol
 type Protocol interface {
+	// Cap returns the capability announced for the protocol.
+	Cap() Cap
+	// Length returns the number of message codes used by the protocol,
+	// which sends and receives codes from 0 to Length-1.
+	Length() uint8
 	Run(p Peer) error
 }
 
+// MsgSizeLimiter is implemented by protocols that bound the size of their messages.
+// Messages of a type without a limit may be as large as Config.MaxMsgSize.
+type MsgSizeLimiter interface {
+	MaxMsgSize(mType uint8) (uint32, bool)
+}
+
 type SimpleProtocol struct {
-	Func func(p Peer) error
+	Name    string
+	Version uint8
+	// MsgLength is the number of message codes of the protocol.
+	MsgLength uint8
+	Func      func(p Peer) error
+	// MsgSizeLimits maps message types of the protocol to their maximum size.
+	MsgSizeLimits map[uint8]uint32
+}
+
+func (sp *SimpleProtocol) Cap() Cap {
+	return Cap{Name: sp.Name, Version: sp.Version}
+}
+
+func (sp *SimpleProtocol) Length() uint8 {
+	return sp.MsgLength
 }
 
 func (sp *SimpleProtocol) Run(p Peer) error {
 	return sp.Func(p)
 }
 
+func (sp *SimpleProtocol) MaxMsgSize(mType uint8) (uint32, bool) {
+	limit, ok := sp.MsgSizeLimits[mType]
+	return limit, ok
+}
+
 // func Run(p Peer, ps []Protocol) {
 // 	peer := newPeer(p, ps)
 // }
+
+// protocolCaps returns the capabilities announced for the protocols ps.
+func protocolCaps(ps []Protocol) []Cap {
+	caps := make([]Cap, 0, len(ps))
+	for _, item := range ps {
+		caps = append(caps, item.Cap())
+	}
+	return caps
+}
+
+// matchProtocols returns the protocols of ps that the remote node announced in caps.
+// When both sides run several versions of a protocol, the highest one is used.
+// The matched protocols are ordered by name and get consecutive message
+// codes after the p2p level ones, so that both sides agree on the offsets.
+func matchProtocols(ps []Protocol, caps []Cap) []*protoRW {
+	remote := make(map[Cap]bool, len(caps))
+	for _, c := range caps {
+		remote[c] = true
+	}
+	best := make(map[string]Protocol)
+	for _, item := range ps {
+		c := item.Cap()
+		if !remote[c] {
+			continue
+		}
+		if old, ok := best[c.Name]; !ok || old.Cap().Version < c.Version {
+			best[c.Name] = item
+		}
+	}
+	names := make([]string, 0, len(best))
+	for name := range best {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]*protoRW, 0, len(names))
+	offset := int(baseProtocolLength)
+	for _, name := range names {
+		item := best[name]
+		if offset+int(item.Length()) > 256 {
+			break
+		}
+		result = append(result, &protoRW{
+			proto:  item,
+			offset: uint8(offset),
+			in:     make(chan MessageReader),
+		})
+		offset += int(item.Length())
+	}
+	return result
+}
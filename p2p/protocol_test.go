@@ -0,0 +1,146 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+	"xfsgo/crypto"
+	"xfsgo/p2p/discover"
+)
+
+func newTestSimpleProtocol(name string, version uint8, length uint8) *SimpleProtocol {
+	return &SimpleProtocol{
+		Name:      name,
+		Version:   version,
+		MsgLength: length,
+		Func: func(p Peer) error {
+			return nil
+		},
+	}
+}
+
+func TestMatchProtocols(t *testing.T) {
+	local := []Protocol{
+		newTestSimpleProtocol("xfs", 1, 8),
+		newTestSimpleProtocol("xfs", 2, 10),
+		newTestSimpleProtocol("mon", 1, 4),
+		newTestSimpleProtocol("light", 1, 4),
+	}
+	remote := []Cap{{"xfs", 1}, {"xfs", 2}, {"mon", 1}, {"light", 2}}
+	got := matchProtocols(local, remote)
+	want := []struct {
+		cap    Cap
+		offset uint8
+	}{
+		{Cap{"mon", 1}, baseProtocolLength},
+		{Cap{"xfs", 2}, baseProtocolLength + 4},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d protocols, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].proto.Cap() != w.cap || got[i].offset != w.offset {
+			t.Fatalf("got protocol %s at offset %d, want %s at %d",
+				got[i].proto.Cap(), got[i].offset, w.cap, w.offset)
+		}
+	}
+}
+
+func TestHelloBody_Caps(t *testing.T) {
+	key, _ := crypto.GenPrvKey()
+	c := newTestPeerConn(t, nil, key, nil)
+	c.caps = []Cap{{"xfs", 1}, {"mon", 2}}
+	body, _, err := c.newHelloBody(discover.NodeId{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.signHello(body, typeHelloRequest, nil); err != nil {
+		t.Fatal(err)
+	}
+	raw := body.marshal(version1, typeHelloRequest)
+	got := new(helloBody)
+	if _, ok := got.unmarshal(raw, typeHelloRequest); !ok {
+		t.Fatalf("unmarshal hello failed")
+	}
+	if len(got.caps) != 2 || got.caps[0] != c.caps[0] || got.caps[1] != c.caps[1] {
+		t.Fatalf("got caps %v, want %v", got.caps, c.caps)
+	}
+	if err = verifyHello(version1, typeHelloRequest, got, nil); err != nil {
+		t.Fatal(err)
+	}
+	// caps are covered by the signature
+	got.caps[1].Version = 3
+	if err = verifyHello(version1, typeHelloRequest, got, nil); err == nil {
+		t.Fatalf("verify hello with modified caps should fail")
+	}
+}
+
+func TestPeer_ProtocolRouting(t *testing.T) {
+	ps := []Protocol{
+		newTestSimpleProtocol("xfs", 1, 8),
+		newTestSimpleProtocol("mon", 1, 4),
+	}
+	clientKey, _ := crypto.GenPrvKey()
+	serverKey, _ := crypto.GenPrvKey()
+	serverId := discover.PubKey2NodeId(serverKey.PublicKey)
+	cRw, sRw := net.Pipe()
+	client := newTestPeerConn(t, cRw, clientKey, &serverId)
+	client.caps = protocolCaps(ps)
+	server := newTestPeerConn(t, sRw, serverKey, nil)
+	server.caps = protocolCaps(ps)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.serverHandshake()
+	}()
+	if err := client.clientHandshake(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	clientPeer := newPeer(client, ps, 0)
+	serverPeer := newPeer(server, ps, 0)
+	go serverPeer.readLoop()
+	defer close(serverPeer.close)
+
+	for _, proto := range clientPeer.protos {
+		go func(rw *protoRW) {
+			_ = rw.WriteMessage(2, []byte(rw.proto.Cap().Name))
+		}(proto)
+	}
+	for i := 0; i < len(ps); i++ {
+		select {
+		case msg := <-serverPeer.protos[0].GetProtocolMsgCh():
+			data, _ := msg.ReadAll()
+			if msg.Type() != 2 || string(data) != "mon" {
+				t.Fatalf("mon got type %d data %q", msg.Type(), data)
+			}
+		case msg := <-serverPeer.protos[1].GetProtocolMsgCh():
+			data, _ := msg.ReadAll()
+			if msg.Type() != 2 || string(data) != "xfs" {
+				t.Fatalf("xfs got type %d data %q", msg.Type(), data)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for protocol messages")
+		}
+	}
+	if err := clientPeer.protos[0].WriteMessage(4, nil); err == nil {
+		t.Fatalf("write message out of protocol range should fail")
+	}
+}
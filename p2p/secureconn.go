@@ -0,0 +1,285 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package p2p
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+const (
+	// length of an uncompressed P-256 ephemeral public key.
+	ephemeralKeyLen = 65
+	// length of the random nonce each side contributes to the handshake.
+	handshakeNonceLen = 32
+	// frame = sealed head + sealed payload + mac(32byte)
+	// head = payload length(4byte) + message type(1byte)
+	frameHeadLen = 5
+	// gcmOverhead is the tag length of the AES-GCM sealed parts.
+	gcmOverhead    = 16
+	frameHeaderLen = frameHeadLen + gcmOverhead
+	frameMacLen    = sha256.Size
+	// maxFrameSize bounds the sealed payload of a single frame.
+	maxFrameSize = 16 * 1024 * 1024
+)
+
+var (
+	errFrameTooLarge  = errors.New("secure frame too large")
+	errFrameMac       = errors.New("secure frame mac mismatch")
+	errFrameType      = errors.New("secure frame type mismatch")
+	errInvalidEphKey  = errors.New("invalid ephemeral public key")
+	errSessionCounter = errors.New("secure session counter exhausted")
+)
+
+// sessionKeys holds the symmetric keys negotiated by the handshake,
+// one pair (encryption, mac) for each direction of the connection.
+type sessionKeys struct {
+	egressEnc  []byte
+	egressMac  []byte
+	ingressEnc []byte
+	ingressMac []byte
+}
+
+// deriveSessionKeys computes the session keys from the ECDH shared secret
+// and the nonces of both sides. initiator reports whether the local side
+// dialed the connection, which decides the direction of each key.
+func deriveSessionKeys(prv *ecdsa.PrivateKey, remoteEph []byte, initNonce, respNonce []byte, initiator bool) (*sessionKeys, error) {
+	curve := elliptic.P256()
+	x, y := elliptic.Unmarshal(curve, remoteEph)
+	if x == nil {
+		return nil, errInvalidEphKey
+	}
+	sx, _ := curve.ScalarMult(x, y, prv.D.Bytes())
+	shared := make([]byte, 32)
+	sxBytes := sx.Bytes()
+	copy(shared[len(shared)-len(sxBytes):], sxBytes)
+	secret := kdf(shared, initNonce, respNonce)
+	i2rEnc := kdf(secret, []byte("xfs-i2r-enc"))
+	i2rMac := kdf(secret, []byte("xfs-i2r-mac"))
+	r2iEnc := kdf(secret, []byte("xfs-r2i-enc"))
+	r2iMac := kdf(secret, []byte("xfs-r2i-mac"))
+	if initiator {
+		return &sessionKeys{
+			egressEnc: i2rEnc, egressMac: i2rMac,
+			ingressEnc: r2iEnc, ingressMac: r2iMac,
+		}, nil
+	}
+	return &sessionKeys{
+		egressEnc: r2iEnc, egressMac: r2iMac,
+		ingressEnc: i2rEnc, ingressMac: i2rMac,
+	}, nil
+}
+
+func kdf(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// frameCipher seals or opens frames of one direction of a secure session.
+// The head and the payload of every frame are encrypted with AES-256-GCM
+// using a counter as nonce, and both are additionally authenticated by
+// HMAC-SHA256 keyed with the direction's mac key. The head is sealed on its
+// own so that the reader learns the payload length and message type before
+// reading the payload.
+type frameCipher struct {
+	aead    cipher.AEAD
+	mac     []byte
+	counter uint64
+}
+
+func newFrameCipher(encKey, macKey []byte) (*frameCipher, error) {
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &frameCipher{aead: aead, mac: macKey}, nil
+}
+
+func (fc *frameCipher) nonce() ([]byte, error) {
+	if fc.counter == ^uint64(0) {
+		return nil, errSessionCounter
+	}
+	nonce := make([]byte, fc.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], fc.counter)
+	fc.counter++
+	return nonce, nil
+}
+
+func (fc *frameCipher) frameMac(header, sealed []byte) []byte {
+	m := hmac.New(sha256.New, fc.mac)
+	m.Write(header)
+	m.Write(sealed)
+	return m.Sum(nil)
+}
+
+// seal returns the frame of the message data of type mType.
+func (fc *frameCipher) seal(mType uint8, data []byte) ([]byte, error) {
+	if len(data)+gcmOverhead > maxFrameSize {
+		return nil, errFrameTooLarge
+	}
+	headNonce, err := fc.nonce()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := fc.nonce()
+	if err != nil {
+		return nil, err
+	}
+	var head [frameHeadLen]byte
+	binary.LittleEndian.PutUint32(head[:], uint32(len(data)))
+	head[4] = mType
+	frame := make([]byte, 0, frameHeaderLen+len(data)+gcmOverhead+frameMacLen)
+	frame = fc.aead.Seal(frame, headNonce, head[:], nil)
+	frame = fc.aead.Seal(frame, nonce, data, frame[:frameHeaderLen])
+	frame = append(frame, fc.frameMac(frame[:frameHeaderLen], frame[frameHeaderLen:])...)
+	return frame, nil
+}
+
+// openHead returns the payload length and message type of the sealed head of a frame.
+func (fc *frameCipher) openHead(header []byte) (uint32, uint8, error) {
+	nonce, err := fc.nonce()
+	if err != nil {
+		return 0, 0, err
+	}
+	head, err := fc.aead.Open(nil, nonce, header, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	return binary.LittleEndian.Uint32(head), head[4], nil
+}
+
+// open returns the payload of a frame whose head was opened by openHead.
+func (fc *frameCipher) open(header, sealed, mac []byte) ([]byte, error) {
+	if !hmac.Equal(mac, fc.frameMac(header, sealed)) {
+		return nil, errFrameMac
+	}
+	nonce, err := fc.nonce()
+	if err != nil {
+		return nil, err
+	}
+	return fc.aead.Open(nil, nonce, sealed, header)
+}
+
+// secureConn wraps a handshaked net.Conn, encrypting every Write as a
+// single frame and transparently decrypting incoming frames on Read.
+type secureConn struct {
+	net.Conn
+	wmu     sync.Mutex
+	egress  *frameCipher
+	ingress *frameCipher
+	readBuf bytes.Buffer
+	// limit returns the size limit of the messages of a type, the frames
+	// are only bounded by maxFrameSize when it is nil.
+	limit func(mType uint8) uint32
+}
+
+func newSecureConn(conn net.Conn, keys *sessionKeys) (*secureConn, error) {
+	egress, err := newFrameCipher(keys.egressEnc, keys.egressMac)
+	if err != nil {
+		return nil, err
+	}
+	ingress, err := newFrameCipher(keys.ingressEnc, keys.ingressMac)
+	if err != nil {
+		return nil, err
+	}
+	return &secureConn{
+		Conn:    conn,
+		egress:  egress,
+		ingress: ingress,
+	}, nil
+}
+
+// Write seals p into one frame and writes it to the underlying connection,
+// p must be a whole message.
+func (sc *secureConn) Write(p []byte) (int, error) {
+	sc.wmu.Lock()
+	defer sc.wmu.Unlock()
+	var mType uint8
+	if len(p) >= headerLen {
+		mType = p[1]
+	}
+	frame, err := sc.egress.seal(mType, p)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = sc.Conn.Write(frame); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read returns decrypted bytes, reading the next frames when the buffer is drained.
+func (sc *secureConn) Read(p []byte) (int, error) {
+	for sc.readBuf.Len() == 0 {
+		if err := sc.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+	return sc.readBuf.Read(p)
+}
+
+func (sc *secureConn) readFrame() error {
+	header := make([]byte, frameHeaderLen)
+	if _, err := io.ReadFull(sc.Conn, header); err != nil {
+		return err
+	}
+	n, mType, err := sc.ingress.openHead(header)
+	if err != nil {
+		return fmt.Errorf("open secure frame err: %v", err)
+	}
+	// check the limit of the message type before allocating the payload
+	if n+gcmOverhead > maxFrameSize {
+		return errFrameTooLarge
+	}
+	if sc.limit != nil && n > headerLen {
+		if max := sc.limit(mType); n-headerLen > max {
+			return &ErrMsgTooLarge{Type: mType, Size: n - headerLen, Limit: max}
+		}
+	}
+	sealedLen := int(n) + gcmOverhead
+	body := make([]byte, sealedLen+frameMacLen)
+	if _, err = io.ReadFull(sc.Conn, body); err != nil {
+		return err
+	}
+	data, err := sc.ingress.open(header, body[:sealedLen], body[sealedLen:])
+	if err != nil {
+		return fmt.Errorf("open secure frame err: %v", err)
+	}
+	if len(data) >= headerLen && data[1] != mType {
+		return errFrameType
+	}
+	sc.readBuf.Write(data)
+	return nil
+}
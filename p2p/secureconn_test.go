@@ -0,0 +1,195 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package p2p
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"net"
+	"testing"
+	"xfsgo/crypto"
+	"xfsgo/p2p/discover"
+	"xfsgo/p2p/log"
+)
+
+func newTestPeerConn(t *testing.T, rw net.Conn, key *ecdsa.PrivateKey, dst *discover.NodeId) *peerConn {
+	c := &peerConn{
+		logger:  log.DefaultLogger(),
+		self:    discover.PubKey2NodeId(key.PublicKey),
+		key:     key,
+		rw:      rw,
+		version: version1,
+	}
+	if dst != nil {
+		c.id = *dst
+	}
+	return c
+}
+
+func testHandshake(t *testing.T, clientKey, serverKey *ecdsa.PrivateKey, dst discover.NodeId) (*peerConn, *peerConn, error, error) {
+	cRw, sRw := net.Pipe()
+	client := newTestPeerConn(t, cRw, clientKey, &dst)
+	server := newTestPeerConn(t, sRw, serverKey, nil)
+	errc := make(chan error, 1)
+	go func() {
+		err := server.serverHandshake()
+		if err != nil {
+			_ = sRw.Close()
+		}
+		errc <- err
+	}()
+	cErr := client.clientHandshake()
+	if cErr != nil {
+		_ = cRw.Close()
+	}
+	sErr := <-errc
+	return client, server, cErr, sErr
+}
+
+func TestPeerConn_Handshake(t *testing.T) {
+	clientKey, _ := crypto.GenPrvKey()
+	serverKey, _ := crypto.GenPrvKey()
+	serverId := discover.PubKey2NodeId(serverKey.PublicKey)
+	client, server, cErr, sErr := testHandshake(t, clientKey, serverKey, serverId)
+	if cErr != nil || sErr != nil {
+		t.Fatalf("handshake err: client %v, server %v", cErr, sErr)
+	}
+	if server.id != client.self {
+		t.Fatalf("server got remote id %s, want %s", server.id, client.self)
+	}
+	if _, ok := client.rw.(*secureConn); !ok {
+		t.Fatalf("client connection not upgraded")
+	}
+	want := []byte("hello secure world")
+	go func() {
+		_ = client.writeMessage(typePingMsg, want)
+	}()
+	msg, err := server.readMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := msg.ReadAll()
+	if msg.Type() != typePingMsg || !bytes.Equal(got, want) {
+		t.Fatalf("got type %d data %q, want type %d data %q", msg.Type(), got, typePingMsg, want)
+	}
+	go func() {
+		_ = server.writeMessage(typePongMsg, want)
+	}()
+	msg, err = client.readMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = msg.ReadAll()
+	if msg.Type() != typePongMsg || !bytes.Equal(got, want) {
+		t.Fatalf("got type %d data %q, want type %d data %q", msg.Type(), got, typePongMsg, want)
+	}
+}
+
+func TestPeerConn_HandshakeImpersonation(t *testing.T) {
+	clientKey, _ := crypto.GenPrvKey()
+	serverKey, _ := crypto.GenPrvKey()
+	otherKey, _ := crypto.GenPrvKey()
+	// dial the id of otherKey, but the remote only owns serverKey
+	otherId := discover.PubKey2NodeId(otherKey.PublicKey)
+	_, _, cErr, sErr := testHandshake(t, clientKey, serverKey, otherId)
+	if cErr == nil && sErr == nil {
+		t.Fatalf("handshake with impersonated node should fail")
+	}
+}
+
+func TestVerifyHello_ForgedId(t *testing.T) {
+	key, _ := crypto.GenPrvKey()
+	otherKey, _ := crypto.GenPrvKey()
+	c := newTestPeerConn(t, nil, key, nil)
+	body, _, err := c.newHelloBody(discover.NodeId{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// claim another node id with our own signature
+	body.id = discover.PubKey2NodeId(otherKey.PublicKey)
+	if err = c.signHello(body, typeHelloRequest, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = verifyHello(version1, typeHelloRequest, body, nil); err == nil {
+		t.Fatalf("verify hello with forged id should fail")
+	}
+}
+
+func TestFrameCipher_Tamper(t *testing.T) {
+	key, _ := crypto.GenPrvKey()
+	eph, _ := crypto.GenPrvKey()
+	c := newTestPeerConn(t, nil, key, nil)
+	body, _, err := c.newHelloBody(discover.NodeId{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, handshakeNonceLen)
+	keys, err := deriveSessionKeys(eph, body.ephemeral, nonce, nonce, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealer, _ := newFrameCipher(keys.egressEnc, keys.egressMac)
+	opener, _ := newFrameCipher(keys.egressEnc, keys.egressMac)
+	frame, err := sealer.seal(typePingMsg, []byte("transfer 100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame[frameHeaderLen] ^= 0x01
+	macStart := len(frame) - frameMacLen
+	if _, _, err = opener.openHead(frame[:frameHeaderLen]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = opener.open(frame[:frameHeaderLen], frame[frameHeaderLen:macStart], frame[macStart:]); err == nil {
+		t.Fatalf("open tampered frame should fail")
+	}
+}
+
+func TestSecureConn_FrameLimit(t *testing.T) {
+	eph, _ := crypto.GenPrvKey()
+	remote, _ := crypto.GenPrvKey()
+	nonce := make([]byte, handshakeNonceLen)
+	remoteEph := elliptic.Marshal(elliptic.P256(), remote.PublicKey.X, remote.PublicKey.Y)
+	keys, err := deriveSessionKeys(eph, remoteEph, nonce, nonce, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cRw, sRw := net.Pipe()
+	defer cRw.Close()
+	writer, _ := newSecureConn(cRw, keys)
+	reader, _ := newSecureConn(sRw, &sessionKeys{
+		ingressEnc: keys.egressEnc, ingressMac: keys.egressMac,
+		egressEnc: keys.ingressEnc, egressMac: keys.ingressMac,
+	})
+	reader.limit = func(mType uint8) uint32 {
+		if mType == typePingMsg {
+			return 16
+		}
+		return 1024
+	}
+	go func() {
+		msg := make([]byte, headerLen+512)
+		msg[1] = typePingMsg
+		_, _ = writer.Write(msg)
+	}()
+	_, err = reader.Read(make([]byte, 1))
+	if e, ok := err.(*ErrMsgTooLarge); !ok || e.Type != typePingMsg || e.Size != 512 {
+		t.Fatalf("got err %v, want message too large", err)
+	}
+	// the payload is left unread
+	_ = sRw.Close()
+}
@@ -38,7 +38,7 @@ type server struct {
 	protocols []Protocol
 
 	addpeer    chan *peerConn
-	delpeer    chan Peer
+	delpeer    chan *peer
 	table      *discover.Table
 	logger     log.Logger
 	lastLookup time.Time
@@ -56,7 +56,12 @@ type Config struct {
 	StaticNodes     []*discover.Node
 	BootstrapNodes  []*discover.Node
 	MaxPeers        int
-	Logger          log.Logger
+	// MaxMsgSize bounds messages without a protocol specific limit,
+	// DefaultMaxMsgSize is used when it is zero.
+	MaxMsgSize uint32
+	// DisableCompression keeps messages uncompressed, even with peers supporting it.
+	DisableCompression bool
+	Logger             log.Logger
 }
 
 // NewServer Creates background service object
@@ -99,7 +104,7 @@ func (srv *server) Start() error {
 	srv.running = true
 	// Peer to peer session entity
 	srv.addpeer = make(chan *peerConn)
-	srv.delpeer = make(chan Peer)
+	srv.delpeer = make(chan *peer)
 	srv.quit = make(chan struct{})
 	srv.natm = &nat.DefaultListener{}
 	var err error
@@ -126,7 +131,7 @@ func (srv *server) Start() error {
 }
 
 func (srv *server) run(dialer *dialstate) {
-	peers := make(map[discover.NodeId]Peer)
+	peers := make(map[discover.NodeId]*peer)
 	tasks := make([]task, 0)
 	pendingTasks := make([]task, 0)
 	taskdone := make(chan task)
@@ -172,7 +177,7 @@ running:
 			break running
 		// add peer
 		case c := <-srv.addpeer:
-			p := newPeer(c, srv.protocols)
+			p := newPeer(c, srv.protocols, srv.config.MaxMsgSize)
 			peers[c.id] = p
 			srv.logger.Infof("save peer id to peers: %s", c.id)
 			go srv.runPeer(p)
@@ -187,7 +192,7 @@ running:
 	}
 }
 
-func (srv *server) runPeer(peer Peer) {
+func (srv *server) runPeer(peer *peer) {
 	peer.Run()
 	srv.delpeer <- peer
 }
@@ -242,6 +247,9 @@ func (srv *server) newPeerConn(rw net.Conn, flag int, dst *discover.NodeId) *pee
 		key:     srv.config.Key,
 		rw:      rw,
 		version: srv.config.ProtocolVersion,
+		caps:    protocolCaps(srv.protocols),
+
+		noCompression: srv.config.DisableCompression,
 	}
 	if dst != nil {
 		c.id = *dst
@@ -31,6 +31,14 @@ type testProto struct {
 	t *testing.T
 }
 
+func (tp *testProto) Cap() Cap {
+	return Cap{Name: "test", Version: 1}
+}
+
+func (tp *testProto) Length() uint8 {
+	return 1
+}
+
 func (tp *testProto) Run(p Peer) error {
 	tp.t.Logf("join peer: %s", p.ID())
 	return nil
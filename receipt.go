@@ -25,6 +25,9 @@ import (
 
 type Receipt struct {
 	TxHash common.Hash `json:"tx_hash"`
+	// legacy receipts belong to the blocks before BlockVersion and keep
+	// the JSON encoding, see Transaction.
+	legacy bool
 }
 
 func NewReceipt(txHash common.Hash) *Receipt {
@@ -32,12 +35,30 @@ func NewReceipt(txHash common.Hash) *Receipt {
 		TxHash: txHash,
 	}
 }
+// Encode returns the canonical binary encoding of the receipt, or the JSON
+// encoding of a legacy receipt.
+// receipt = format(1byte)+tx_hash(32byte)
 func (r *Receipt) Encode() ([]byte, error) {
-	return json.Marshal(r)
+	if r.legacy {
+		return json.Marshal(r)
+	}
+	w := rawencode.NewBinaryWriter()
+	w.WriteUint8(binaryFormatV1)
+	w.WriteFixed(r.TxHash[:])
+	return w.Bytes(), nil
 }
 
+// Decode parses the binary encoding of the receipt, or its legacy JSON encoding.
 func (r *Receipt) Decode(data []byte) error {
-	return json.Unmarshal(data, r)
+	if rawencode.IsLegacyJSON(data) {
+		return json.Unmarshal(data, r)
+	}
+	br := rawencode.NewBinaryReader(data)
+	if br.ReadUint8() != binaryFormatV1 {
+		return rawencode.ErrFormatVersion
+	}
+	br.ReadFixed(r.TxHash[:])
+	return br.Finish()
 }
 
 func (r *Receipt) Hash() common.Hash {
@@ -0,0 +1,266 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"encoding"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"xfsgo/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	discoverMethod    = "rpc.discover"
+	openRPCVersion    = "1.2.6"
+	openRPCTitle      = "xfsgo JSON-RPC API"
+	openRPCAPIVersion = "1.0.0"
+)
+
+// OpenRPCDoc is the OpenRPC document describing the methods served on a transport.
+type OpenRPCDoc struct {
+	OpenRPC string           `json:"openrpc"`
+	Info    OpenRPCInfo      `json:"info"`
+	Methods []*OpenRPCMethod `json:"methods"`
+}
+
+type OpenRPCInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenRPCMethod describes a method, its params are the fields of its
+// argument struct, given by name or in order.
+type OpenRPCMethod struct {
+	Name           string                      `json:"name"`
+	ParamStructure string                      `json:"paramStructure"`
+	Params         []*OpenRPCContentDescriptor `json:"params"`
+	Result         *OpenRPCContentDescriptor   `json:"result"`
+}
+
+type OpenRPCContentDescriptor struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+}
+
+var (
+	typeOfJSONMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	typeOfTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	typeOfJSONNumber    = reflect.TypeOf(json.Number(""))
+
+	// knownSchemas are the schemas of the types with their own JSON encoding.
+	knownSchemas = map[reflect.Type]map[string]interface{}{
+		reflect.TypeOf(common.Hash{}):    {"type": "string"},
+		reflect.TypeOf(common.Address{}): {"type": "string"},
+		reflect.TypeOf(big.Int{}):        {"type": "integer"},
+		typeOfJSONNumber:                 {"type": []string{"number", "string"}},
+	}
+)
+
+// typeSchema returns the JSON schema of the encoding of t, seen holds the
+// structs being described to stop at recursive types.
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if schema, ok := knownSchemas[t]; ok {
+		return schema
+	}
+	if t.Implements(typeOfJSONMarshaler) || reflect.PtrTo(t).Implements(typeOfJSONMarshaler) {
+		return map[string]interface{}{}
+	}
+	if t.Implements(typeOfTextMarshaler) || reflect.PtrTo(t).Implements(typeOfTextMarshaler) {
+		return map[string]interface{}{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			// base64
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem(), seen),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), seen),
+		}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		properties := make(map[string]interface{})
+		for _, field := range structFields(t) {
+			properties[field.name] = typeSchema(field.typ, seen)
+		}
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+		}
+	}
+	// interface{} and the types without a JSON encoding
+	return map[string]interface{}{}
+}
+
+type jsonField struct {
+	name string
+	typ  reflect.Type
+}
+
+// structFields returns the fields of a struct encoded by encoding/json with
+// their names, the fields of embedded structs included.
+func structFields(t reflect.Type) []jsonField {
+	fields := make([]jsonField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, structFields(ft)...)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{name: name, typ: f.Type})
+	}
+	return fields
+}
+
+// methodDoc describes a registered method, see decodeParams for its params.
+func methodDoc(name string, mtype *methodType) *OpenRPCMethod {
+	m := &OpenRPCMethod{
+		Name:           name,
+		ParamStructure: "either",
+		Params:         make([]*OpenRPCContentDescriptor, 0),
+		Result: &OpenRPCContentDescriptor{
+			Name:   "result",
+			Schema: typeSchema(mtype.ReplyType, make(map[reflect.Type]bool)),
+		},
+	}
+	argType := mtype.ArgType
+	for argType.Kind() == reflect.Ptr {
+		argType = argType.Elem()
+	}
+	if argType.Kind() == reflect.Struct {
+		for _, field := range structFields(argType) {
+			m.Params = append(m.Params, &OpenRPCContentDescriptor{
+				Name:   field.name,
+				Schema: typeSchema(field.typ, make(map[reflect.Type]bool)),
+			})
+		}
+	} else if argType.Kind() != reflect.Interface {
+		m.ParamStructure = "by-position"
+		m.Params = append(m.Params, &OpenRPCContentDescriptor{
+			Name:   "args",
+			Schema: typeSchema(argType, make(map[reflect.Type]bool)),
+		})
+	}
+	return m
+}
+
+// discover returns the document of the methods served with modules,
+// the subscription methods included on websocket connections.
+func (server *RPCServer) discover(modules moduleSet, websocket bool) *OpenRPCDoc {
+	doc := &OpenRPCDoc{
+		OpenRPC: openRPCVersion,
+		Info: OpenRPCInfo{
+			Title:   openRPCTitle,
+			Version: openRPCAPIVersion,
+		},
+		Methods: make([]*OpenRPCMethod, 0),
+	}
+	for name, s := range server.serviceMap {
+		if !modules.has(name) {
+			continue
+		}
+		for mname, mtype := range s.methods {
+			doc.Methods = append(doc.Methods, methodDoc(name+"."+mname, mtype))
+		}
+	}
+	if websocket {
+		stringSchema := map[string]interface{}{"type": "string"}
+		doc.Methods = append(doc.Methods,
+			&OpenRPCMethod{
+				Name:           subscribeMethod,
+				ParamStructure: "either",
+				Params:         []*OpenRPCContentDescriptor{{Name: "topic", Schema: stringSchema}},
+				Result:         &OpenRPCContentDescriptor{Name: "subscription", Schema: stringSchema},
+			},
+			&OpenRPCMethod{
+				Name:           unsubscribeMethod,
+				ParamStructure: "either",
+				Params:         []*OpenRPCContentDescriptor{{Name: "id", Schema: stringSchema}},
+				Result: &OpenRPCContentDescriptor{
+					Name:   "result",
+					Schema: map[string]interface{}{"type": "boolean"},
+				},
+			})
+	}
+	doc.Methods = append(doc.Methods, &OpenRPCMethod{
+		Name:           discoverMethod,
+		ParamStructure: "either",
+		Params:         make([]*OpenRPCContentDescriptor, 0),
+		Result: &OpenRPCContentDescriptor{
+			Name:   "openrpc",
+			Schema: map[string]interface{}{"type": "object"},
+		},
+	})
+	sort.Slice(doc.Methods, func(i, j int) bool {
+		return doc.Methods[i].Name < doc.Methods[j].Name
+	})
+	return doc
+}
+
+// handleOpenRPC serves the document of the HTTP methods of ListenAddr.
+func (server *RPCServer) handleOpenRPC(c *gin.Context) {
+	c.JSON(200, server.discover(server.httpMods, false))
+}
+
+// handleAdminOpenRPC serves the document of the methods of the admin socket.
+func (server *RPCServer) handleAdminOpenRPC(c *gin.Context) {
+	c.JSON(200, server.discover(nil, false))
+}
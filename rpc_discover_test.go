@@ -0,0 +1,120 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"xfsgo/common"
+)
+
+type testSchemaInner struct {
+	Height uint64 `json:"height"`
+}
+
+type testSchemaStruct struct {
+	testSchemaInner
+	Hash    common.Hash       `json:"hash"`
+	Tags    []string          `json:"tags,omitempty"`
+	Data    []byte            `json:"data"`
+	Extra   map[string]bool   `json:"extra"`
+	Next    *testSchemaStruct `json:"next"`
+	Skipped string            `json:"-"`
+	NoTag   bool
+	private int
+}
+
+func TestTypeSchema(t *testing.T) {
+	got, err := json.Marshal(typeSchema(reflect.TypeOf(&testSchemaStruct{}), make(map[reflect.Type]bool)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"properties":{"NoTag":{"type":"boolean"},"data":{"type":"string"},` +
+		`"extra":{"additionalProperties":{"type":"boolean"},"type":"object"},` +
+		`"hash":{"type":"string"},"height":{"type":"integer"},"next":{"type":"object"},` +
+		`"tags":{"items":{"type":"string"},"type":"array"}},"type":"object"}`
+	if string(got) != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func findMethod(doc *OpenRPCDoc, name string) *OpenRPCMethod {
+	for _, m := range doc.Methods {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestRPCServer_Discover(t *testing.T) {
+	server, ts := newTestRPCServer(t, &RPCConfig{HTTPModules: []string{"Test"}})
+	if err := server.RegisterName("Hidden", &testRPCService{}); err != nil {
+		t.Fatal(err)
+	}
+	_, body := postRPC(t, ts.Client(), ts.URL, `{"jsonrpc":"2.0","id":1,"method":"rpc.discover"}`, "")
+	var resp struct {
+		Result *OpenRPCDoc `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatal(err)
+	}
+	doc := resp.Result
+	if doc == nil || doc.OpenRPC != openRPCVersion {
+		t.Fatalf("got %s, want an OpenRPC document", body)
+	}
+	echo := findMethod(doc, "Test.Echo")
+	if echo == nil {
+		t.Fatal("Test.Echo not listed")
+	}
+	if len(echo.Params) != 2 || echo.Params[0].Name != "name" || echo.Params[1].Name != "count" {
+		t.Fatalf("got params %+v, want name and count", echo.Params)
+	}
+	if echo.Result.Schema["type"] != "string" {
+		t.Fatalf("got result schema %v, want a string", echo.Result.Schema)
+	}
+	if findMethod(doc, "Hidden.Echo") != nil {
+		t.Fatal("method of a namespace not served listed")
+	}
+	if findMethod(doc, subscribeMethod) != nil {
+		t.Fatal("subscription method listed over HTTP")
+	}
+	if findMethod(doc, discoverMethod) == nil {
+		t.Fatal("rpc.discover not listed")
+	}
+
+	res, err := ts.Client().Get(ts.URL + "/openrpc.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc = new(OpenRPCDoc)
+	if err = json.Unmarshal(data, doc); err != nil {
+		t.Fatal(err)
+	}
+	if findMethod(doc, "Test.Echo") == nil || findMethod(doc, "Hidden.Echo") != nil {
+		t.Fatalf("got document %s, want the methods of Test", data)
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsSendBuffer is the number of messages queued for a websocket
+	// connection, a client which falls further behind is disconnected.
+	wsSendBuffer = 256
+	// maxWSSubscriptions is the number of subscriptions of a websocket connection.
+	maxWSSubscriptions = 32
+
+	subscribeMethod    = "Subscribe"
+	unsubscribeMethod  = "Unsubscribe"
+	notificationMethod = "subscription"
+)
+
+var (
+	notificationsUnsupportedError = NewRPCError(-32601, "notifications not supported")
+	unknownTopicError             = NewRPCError(-32602, "unknown subscription topic")
+	unknownSubscriptionError      = NewRPCError(-32602, "subscription not found")
+	tooManySubscriptionsError     = NewRPCError(-32008, "too many subscriptions")
+)
+
+// wsConn queues the messages of a websocket connection for its writer,
+// gorilla connections don't support concurrent writers.
+type wsConn struct {
+	conn      *websocket.Conn
+	send      chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+	subs      map[string]string // subscription id to topic
+}
+
+func newWSConn(conn *websocket.Conn) *wsConn {
+	c := &wsConn{
+		conn:   conn,
+		send:   make(chan []byte, wsSendBuffer),
+		closed: make(chan struct{}),
+		subs:   make(map[string]string),
+	}
+	go c.writeLoop()
+	return c
+}
+
+func (c *wsConn) writeLoop() {
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.close()
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// write queues a response, waiting while the queue is full.
+func (c *wsConn) write(msg []byte) {
+	select {
+	case c.send <- msg:
+	case <-c.closed:
+	}
+}
+
+// notify queues a notification and reports false when the queue is full.
+func (c *wsConn) notify(msg []byte) bool {
+	select {
+	case c.send <- msg:
+		return true
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *wsConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		_ = c.conn.Close()
+	})
+}
+
+// subscriptionHub dispatches the notifications of the topics to the
+// connections subscribed to them.
+type subscriptionHub struct {
+	mu     sync.RWMutex
+	topics map[string]map[string]*wsConn
+	// namespaces holds the namespace of each topic, a client needs
+	// access to it to subscribe.
+	namespaces map[string]string
+}
+
+func newSubscriptionHub() *subscriptionHub {
+	return &subscriptionHub{
+		topics:     make(map[string]map[string]*wsConn),
+		namespaces: make(map[string]string),
+	}
+}
+
+func newSubscriptionID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return "0x" + hex.EncodeToString(id[:])
+}
+
+func (h *subscriptionHub) addTopic(topic, namespace string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.topics[topic]; !ok {
+		h.topics[topic] = make(map[string]*wsConn)
+	}
+	h.namespaces[topic] = namespace
+}
+
+func (h *subscriptionHub) subscribe(c *wsConn, topic string, modules moduleSet) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subs, ok := h.topics[topic]
+	if !ok {
+		return "", unknownTopicError
+	}
+	if !modules.has(h.namespaces[topic]) {
+		return "", methodNotFoundError
+	}
+	if len(c.subs) >= maxWSSubscriptions {
+		return "", tooManySubscriptionsError
+	}
+	id := newSubscriptionID()
+	subs[id] = c
+	c.subs[id] = topic
+	return id, nil
+}
+
+func (h *subscriptionHub) unsubscribe(c *wsConn, id string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	topic, ok := c.subs[id]
+	if !ok {
+		return unknownSubscriptionError
+	}
+	delete(h.topics[topic], id)
+	delete(c.subs, id)
+	return nil
+}
+
+// unsubscribeAll drops the subscriptions of a closed connection.
+func (h *subscriptionHub) unsubscribeAll(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, topic := range c.subs {
+		delete(h.topics[topic], id)
+		delete(c.subs, id)
+	}
+}
+
+func (h *subscriptionHub) notify(topic string, result interface{}) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	subs := h.topics[topic]
+	if len(subs) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	for id, c := range subs {
+		msg, _ := json.Marshal(map[string]interface{}{
+			"jsonrpc": jsonrpcVersion,
+			"method":  notificationMethod,
+			"params": map[string]interface{}{
+				"subscription": id,
+				"result":       json.RawMessage(data),
+			},
+		})
+		if !c.notify(msg) {
+			// the connection is dropped by its read loop
+			c.close()
+		}
+	}
+	return nil
+}
+
+// RegisterTopic adds a topic websocket clients can subscribe to with the
+// Subscribe method when they may call the namespace, its notifications
+// are sent by Notify.
+func (server *RPCServer) RegisterTopic(topic, namespace string) {
+	server.subs.addTopic(topic, namespace)
+}
+
+// Notify sends result to the subscribers of topic. Subscribers which
+// don't keep up with their notifications are disconnected.
+func (server *RPCServer) Notify(topic string, result interface{}) error {
+	return server.subs.notify(topic, result)
+}
+
+// stringParam returns the first element of params given as an array,
+// or the value of name when given as an object.
+func stringParam(params json.RawMessage, name string) (string, bool) {
+	var arr []string
+	if err := json.Unmarshal(params, &arr); err == nil {
+		if len(arr) > 0 {
+			return arr[0], true
+		}
+		return "", false
+	}
+	var obj map[string]string
+	if err := json.Unmarshal(params, &obj); err == nil {
+		s, ok := obj[name]
+		return s, ok
+	}
+	return "", false
+}
+
+// callSubscription handles the Subscribe and Unsubscribe methods, which
+// are bound to the connection they are called on, modules are the
+// namespaces the client may call.
+func (server *RPCServer) callSubscription(c *wsConn, obj *jsonRPCObj, modules moduleSet) (interface{}, error) {
+	if c == nil {
+		return nil, notificationsUnsupportedError
+	}
+	if obj.Method == subscribeMethod {
+		topic, ok := stringParam(obj.Params, "topic")
+		if !ok {
+			return nil, invalidParamsError
+		}
+		return server.subs.subscribe(c, topic, modules)
+	}
+	id, ok := stringParam(obj.Params, "id")
+	if !ok {
+		return nil, invalidParamsError
+	}
+	if err := server.subs.unsubscribe(c, id); err != nil {
+		return nil, err
+	}
+	return true, nil
+}
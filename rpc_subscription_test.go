@@ -0,0 +1,155 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func newTestWSServer(t *testing.T, config *RPCConfig) (*RPCServer, *websocket.Conn) {
+	server := NewRPCServer(config)
+	server.ginEngine.Any("/", server.handle)
+	ts := httptest.NewServer(server.ginEngine)
+	t.Cleanup(ts.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return server, conn
+}
+
+type testWSMessage struct {
+	ID     *int                   `json:"id"`
+	Method string                 `json:"method"`
+	Result json.RawMessage        `json:"result"`
+	Error  *jsonRPCRespErr        `json:"error"`
+	Params map[string]interface{} `json:"params"`
+}
+
+func wsCall(t *testing.T, conn *websocket.Conn, method string, params interface{}) *testWSMessage {
+	req, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0", "id": 1, "method": method, "params": params,
+	})
+	if err := conn.WriteMessage(websocket.TextMessage, req); err != nil {
+		t.Fatal(err)
+	}
+	return wsRead(t, conn)
+}
+
+func wsRead(t *testing.T, conn *websocket.Conn) *testWSMessage {
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &testWSMessage{}
+	if err = json.Unmarshal(data, msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestRPCServer_Subscribe(t *testing.T) {
+	server, conn := newTestWSServer(t, &RPCConfig{})
+	server.RegisterTopic("newHeads", "Chain")
+	if resp := wsCall(t, conn, "Subscribe", []string{"unknown"}); resp.Error == nil {
+		t.Fatalf("subscribed to an unknown topic")
+	}
+	resp := wsCall(t, conn, "Subscribe", map[string]string{"topic": "newHeads"})
+	var id string
+	if resp.Error != nil || json.Unmarshal(resp.Result, &id) != nil {
+		t.Fatalf("subscribe failed: %+v", resp.Error)
+	}
+	if err := server.Notify("newHeads", map[string]int{"height": 7}); err != nil {
+		t.Fatal(err)
+	}
+	n := wsRead(t, conn)
+	if n.Method != notificationMethod || n.ID != nil || n.Params["subscription"] != id {
+		t.Fatalf("got notification %+v", n)
+	}
+	if result := n.Params["result"].(map[string]interface{}); result["height"] != float64(7) {
+		t.Fatalf("got result %v", result)
+	}
+	resp = wsCall(t, conn, "Unsubscribe", []string{id})
+	if resp.Error != nil || string(resp.Result) != "true" {
+		t.Fatalf("unsubscribe failed: %+v", resp.Error)
+	}
+	if resp = wsCall(t, conn, "Unsubscribe", []string{id}); resp.Error == nil {
+		t.Fatalf("unsubscribed twice")
+	}
+	server.subs.mu.RLock()
+	defer server.subs.mu.RUnlock()
+	if len(server.subs.topics["newHeads"]) != 0 {
+		t.Fatalf("subscription not removed")
+	}
+}
+
+func TestRPCServer_SlowSubscriber(t *testing.T) {
+	server, conn := newTestWSServer(t, &RPCConfig{})
+	server.RegisterTopic("newHeads", "Chain")
+	if resp := wsCall(t, conn, "Subscribe", []string{"newHeads"}); resp.Error != nil {
+		t.Fatalf("subscribe failed: %+v", resp.Error)
+	}
+	// notifications are not read until the queue and the socket buffers are full
+	payload := strings.Repeat("x", 64*1024)
+	for i := 0; i < 4*wsSendBuffer; i++ {
+		if err := server.Notify("newHeads", payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		server.subs.mu.RLock()
+		n := len(server.subs.topics["newHeads"])
+		server.subs.mu.RUnlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("slow subscriber not disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRPCServer_SubscribeModules(t *testing.T) {
+	server, conn := newTestWSServer(t, &RPCConfig{WSModules: []string{"Chain"}})
+	server.RegisterTopic("newHeads", "Chain")
+	server.RegisterTopic("newPendingTransactions", "TxPool")
+	resp := wsCall(t, conn, "Subscribe", []string{"newPendingTransactions"})
+	if resp.Error == nil || resp.Error.Code != methodNotFoundError.Code {
+		t.Fatalf("got %+v, want a method not found error", resp.Error)
+	}
+	for i := 0; i < maxWSSubscriptions; i++ {
+		if resp = wsCall(t, conn, "Subscribe", []string{"newHeads"}); resp.Error != nil {
+			t.Fatalf("subscribe %d failed: %+v", i, resp.Error)
+		}
+	}
+	resp = wsCall(t, conn, "Subscribe", []string{"newHeads"})
+	if resp.Error == nil || resp.Error.Code != tooManySubscriptionsError.Code {
+		t.Fatalf("got %+v, want too many subscriptions", resp.Error)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+//go:build !windows
+// +build !windows
+
+package xfsgo
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestListenAdmin(t *testing.T) {
+	mask := syscall.Umask(0)
+	defer syscall.Umask(mask)
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	listener, err := listenAdmin(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		t.Fatalf("got admin socket permissions %o", perm)
+	}
+	// the umask of the process is restored
+	if got := syscall.Umask(0); got != 0 {
+		t.Fatalf("got umask %o, want 0", got)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errUnauthorized = errors.New("unauthorized")
+	errInvalidJWT   = errors.New("invalid token")
+	errExpiredJWT   = errors.New("token expired")
+)
+
+const (
+	// jwtLeeway is the clock difference tolerated when checking the times of a token.
+	jwtLeeway = 5 * time.Second
+	// authTokenKey holds the accepted token in the context of a request.
+	authTokenKey = "xfsgo.authToken"
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+}
+
+type jwtClaims struct {
+	ExpiresAt *int64 `json:"exp"`
+	NotBefore *int64 `json:"nbf"`
+}
+
+// verifyJWT checks the HS256 signature and the times of a token.
+func verifyJWT(token string, secret []byte, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errInvalidJWT
+	}
+	headerData, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return errInvalidJWT
+	}
+	header := &jwtHeader{}
+	if err = json.Unmarshal(headerData, header); err != nil || header.Alg != "HS256" {
+		return errInvalidJWT
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errInvalidJWT
+	}
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return errInvalidJWT
+	}
+	claimsData, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return errInvalidJWT
+	}
+	claims := &jwtClaims{}
+	if err = json.Unmarshal(claimsData, claims); err != nil {
+		return errInvalidJWT
+	}
+	if claims.ExpiresAt != nil && now.Add(-jwtLeeway).Unix() >= *claims.ExpiresAt {
+		return errExpiredJWT
+	}
+	if claims.NotBefore != nil && now.Add(jwtLeeway).Unix() < *claims.NotBefore {
+		return errInvalidJWT
+	}
+	return nil
+}
+
+// bearerToken returns the token of the Authorization header.
+func bearerToken(c *gin.Context) string {
+	auth := c.GetHeader("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// authMiddleware rejects the requests without a bearer token which is one
+// of tokens or a JWT signed with secret. Nothing is checked when neither
+// is configured.
+func authMiddleware(secret []byte, tokens []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(secret) == 0 && len(tokens) == 0 {
+			return
+		}
+		token := bearerToken(c)
+		if token == "" {
+			httperr(c, 401, errUnauthorized)
+			return
+		}
+		for _, t := range tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				c.Set(authTokenKey, token)
+				return
+			}
+		}
+		if len(secret) > 0 {
+			if err := verifyJWT(token, secret, time.Now()); err == nil {
+				c.Set(authTokenKey, token)
+				return
+			}
+		}
+		httperr(c, 401, errUnauthorized)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func signTestJWT(secret []byte, header, claims string) string {
+	data := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(data))
+	return data + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyJWT(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1000, 0)
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"valid", signTestJWT(secret, `{"alg":"HS256","typ":"JWT"}`, `{"exp":2000}`), nil},
+		{"no times", signTestJWT(secret, `{"alg":"HS256"}`, `{}`), nil},
+		{"expired", signTestJWT(secret, `{"alg":"HS256"}`, `{"exp":900}`), errExpiredJWT},
+		{"not yet valid", signTestJWT(secret, `{"alg":"HS256"}`, `{"nbf":1100}`), errInvalidJWT},
+		{"other secret", signTestJWT([]byte("other"), `{"alg":"HS256"}`, `{}`), errInvalidJWT},
+		{"alg none", signTestJWT(secret, `{"alg":"none"}`, `{}`), errInvalidJWT},
+		{"malformed", "a.b", errInvalidJWT},
+	}
+	for _, tt := range tests {
+		if err := verifyJWT(tt.token, secret, now); err != tt.want {
+			t.Errorf("%s: got err %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRPCServer_Auth(t *testing.T) {
+	secret := []byte("secret")
+	_, ts := newTestRPCServer(t, &RPCConfig{
+		JWTSecret: secret,
+		Tokens:    []string{"static"},
+	})
+	body := `{"jsonrpc":"2.0","id":1,"method":"Test.Echo","params":{"name":"a"}}`
+	tests := []struct {
+		token string
+		want  int
+	}{
+		{"", http.StatusUnauthorized},
+		{"wrong", http.StatusUnauthorized},
+		{"static", http.StatusOK},
+		{signTestJWT(secret, `{"alg":"HS256"}`, `{}`), http.StatusOK},
+		{signTestJWT([]byte("other"), `{"alg":"HS256"}`, `{}`), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if status, _ := postRPC(t, ts.Client(), ts.URL, body, tt.token); status != tt.want {
+			t.Errorf("got status %d with token %q, want %d", status, tt.token, tt.want)
+		}
+	}
+}
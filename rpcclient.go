@@ -17,14 +17,19 @@
 package xfsgo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
+	"strings"
 
 	"github.com/go-resty/resty/v2"
 )
 
 type Client struct {
 	hostUrl string
+	token   string
 }
 
 type jsonRPCReq struct {
@@ -41,15 +46,36 @@ type jsonRPCResp struct {
 	ID      int         `json:"id"`
 }
 
+// ipcScheme prefixes the path of the Unix socket of a server in a client URL.
+const ipcScheme = "ipc://"
+
+// NewClient returns a client of the server at url, an HTTP URL or the
+// path of its Unix socket prefixed with ipc://.
 func NewClient(url string) *Client {
 	return &Client{
 		hostUrl: url,
 	}
 }
 
+// SetToken sets the bearer token sent with the requests.
+func (cli *Client) SetToken(token string) {
+	cli.token = token
+}
+
 // CallMethod executes a JSON-RPC call with the given psrameters,which is important to the rpc server.
 func (cli *Client) CallMethod(id int, methodname string, params interface{}, out interface{}) error {
 	client := resty.New()
+	url := cli.hostUrl
+	if strings.HasPrefix(url, ipcScheme) {
+		path := strings.TrimPrefix(url, ipcScheme)
+		client.SetTransport(&http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		})
+		// the host is not used to connect
+		url = "http://ipc/"
+	}
 	req := &jsonRPCReq{
 		JsonRPC: "2.0",
 		ID:      id,
@@ -58,14 +84,21 @@ func (cli *Client) CallMethod(id int, methodname string, params interface{}, out
 	}
 	// The result must be a pointer so that response json can unmarshal into it.
 	var resp *jsonRPCResp = nil
-	r, err := client.R().
+	r := client.R()
+	if cli.token != "" {
+		r.SetAuthToken(cli.token)
+	}
+	res, err := r.
 		SetHeader("Content-Type", "application/json").
 		SetBody(req).
 		SetResult(&resp). // or SetResult(AuthSuccess{}).
-		Post(cli.hostUrl)
+		Post(url)
 	if err != nil {
 		return err
 	}
+	if res.IsError() {
+		return fmt.Errorf("rpc request err: %s", res.Status())
+	}
 	if resp == nil {
 		return fmt.Errorf("resp null")
 	}
@@ -81,6 +114,5 @@ func (cli *Client) CallMethod(id int, methodname string, params interface{}, out
 	if err != nil {
 		return err
 	}
-	_ = r
 	return nil
 }
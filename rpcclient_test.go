@@ -0,0 +1,50 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClient_IPC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	socket := filepath.Join(dir, "admin.sock")
+	server, _ := newTestRPCServer(t, &RPCConfig{
+		HTTPModules: []string{"Chain"},
+		AdminSocket: socket,
+	})
+	if err = server.startAdmin(); err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	cli := NewClient(ipcScheme + socket)
+	if err = cli.CallMethod(1, "Test.Echo", map[string]string{"name": "a"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != "a:" {
+		t.Fatalf("got %s, want a:", got)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Default limits of the RPC server, used when RPCConfig leaves them zero.
+const (
+	DefaultRPCMaxBodySize    = 5 * 1024 * 1024
+	DefaultRPCMaxFrameSize   = 5 * 1024 * 1024
+	DefaultRPCMethodTimeout  = 30 * time.Second
+	DefaultRPCMaxConcurrency = 256
+	DefaultRPCMaxBatchSize   = 100
+
+	// rateLimitIdle is how long the bucket of a client which sends
+	// nothing is kept, a new bucket is full anyway.
+	rateLimitIdle = time.Minute
+)
+
+var (
+	rateLimitedError     = NewRPCError(-32005, "rate limit exceeded")
+	serverBusyError      = NewRPCError(-32006, "server busy")
+	methodTimeoutError   = NewRPCError(-32007, "method timed out")
+	requestTooLargeError = NewRPCError(-32600, "request too large")
+)
+
+// rateLimiter is a token bucket per client, which gets rate requests per
+// second on average and burst at once.
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*rateBucket
+	lastSweep time.Time
+}
+
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns nil, which allows everything, when rate is not positive.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = int(rate)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*rateBucket),
+	}
+}
+
+func (l *rateLimiter) allow(key string, now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) > rateLimitIdle {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > rateLimitIdle {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// callLimited runs a method with the concurrency cap and the timeout of the
+// server. Methods taking a context see it cancelled at the timeout, the
+// results of the others are dropped. A method keeps its slot until it
+// returns, even after timing out, so that abandoned calls stay bounded by
+// the cap: long running methods must take a context and stop when it is done.
+func (server *RPCServer) callLimited(s *service, mtype *methodType, obj *jsonRPCObj) (interface{}, error) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if server.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), server.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+	if server.slots != nil {
+		select {
+		case server.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, serverBusyError
+		}
+	}
+	type callResult struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan callResult, 1)
+	go func() {
+		defer func() {
+			if server.slots != nil {
+				<-server.slots
+			}
+			if r := recover(); r != nil {
+				server.logger.Errorf("rpc method %s panic: %v", obj.Method, r)
+				done <- callResult{err: internalError}
+			}
+		}()
+		result, err := s.callMethod(ctx, mtype, obj.Params)
+		done <- callResult{result: result, err: err}
+	}()
+	select {
+	case r := <-done:
+		return r.result, r.err
+	case <-ctx.Done():
+		return nil, methodTimeoutError
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLimitService has a method waiting for the context of the call
+// and one waiting for release, whatever the context.
+type testLimitService struct {
+	release chan struct{}
+}
+
+func (*testLimitService) Wait(ctx context.Context, _ interface{}, _ *string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *testLimitService) Block(_ interface{}, reply *string) error {
+	<-s.release
+	*reply = "released"
+	return nil
+}
+
+func newTestLimitServer(t *testing.T, config *RPCConfig) (string, *http.Client, *testLimitService) {
+	server, ts := newTestRPCServer(t, config)
+	svc := &testLimitService{release: make(chan struct{})}
+	t.Cleanup(func() {
+		close(svc.release)
+	})
+	if err := server.RegisterName("Limit", svc); err != nil {
+		t.Fatal(err)
+	}
+	return ts.URL, ts.Client(), svc
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(2, 3)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if !l.allow("a", now) {
+			t.Fatalf("request %d of the burst refused", i)
+		}
+	}
+	if l.allow("a", now) {
+		t.Fatal("request above the burst allowed")
+	}
+	if !l.allow("b", now) {
+		t.Fatal("request of another client refused")
+	}
+	// a token every half second
+	if !l.allow("a", now.Add(500*time.Millisecond)) {
+		t.Fatal("refilled request refused")
+	}
+	if l.allow("a", now.Add(500*time.Millisecond)) {
+		t.Fatal("request above the rate allowed")
+	}
+	if newRateLimiter(0, 10) != nil {
+		t.Fatal("limiter without rate enabled")
+	}
+	var disabled *rateLimiter
+	if !disabled.allow("a", now) {
+		t.Fatal("disabled limiter refused")
+	}
+}
+
+func TestRPCServer_RateLimit(t *testing.T) {
+	url, client, _ := newTestLimitServer(t, &RPCConfig{RateLimit: 0.001, RateBurst: 2})
+	body := `{"jsonrpc":"2.0","id":1,"method":"Test.Echo","params":["a",1]}`
+	for i := 0; i < 2; i++ {
+		if status, _ := postRPC(t, client, url, body, ""); status != http.StatusOK {
+			t.Fatalf("got status %d, want %d", status, http.StatusOK)
+		}
+	}
+	status, got := postRPC(t, client, url, body, "")
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("got status %d, want %d", status, http.StatusTooManyRequests)
+	}
+	want := `{"jsonrpc":"2.0","id":null,"error":{"code":-32005,"message":"rate limit exceeded"}}`
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestRPCServer_RateLimitBatch(t *testing.T) {
+	url, client, _ := newTestLimitServer(t, &RPCConfig{RateLimit: 0.001, RateBurst: 2})
+	body := `[{"jsonrpc":"2.0","id":1,"method":"Test.Echo","params":["a",1]},` +
+		`{"jsonrpc":"2.0","id":2,"method":"Test.Echo","params":["b",1]},` +
+		`{"jsonrpc":"2.0","method":"Test.Echo","params":["c",1]},` +
+		`{"jsonrpc":"2.0","id":4,"method":"Test.Echo","params":["d",1]}]`
+	status, got := postRPC(t, client, url, body, "")
+	if status != http.StatusOK {
+		t.Fatalf("got status %d, want %d", status, http.StatusOK)
+	}
+	want := `[{"jsonrpc":"2.0","id":1,"result":"a:1"},{"jsonrpc":"2.0","id":2,"result":"b:1"},` +
+		`{"jsonrpc":"2.0","id":4,"error":{"code":-32005,"message":"rate limit exceeded"}}]`
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if status, _ = postRPC(t, client, url, body, ""); status != http.StatusTooManyRequests {
+		t.Fatalf("got status %d, want %d", status, http.StatusTooManyRequests)
+	}
+}
+
+func TestRPCServer_RateLimitTokens(t *testing.T) {
+	url, client, _ := newTestLimitServer(t, &RPCConfig{RateLimit: 0.001, RateBurst: 1})
+	body := `{"jsonrpc":"2.0","id":1,"method":"Test.Echo","params":["a",1]}`
+	// unchecked tokens and forwarded IPs do not give new buckets
+	for i, token := range []string{"a", "b", "c"} {
+		req, err := http.NewRequest("POST", url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Forwarded-For", "10.0.0."+strconv.Itoa(i+1))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		want := http.StatusTooManyRequests
+		if i == 0 {
+			want = http.StatusOK
+		}
+		if resp.StatusCode != want {
+			t.Fatalf("got status %d with token %s, want %d", resp.StatusCode, token, want)
+		}
+	}
+
+	// accepted tokens have their own bucket
+	url, client, _ = newTestLimitServer(t, &RPCConfig{
+		Tokens:    []string{"a", "b"},
+		RateLimit: 0.001,
+		RateBurst: 1,
+	})
+	for _, token := range []string{"a", "b"} {
+		if status, _ := postRPC(t, client, url, body, token); status != http.StatusOK {
+			t.Fatalf("got status %d with token %s, want %d", status, token, http.StatusOK)
+		}
+	}
+	for _, token := range []string{"a", "b"} {
+		if status, _ := postRPC(t, client, url, body, token); status != http.StatusTooManyRequests {
+			t.Fatalf("got status %d with token %s, want %d", status, token, http.StatusTooManyRequests)
+		}
+	}
+}
+
+func TestRPCServer_BodySize(t *testing.T) {
+	url, client, _ := newTestLimitServer(t, &RPCConfig{MaxBodySize: 128})
+	body := `{"jsonrpc":"2.0","id":1,"method":"Test.Echo","params":["` + strings.Repeat("a", 128) + `",1]}`
+	status, got := postRPC(t, client, url, body, "")
+	if status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("got status %d, want %d", status, http.StatusRequestEntityTooLarge)
+	}
+	want := `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"request too large"}}`
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	body = `{"jsonrpc":"2.0","id":1,"method":"Test.Echo","params":["a",1]}`
+	if status, _ = postRPC(t, client, url, body, ""); status != http.StatusOK {
+		t.Fatalf("got status %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestRPCServer_BatchSize(t *testing.T) {
+	url, client, _ := newTestLimitServer(t, &RPCConfig{MaxBatchSize: 3, MaxConcurrency: 1})
+	reqs := make([]string, 0)
+	for i := 1; i <= 4; i++ {
+		reqs = append(reqs, `{"jsonrpc":"2.0","id":`+strconv.Itoa(i)+`,"method":"Test.Echo","params":["a",`+strconv.Itoa(i)+`]}`)
+	}
+	_, got := postRPC(t, client, url, "["+strings.Join(reqs, ",")+"]", "")
+	want := `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid request"}}`
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	// the batch is run by one worker, the responses are in order
+	_, got = postRPC(t, client, url, "["+strings.Join(reqs[:3], ",")+"]", "")
+	want = `[{"jsonrpc":"2.0","id":1,"result":"a:1"},{"jsonrpc":"2.0","id":2,"result":"a:2"},` +
+		`{"jsonrpc":"2.0","id":3,"result":"a:3"}]`
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestRPCServer_MethodTimeout(t *testing.T) {
+	url, client, _ := newTestLimitServer(t, &RPCConfig{MethodTimeout: 50 * time.Millisecond})
+	_, got := postRPC(t, client, url, `{"jsonrpc":"2.0","id":1,"method":"Limit.Wait"}`, "")
+	want := `{"jsonrpc":"2.0","id":1,"error":{"code":-32007,"message":"method timed out"}}`
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestRPCServer_Concurrency(t *testing.T) {
+	url, client, _ := newTestLimitServer(t, &RPCConfig{
+		MethodTimeout:  50 * time.Millisecond,
+		MaxConcurrency: 1,
+	})
+	// the blocked method keeps its slot after timing out
+	_, got := postRPC(t, client, url, `{"jsonrpc":"2.0","id":1,"method":"Limit.Block"}`, "")
+	want := `{"jsonrpc":"2.0","id":1,"error":{"code":-32007,"message":"method timed out"}}`
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	_, got = postRPC(t, client, url, `{"jsonrpc":"2.0","id":2,"method":"Test.Echo","params":["a",1]}`, "")
+	want = `{"jsonrpc":"2.0","id":2,"error":{"code":-32006,"message":"server busy"}}`
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
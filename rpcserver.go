@@ -18,15 +18,19 @@ package xfsgo
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"go/token"
-	"io"
 	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
 	"reflect"
-	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"xfsgo/p2p/log"
 
 	"github.com/gin-gonic/gin"
@@ -41,8 +45,8 @@ var (
 	parseError          = NewRPCError(-32700, "parse error")
 	invalidRequestError = NewRPCError(-32600, "invalid request")
 	methodNotFoundError = NewRPCError(-32601, "method not found")
-	//invalidParamsError = NewRPCError(-32602, "invalid params")
-	//internalError = NewRPCError(-32603, "invalid params")
+	invalidParamsError  = NewRPCError(-32602, "invalid params")
+	internalError       = NewRPCError(-32603, "internal error")
 )
 
 type methodType struct {
@@ -50,6 +54,9 @@ type methodType struct {
 	ArgType   reflect.Type
 	ReplyType reflect.Type
 	numCalls  uint
+	// hasCtx is set for the methods taking a context first,
+	// which is cancelled when the call times out.
+	hasCtx bool
 }
 
 type service struct {
@@ -58,19 +65,80 @@ type service struct {
 	typ     reflect.Type
 	methods map[string]*methodType
 }
+
+// jsonRPCObj is a request, a request without id is a notification
+// which gets no response.
 type jsonRPCObj struct {
-	jsonrpc string
-	id      *int
-	method  string
-	params  interface{}
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
 }
+
 type jsonRPCRespErr struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 }
 
+type jsonRPCRespObj struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *jsonRPCRespErr `json:"error,omitempty"`
+}
+
 type RPCConfig struct {
 	ListenAddr string
+	// HTTPModules and WSModules are the namespaces served to the HTTP and
+	// websocket clients of ListenAddr, every namespace when empty.
+	HTTPModules []string
+	WSModules   []string
+	// JWTSecret and Tokens enable the authentication of the clients of
+	// ListenAddr, which must send a HS256 JWT signed with JWTSecret or
+	// one of Tokens as a bearer token.
+	JWTSecret []byte
+	Tokens    []string
+	// AdminSocket is the path of a Unix socket serving every namespace
+	// without authentication to local clients, disabled when empty.
+	AdminSocket string
+	// MaxBodySize and MaxFrameSize limit the size of HTTP requests and
+	// websocket messages in bytes.
+	MaxBodySize  int64
+	MaxFrameSize int64
+	// MethodTimeout limits the execution of a method and MaxConcurrency
+	// the number of methods executed at once, including the methods which
+	// timed out but did not return yet.
+	MethodTimeout  time.Duration
+	MaxConcurrency int
+	// MaxBatchSize is the largest number of requests in a batch.
+	MaxBatchSize int
+	// RateLimit is the number of requests per second allowed to a client
+	// of ListenAddr, identified by its accepted token or its IP, and
+	// RateBurst the number allowed at once. Each request of a batch counts.
+	// Disabled when RateLimit is zero.
+	RateLimit float64
+	RateBurst int
+	// TrustedProxies are the IPs or CIDRs of the proxies whose
+	// X-Forwarded-For header gives the IP of a client, none when empty.
+	TrustedProxies []string
+}
+
+// moduleSet is a set of namespaces, nil holds every namespace.
+type moduleSet map[string]bool
+
+func newModuleSet(names []string) moduleSet {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(moduleSet)
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+func (set moduleSet) has(name string) bool {
+	return set == nil || set[name]
 }
 
 // RPCServer is an RPC server.
@@ -80,6 +148,15 @@ type RPCServer struct {
 	ginEngine  *gin.Engine
 	upgrader   websocket.Upgrader
 	serviceMap map[string]*service
+	subs       *subscriptionHub
+	httpMods   moduleSet
+	wsMods     moduleSet
+	maxBody    int64
+	maxFrame   int64
+	timeout    time.Duration
+	maxBatch   int
+	slots      chan struct{}
+	limiter    *rateLimiter
 }
 
 func ginlogger(log log.Logger) gin.HandlerFunc {
@@ -96,63 +173,105 @@ func NewRPCServer(config *RPCConfig) *RPCServer {
 		logger:     log.DefaultLogger(),
 		config:     config,
 		serviceMap: make(map[string]*service),
+		subs:       newSubscriptionHub(),
+		httpMods:   newModuleSet(config.HTTPModules),
+		wsMods:     newModuleSet(config.WSModules),
+		maxBody:    config.MaxBodySize,
+		maxFrame:   config.MaxFrameSize,
+		timeout:    config.MethodTimeout,
+		maxBatch:   config.MaxBatchSize,
+		limiter:    newRateLimiter(config.RateLimit, config.RateBurst),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
 		},
 	}
 
+	if server.maxBody <= 0 {
+		server.maxBody = DefaultRPCMaxBodySize
+	}
+	if server.maxFrame <= 0 {
+		server.maxFrame = DefaultRPCMaxFrameSize
+	}
+	if server.timeout <= 0 {
+		server.timeout = DefaultRPCMethodTimeout
+	}
+	if server.maxBatch <= 0 {
+		server.maxBatch = DefaultRPCMaxBatchSize
+	}
+	concurrency := config.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultRPCMaxConcurrency
+	}
+	server.slots = make(chan struct{}, concurrency)
+
 	logger := log.DefaultLogger()
 	gin.DefaultWriter = logger.Writer()
 	gin.SetMode("release")
 	server.ginEngine = gin.New()
+	// gin trusts the forwarded IPs of any client by default
+	server.ginEngine.TrustedProxies = config.TrustedProxies
 	server.ginEngine.Use(ginlogger(logger))
 	server.ginEngine.Use(gin.Recovery())
+	server.ginEngine.Use(authMiddleware(config.JWTSecret, config.Tokens))
 	return server
 }
 
-func (s *service) callMethod(mtype *methodType, params interface{}) (interface{}, error) {
+// decodeParams decodes the params of a request into out, a pointer to the
+// argument of a method. Params given as an array set the fields of a struct
+// argument in order.
+func decodeParams(params json.RawMessage, out reflect.Value) error {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return nil
+	}
+	switch params[0] {
+	case '[':
+		var arr []json.RawMessage
+		if err := json.Unmarshal(params, &arr); err != nil {
+			return err
+		}
+		arg := out.Elem()
+		if arg.Kind() != reflect.Struct {
+			if len(arr) != 1 {
+				return invalidParamsError
+			}
+			return json.Unmarshal(arr[0], out.Interface())
+		}
+		if len(arr) != arg.NumField() {
+			return invalidParamsError
+		}
+		for i, elem := range arr {
+			if !arg.Field(i).CanSet() {
+				return invalidParamsError
+			}
+			if err := json.Unmarshal(elem, arg.Field(i).Addr().Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case '{':
+		return json.Unmarshal(params, out.Interface())
+	}
+	return invalidParamsError
+}
+
+func (s *service) callMethod(ctx context.Context, mtype *methodType, params json.RawMessage) (interface{}, error) {
 	function := mtype.method.Func
-	argIsValue := false
 	var argv reflect.Value
 	if mtype.ArgType.Kind() == reflect.Ptr {
 		argv = reflect.New(mtype.ArgType.Elem())
 	} else {
 		argv = reflect.New(mtype.ArgType)
-		argIsValue = true
 	}
-
-	if argIsValue {
-		argv = argv.Elem()
-	}
-	if params != nil {
-		tk := reflect.TypeOf(params).Kind()
-		switch tk {
-		case reflect.Slice:
-			paramsArr, _ := params.([]interface{})
-			if len(paramsArr) != argv.NumField() {
-				return nil, NewRPCError(-32602, "Invalid params")
-			}
-			for i := 0; i < argv.NumField(); i++ {
-				argv.Field(i).Set(reflect.ValueOf(paramsArr[i]))
-			}
-		case reflect.Map:
-			paramsMap := params.(map[string]interface{})
-			for i := 0; i < argv.NumField(); i++ {
-				fieldInfo := argv.Type().Field(i) // a reflect.StructField
-				tag := fieldInfo.Tag              // a reflect.StructTag
-				name := tag.Get("json")
-				if name == "" {
-					name = strings.ToLower(fieldInfo.Name)
-				}
-				name = strings.Split(name, ",")[0] //json Tag
-				if value, ok := paramsMap[name]; ok {
-					if reflect.ValueOf(value).Type() == argv.FieldByName(fieldInfo.Name).Type() {
-						argv.FieldByName(fieldInfo.Name).Set(reflect.ValueOf(value))
-					}
-				}
-			}
+	if err := decodeParams(params, argv); err != nil {
+		if err == invalidParamsError {
+			return nil, err
 		}
+		return nil, NewRPCErrorCause(-32602, err)
+	}
+	if mtype.ArgType.Kind() != reflect.Ptr {
+		argv = argv.Elem()
 	}
 	replyv := reflect.New(mtype.ReplyType.Elem())
 	switch mtype.ReplyType.Elem().Kind() {
@@ -161,7 +280,11 @@ func (s *service) callMethod(mtype *methodType, params interface{}) (interface{}
 	case reflect.Slice:
 		replyv.Elem().Set(reflect.MakeSlice(mtype.ReplyType.Elem(), 0, 0))
 	}
-	returnValues := function.Call([]reflect.Value{s.rcvr, argv, replyv})
+	in := []reflect.Value{s.rcvr, argv, replyv}
+	if mtype.hasCtx {
+		in = []reflect.Value{s.rcvr, reflect.ValueOf(ctx), argv, replyv}
+	}
+	returnValues := function.Call(in)
 	errInter := returnValues[0].Interface()
 	if errInter != nil {
 		e := errInter.(error)
@@ -187,7 +310,10 @@ func isExportedOrBuiltinType(t reflect.Type) bool {
 	return token.IsExported(t.Name()) || t.PkgPath() == ""
 }
 
-var typeOfError = reflect.TypeOf((*error)(nil)).Elem()
+var (
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
 
 func suitableMethods(typ reflect.Type) map[string]*methodType {
 	methods := make(map[string]*methodType)
@@ -198,14 +324,20 @@ func suitableMethods(typ reflect.Type) map[string]*methodType {
 		if method.PkgPath != "" {
 			continue
 		}
-		if mtype.NumIn() != 3 {
+		// the receiver, an optional context, the argument and the reply
+		hasCtx := mtype.NumIn() == 4 && mtype.In(1) == typeOfContext
+		if mtype.NumIn() != 3 && !hasCtx {
 			continue
 		}
-		argType := mtype.In(1)
+		argIndex := 1
+		if hasCtx {
+			argIndex = 2
+		}
+		argType := mtype.In(argIndex)
 		if !isExportedOrBuiltinType(argType) {
 			continue
 		}
-		replyType := mtype.In(2)
+		replyType := mtype.In(argIndex + 1)
 		if replyType.Kind() != reflect.Ptr {
 			continue
 		}
@@ -219,6 +351,7 @@ func suitableMethods(typ reflect.Type) map[string]*methodType {
 			method:    method,
 			ArgType:   argType,
 			ReplyType: replyType,
+			hasCtx:    hasCtx,
 		}
 	}
 	return methods
@@ -245,9 +378,10 @@ func (server *RPCServer) register(rcvr interface{}, name string, useName bool) e
 	return nil
 }
 
-func (server *RPCServer) getServiceAndMethodType(pack string) (*service, *methodType, error) {
+// getServiceAndMethodType returns the method of a namespace of modules.
+func (server *RPCServer) getServiceAndMethodType(pack string, modules moduleSet) (*service, *methodType, error) {
 	mpake := strings.Split(pack, ".")
-	if len(mpake) != 2 {
+	if len(mpake) != 2 || !modules.has(mpake[0]) {
 		return nil, nil, methodNotFoundError
 	}
 	mService := server.serviceMap[mpake[0]]
@@ -261,82 +395,179 @@ func (server *RPCServer) getServiceAndMethodType(pack string) (*service, *method
 	return mService, mtype, nil
 }
 
-func (server *RPCServer) parseJsonRPCObj(jsonObjMap map[string]interface{}, obj *jsonRPCObj) error {
-	idNumber, ok := jsonObjMap["id"].(json.Number)
-	if !ok {
-		return invalidRequestError
+// validID reports whether id is a string, a number or null.
+func validID(id json.RawMessage) bool {
+	var v interface{}
+	if err := json.Unmarshal(id, &v); err != nil {
+		return false
 	}
-	idNumberStr := idNumber.String()
-	id, err := strconv.Atoi(idNumberStr)
-	if err != nil {
-		return NewRPCError(-32600, err.Error())
+	switch v.(type) {
+	case nil, string, float64:
+		return true
 	}
-	obj.id = &id
-	version, ok := jsonObjMap["jsonrpc"].(string)
-	if !ok || version != "2.0" {
-		return invalidRequestError
+	return false
+}
+
+func newRPCErrorResp(id json.RawMessage, err error) *jsonRPCRespObj {
+	e := &jsonRPCRespErr{}
+	if rpcErr, ok := err.(*RPCError); ok {
+		e.Code = rpcErr.Code
+		e.Message = rpcErr.Message
+	} else {
+		e.Code = internalError.Code
+		e.Message = internalError.Message
 	}
-	obj.jsonrpc = version
-	methodPack, ok := jsonObjMap["method"].(string)
-	if !ok {
-		return invalidRequestError
+	return &jsonRPCRespObj{
+		JSONRPC: jsonrpcVersion,
+		ID:      id,
+		Error:   e,
 	}
-	obj.method = methodPack
-	obj.params = jsonObjMap["params"]
-	return nil
 }
 
-func (server *RPCServer) jsonRPCCall(data []byte, rpcId **int, w io.Writer) error {
-	var personFromJSON interface{}
-	decoder := json.NewDecoder(bytes.NewReader(data))
-	decoder.UseNumber()
-	decoder.Decode(&personFromJSON)
-
-	jsonObjMap := personFromJSON.(map[string]interface{})
-	rpcObj := &jsonRPCObj{}
-	if err := server.parseJsonRPCObj(jsonObjMap, rpcObj); err != nil {
-		*rpcId = *&rpcObj.id
-		return err
+// callRequest handles a request and returns its response, or nil when it
+// is a notification. conn is the websocket connection it was received on or nil,
+// modules are the namespaces the client may call.
+func (server *RPCServer) callRequest(data json.RawMessage, conn *wsConn, modules moduleSet) (resp *jsonRPCRespObj) {
+	obj := &jsonRPCObj{}
+	if err := json.Unmarshal(data, obj); err != nil {
+		return newRPCErrorResp(nil, invalidRequestError)
 	}
-	*rpcId = *&rpcObj.id
-	s, t, err := server.getServiceAndMethodType(rpcObj.method)
-	if err != nil {
-		return err
+	if obj.ID != nil && !validID(obj.ID) {
+		return newRPCErrorResp(nil, invalidRequestError)
+	}
+	notification := obj.ID == nil
+	defer func() {
+		if r := recover(); r != nil {
+			server.logger.Errorf("rpc method %s panic: %v", obj.Method, r)
+			resp = newRPCErrorResp(obj.ID, internalError)
+		}
+		if notification {
+			resp = nil
+		}
+	}()
+	if obj.JSONRPC != jsonrpcVersion || obj.Method == "" {
+		return newRPCErrorResp(obj.ID, invalidRequestError)
+	}
+	var (
+		result interface{}
+		err    error
+	)
+	if obj.Method == subscribeMethod || obj.Method == unsubscribeMethod {
+		result, err = server.callSubscription(conn, obj, modules)
+	} else if obj.Method == discoverMethod {
+		result = server.discover(modules, conn != nil)
+	} else {
+		s, t, e := server.getServiceAndMethodType(obj.Method, modules)
+		if e != nil {
+			return newRPCErrorResp(obj.ID, e)
+		}
+		result, err = server.callLimited(s, t, obj)
 	}
-	rec, err := s.callMethod(t, rpcObj.params)
 	if err != nil {
-		return err
+		return newRPCErrorResp(obj.ID, err)
+	}
+	return &jsonRPCRespObj{
+		JSONRPC: jsonrpcVersion,
+		ID:      obj.ID,
+		Result:  result,
 	}
-	outMap := make(map[string]interface{})
-	outMap["jsonrpc"] = jsonrpcVersion
-	outMap["id"] = rpcObj.id
-	outMap["result"] = rec
-	outBytes, _ := json.Marshal(outMap)
-	_, _ = w.Write(outBytes)
-	return nil
 }
 
-func httperr(c *gin.Context, status int, err error) {
-	c.String(status, "%s", err)
-	c.Abort()
+// rateLimitedResp answers a request of a batch refused by the rate limit,
+// it is nil for a notification.
+func rateLimitedResp(data json.RawMessage) *jsonRPCRespObj {
+	obj := &jsonRPCObj{}
+	if err := json.Unmarshal(data, obj); err != nil {
+		return newRPCErrorResp(nil, rateLimitedError)
+	}
+	if obj.ID == nil {
+		return nil
+	}
+	if !validID(obj.ID) {
+		return newRPCErrorResp(nil, rateLimitedError)
+	}
+	return newRPCErrorResp(obj.ID, rateLimitedError)
 }
 
-func writeRPCError(err error, reqId *int, w io.Writer) {
-	rpcErr, isRPCErr := err.(*RPCError)
-	e := jsonRPCRespErr{}
-	if !isRPCErr {
-		e.Code = -32603
-		e.Message = "internal error"
-	} else {
-		e.Code = rpcErr.Code
-		e.Message = rpcErr.Message
+// jsonRPCCall handles a request or a batch of requests and returns the
+// encoded response, which is nil when there is nothing to answer. The
+// requests of a batch are run concurrently by at most as many workers as
+// methods may run at once, their responses are in order.
+// The caller charged the rate limit of the client for the first request,
+// allow charges it for each other request of a batch, the requests it
+// refuses are answered with rateLimitedError.
+func (server *RPCServer) jsonRPCCall(data []byte, conn *wsConn, modules moduleSet, allow func() bool) []byte {
+	data = bytes.TrimSpace(data)
+	if !json.Valid(data) {
+		out, _ := json.Marshal(newRPCErrorResp(nil, parseError))
+		return out
+	}
+	if len(data) == 0 || data[0] != '[' {
+		resp := server.callRequest(data, conn, modules)
+		if resp == nil {
+			return nil
+		}
+		out, err := json.Marshal(resp)
+		if err != nil {
+			out, _ = json.Marshal(newRPCErrorResp(resp.ID, internalError))
+		}
+		return out
+	}
+	var batch []json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil || len(batch) == 0 || len(batch) > server.maxBatch {
+		out, _ := json.Marshal(newRPCErrorResp(nil, invalidRequestError))
+		return out
 	}
-	outMap := make(map[string]interface{})
-	outMap["jsonrpc"] = jsonrpcVersion
-	outMap["id"] = reqId
-	outMap["error"] = e
-	outBytes, _ := json.Marshal(outMap)
-	_, _ = w.Write(outBytes)
+	resps := make([]*jsonRPCRespObj, len(batch))
+	calls := make([]int, 0, len(batch))
+	for i, req := range batch {
+		if i > 0 && !allow() {
+			resps[i] = rateLimitedResp(req)
+			continue
+		}
+		calls = append(calls, i)
+	}
+	workers := cap(server.slots)
+	if workers > len(calls) {
+		workers = len(calls)
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				resps[i] = server.callRequest(batch[i], conn, modules)
+			}
+		}()
+	}
+	for _, i := range calls {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	outs := make([]json.RawMessage, 0, len(resps))
+	for _, resp := range resps {
+		if resp == nil {
+			continue
+		}
+		out, err := json.Marshal(resp)
+		if err != nil {
+			out, _ = json.Marshal(newRPCErrorResp(resp.ID, internalError))
+		}
+		outs = append(outs, out)
+	}
+	if len(outs) == 0 {
+		return nil
+	}
+	out, _ := json.Marshal(outs)
+	return out
+}
+
+func httperr(c *gin.Context, status int, err error) {
+	c.String(status, "%s", err)
+	c.Abort()
 }
 
 func isWebsocketRequest(c *gin.Context) bool {
@@ -344,11 +575,22 @@ func isWebsocketRequest(c *gin.Context) bool {
 	upgrade := c.GetHeader("Upgrade")
 	return connection == "Upgrade" && upgrade == "websocket"
 }
-func (server *RPCServer) handleWebsocket(c *gin.Context) error {
+func (server *RPCServer) handleWebsocket(c *gin.Context, modules moduleSet, limiter *rateLimiter) error {
 	conn, err := server.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		return err
 	}
+	// larger messages close the connection
+	conn.SetReadLimit(server.maxFrame)
+	key := rateLimitKey(c)
+	allow := func() bool {
+		return limiter.allow(key, time.Now())
+	}
+	wc := newWSConn(conn)
+	defer func() {
+		server.subs.unsubscribeAll(wc)
+		wc.close()
+	}()
 	for {
 		t, msg, err := conn.ReadMessage()
 		if err != nil {
@@ -357,59 +599,141 @@ func (server *RPCServer) handleWebsocket(c *gin.Context) error {
 		if t != websocket.TextMessage {
 			continue
 		}
-		//msgText := string(msg)
-
-		bs := bytes.NewBuffer(nil)
-		var rpcId *int
-		if err = server.jsonRPCCall(msg, &rpcId, bs); err != nil {
-			writeRPCError(err, nil, bs)
-		}
-		if err = conn.WriteMessage(t, bs.Bytes()); err != nil {
+		if !allow() {
+			out, _ := json.Marshal(newRPCErrorResp(nil, rateLimitedError))
+			wc.write(out)
 			continue
 		}
+		if out := server.jsonRPCCall(msg, wc, modules, allow); out != nil {
+			wc.write(out)
+		}
 	}
 	return nil
 }
 
-//Start starts rpc server.
-func (server *RPCServer) Start() error {
-	server.ginEngine.Any("/", func(c *gin.Context) {
-		//handle websocket request
-		if isWebsocketRequest(c) {
-			if err := server.handleWebsocket(c); err != nil {
-				server.logger.Warnf("ws connect err")
-			}
-			c.Abort()
-			return
-		}
-		if "POST" != c.Request.Method {
-			httperr(c, 404, errors.New("method not allowed"))
-			return
-		}
-		contentType := c.ContentType()
-		if contentType != "application/json" {
-			httperr(c, 404, errors.New("not acceptable"))
-			return
-		}
-		if nil == c.Request.Body {
-			httperr(c, 404, errors.New("body not be empty"))
-			return
+// handle serves the JSON-RPC requests of the clients of ListenAddr.
+func (server *RPCServer) handle(c *gin.Context) {
+	server.serve(c, server.httpMods, server.wsMods, server.limiter)
+}
+
+// handleAdmin serves the JSON-RPC requests of the admin socket.
+func (server *RPCServer) handleAdmin(c *gin.Context) {
+	server.serve(c, nil, nil, nil)
+}
+
+// rateLimitKey identifies the client of a request for the rate limit, by
+// the token authMiddleware accepted or else by its IP. Unchecked tokens
+// are ignored, a client would get a new bucket with each one.
+func rateLimitKey(c *gin.Context) string {
+	if token := c.GetString(authTokenKey); token != "" {
+		return "token:" + token
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// writeHTTPRPCError answers an HTTP request with the status and a JSON-RPC error.
+func writeHTTPRPCError(c *gin.Context, status int, err error) {
+	out, _ := json.Marshal(newRPCErrorResp(nil, err))
+	c.Data(status, "application/json; charset=utf-8", out)
+	c.Abort()
+}
+
+// serve handles a JSON-RPC request over HTTP or a websocket connection
+// with the namespaces of the transport and the rate limit of its clients.
+func (server *RPCServer) serve(c *gin.Context, httpModules, wsModules moduleSet, limiter *rateLimiter) {
+	//handle websocket request
+	if isWebsocketRequest(c) {
+		if err := server.handleWebsocket(c, wsModules, limiter); err != nil {
+			server.logger.Warnf("ws connect err")
 		}
-		body, err := ioutil.ReadAll(c.Request.Body)
-		if err != nil {
-			httperr(c, 500, fmt.Errorf("read body err: %s", err))
+		c.Abort()
+		return
+	}
+	if "POST" != c.Request.Method {
+		httperr(c, 404, errors.New("method not allowed"))
+		return
+	}
+	contentType := c.ContentType()
+	if contentType != "application/json" {
+		httperr(c, 404, errors.New("not acceptable"))
+		return
+	}
+	if nil == c.Request.Body {
+		httperr(c, 404, errors.New("body not be empty"))
+		return
+	}
+	key := rateLimitKey(c)
+	allow := func() bool {
+		return limiter.allow(key, time.Now())
+	}
+	if !allow() {
+		writeHTTPRPCError(c, http.StatusTooManyRequests, rateLimitedError)
+		return
+	}
+	if c.Request.ContentLength > server.maxBody {
+		writeHTTPRPCError(c, http.StatusRequestEntityTooLarge, requestTooLargeError)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, server.maxBody))
+	if err != nil {
+		if int64(len(body)) >= server.maxBody {
+			writeHTTPRPCError(c, http.StatusRequestEntityTooLarge, requestTooLargeError)
 			return
 		}
-		c.Status(200)
-		c.Header("Content-Type", "application/json; charset=utf-8")
-		var rpcId *int = nil
+		httperr(c, 500, fmt.Errorf("read body err: %s", err))
+		return
+	}
+	out := server.jsonRPCCall(body, nil, httpModules, allow)
+	if out == nil {
+		// only notifications
+		c.Status(204)
+		c.Abort()
+		return
+	}
+	c.Data(200, "application/json; charset=utf-8", out)
+	c.Abort()
+}
 
-		if err = server.jsonRPCCall(body, &rpcId, c.Writer); err != nil {
-			writeRPCError(err, rpcId, c.Writer)
-			return
+//Start starts rpc server.
+func (server *RPCServer) Start() error {
+	server.ginEngine.GET("/openrpc.json", server.handleOpenRPC)
+	server.ginEngine.Any("/", server.handle)
+	if server.config.AdminSocket != "" {
+		if err := server.startAdmin(); err != nil {
+			return err
 		}
-		c.Abort()
-	})
+	}
 	server.logger.Infof("start rpc server runing: %s", server.config.ListenAddr)
 	return server.ginEngine.Run(server.config.ListenAddr)
 }
+
+// startAdmin serves the admin socket, which only the owner of the process
+// can connect to.
+func (server *RPCServer) startAdmin() error {
+	path := server.config.AdminSocket
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// a socket left by a previous run
+	_ = os.Remove(path)
+	listener, err := listenAdmin(path)
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(path, 0600); err != nil {
+		_ = listener.Close()
+		return err
+	}
+	engine := gin.New()
+	engine.Use(ginlogger(server.logger))
+	engine.Use(gin.Recovery())
+	engine.GET("/openrpc.json", server.handleAdminOpenRPC)
+	engine.Any("/", server.handleAdmin)
+	server.logger.Infof("start rpc admin socket: %s", path)
+	go func() {
+		if err := http.Serve(listener, engine); err != nil {
+			server.logger.Warnf("rpc admin socket err: %s", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,212 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testRPCService struct{}
+
+type TestEchoArgs struct {
+	Name  string      `json:"name"`
+	Count json.Number `json:"count"`
+}
+
+func (*testRPCService) Echo(args TestEchoArgs, reply *string) error {
+	*reply = args.Name + ":" + args.Count.String()
+	return nil
+}
+
+func (*testRPCService) Panic(_ interface{}, _ *string) error {
+	panic("test")
+}
+
+func newTestRPCServer(t *testing.T, config *RPCConfig) (*RPCServer, *httptest.Server) {
+	server := NewRPCServer(config)
+	if err := server.RegisterName("Test", &testRPCService{}); err != nil {
+		t.Fatal(err)
+	}
+	server.ginEngine.GET("/openrpc.json", server.handleOpenRPC)
+	server.ginEngine.Any("/", server.handle)
+	ts := httptest.NewServer(server.ginEngine)
+	t.Cleanup(ts.Close)
+	return server, ts
+}
+
+func postRPC(t *testing.T, client *http.Client, url, body, token string) (int, string) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func testRPCPost(t *testing.T, body string) (int, string) {
+	_, ts := newTestRPCServer(t, &RPCConfig{})
+	return postRPC(t, ts.Client(), ts.URL, body, "")
+}
+
+func TestRPCServer_Call(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "object params",
+			body: `{"jsonrpc":"2.0","id":1,"method":"Test.Echo","params":{"name":"a","count":2}}`,
+			want: `{"jsonrpc":"2.0","id":1,"result":"a:2"}`,
+		},
+		{
+			name: "array params and string id",
+			body: `{"jsonrpc":"2.0","id":"x","method":"Test.Echo","params":["a",3]}`,
+			want: `{"jsonrpc":"2.0","id":"x","result":"a:3"}`,
+		},
+		{
+			name: "parse error",
+			body: `{"jsonrpc":"2.0",`,
+			want: `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`,
+		},
+		{
+			name: "invalid request",
+			body: `{"jsonrpc":"1.0","id":1,"method":"Test.Echo"}`,
+			want: `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"invalid request"}}`,
+		},
+		{
+			name: "invalid id",
+			body: `{"jsonrpc":"2.0","id":{},"method":"Test.Echo"}`,
+			want: `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid request"}}`,
+		},
+		{
+			name: "method not found",
+			body: `{"jsonrpc":"2.0","id":1,"method":"Test.Nothing"}`,
+			want: `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`,
+		},
+		{
+			name: "invalid params count",
+			body: `{"jsonrpc":"2.0","id":1,"method":"Test.Echo","params":["a"]}`,
+			want: `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid params"}}`,
+		},
+		{
+			name: "method panics",
+			body: `{"jsonrpc":"2.0","id":1,"method":"Test.Panic"}`,
+			want: `{"jsonrpc":"2.0","id":1,"error":{"code":-32603,"message":"internal error"}}`,
+		},
+		{
+			name: "empty batch",
+			body: `[]`,
+			want: `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid request"}}`,
+		},
+		{
+			name: "batch",
+			body: `[
+				{"jsonrpc":"2.0","id":1,"method":"Test.Echo","params":{"name":"a"}},
+				{"jsonrpc":"2.0","method":"Test.Echo","params":{"name":"b"}},
+				1,
+				{"jsonrpc":"2.0","id":"2","method":"Test.Nothing"}
+			]`,
+			want: `[{"jsonrpc":"2.0","id":1,"result":"a:"},` +
+				`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid request"}},` +
+				`{"jsonrpc":"2.0","id":"2","error":{"code":-32601,"message":"method not found"}}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, got := testRPCPost(t, tt.body)
+			if status != http.StatusOK || got != tt.want {
+				t.Fatalf("got status %d body %s, want %s", status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRPCServer_Notification(t *testing.T) {
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","method":"Test.Echo","params":{"name":"a"}}`,
+		`{"jsonrpc":"2.0","method":"Test.Nothing"}`,
+		`[{"jsonrpc":"2.0","method":"Test.Echo"},{"jsonrpc":"2.0","method":"Test.Panic"}]`,
+	} {
+		status, got := testRPCPost(t, body)
+		if status != http.StatusNoContent || got != "" {
+			t.Fatalf("got status %d body %s for %s", status, got, body)
+		}
+	}
+}
+
+func TestRPCServer_Modules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	socket := filepath.Join(dir, "admin.sock")
+	server, ts := newTestRPCServer(t, &RPCConfig{
+		HTTPModules: []string{"Chain"},
+		AdminSocket: socket,
+	})
+	if err = server.startAdmin(); err != nil {
+		t.Fatal(err)
+	}
+	body := `{"jsonrpc":"2.0","id":1,"method":"Test.Echo","params":{"name":"a"}}`
+	notFound := `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`
+	if _, got := postRPC(t, ts.Client(), ts.URL, body, ""); got != notFound {
+		t.Fatalf("got %s from a namespace which is not exposed", got)
+	}
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("got admin socket permissions %o", perm)
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	want := `{"jsonrpc":"2.0","id":1,"result":"a:"}`
+	if _, got := postRPC(t, client, "http://admin/", body, ""); got != want {
+		t.Fatalf("got %s from the admin socket, want %s", got, want)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"xfsgo/storage"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SchemaVersion is the version of the stored formats written by this code.
+// Stores written before the version was recorded have version 1.
+const SchemaVersion uint32 = 2
+
+var (
+	schemaVersionKey        = []byte("schemaVersion")
+	errStoreNotEmpty        = errors.New("store not empty")
+	errInvalidSchemaVersion = errors.New("invalid schema version")
+)
+
+// Stores are the databases of a node, their formats are versioned together.
+type Stores struct {
+	ChainDB storage.Database
+	KeysDB  storage.Database
+	StateDB storage.Database
+	ExtraDB storage.Database
+}
+
+func (s *Stores) list() []storage.Database {
+	return []storage.Database{s.ChainDB, s.KeysDB, s.StateDB, s.ExtraDB}
+}
+
+// Migration converts the stores from the previous version to Version.
+// Migrate returns the number of converted items and must be safe to run
+// again after an interruption. Count, when set, returns the number of
+// items Migrate would convert without writing anything.
+type Migration struct {
+	Version uint32
+	Name    string
+	Count   func(s *Stores) (int, error)
+	Migrate func(s *Stores) (int, error)
+}
+
+// migrations are the registered migrations, ordered by version.
+var migrations = []*Migration{
+	{
+		Version: 2,
+		Name:    "binary encoding of blocks and transactions",
+		Count: func(s *Stores) (int, error) {
+			keys, err := legacyBlockKeys(s.ChainDB)
+			return len(keys), err
+		},
+		Migrate: func(s *Stores) (int, error) {
+			return ConvertLegacyEncoding(s.ChainDB, s.ExtraDB)
+		},
+	},
+}
+
+// readStoreVersion returns the schema version of db, which is 0 when db is empty.
+func readStoreVersion(db storage.Database) (uint32, error) {
+	data, err := db.GetData(schemaVersionKey)
+	if err == nil {
+		if len(data) != 4 {
+			return 0, errInvalidSchemaVersion
+		}
+		return binary.LittleEndian.Uint32(data), nil
+	} else if err != storage.ErrNotFound {
+		return 0, err
+	}
+	err = db.ForeachData(func(k []byte, v []byte) error {
+		return errStoreNotEmpty
+	})
+	if err == errStoreNotEmpty {
+		return 1, nil
+	}
+	return 0, err
+}
+
+func writeStoreVersion(db storage.Database, version uint32) error {
+	var data [4]byte
+	binary.LittleEndian.PutUint32(data[:], version)
+	return db.SetData(schemaVersionKey, data[:])
+}
+
+// readSchemaVersions returns the lowest and the highest versions of the
+// stores which are not empty, both are 0 when all of them are empty.
+func readSchemaVersions(s *Stores) (lowest uint32, highest uint32, err error) {
+	for _, db := range s.list() {
+		v, err := readStoreVersion(db)
+		if err != nil {
+			return 0, 0, err
+		}
+		if v != 0 && (lowest == 0 || v < lowest) {
+			lowest = v
+		}
+		if v > highest {
+			highest = v
+		}
+	}
+	return lowest, highest, nil
+}
+
+// ReadSchemaVersion returns the schema version of the stores, the lowest one
+// of the stores which are not empty, or 0 when all of them are empty.
+func ReadSchemaVersion(s *Stores) (uint32, error) {
+	version, _, err := readSchemaVersions(s)
+	return version, err
+}
+
+func errSchemaTooNew(version uint32) error {
+	return fmt.Errorf("database schema version %d is newer than the supported %d", version, SchemaVersion)
+}
+
+func writeSchemaVersion(s *Stores, version uint32) error {
+	for _, db := range s.list() {
+		if err := writeStoreVersion(db, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckSchema records the current schema version in new stores and fails
+// when the stores were written with another version.
+func CheckSchema(s *Stores) error {
+	version, highest, err := readSchemaVersions(s)
+	if err != nil {
+		return err
+	}
+	switch {
+	case highest > SchemaVersion:
+		return errSchemaTooNew(highest)
+	case version != 0 && version < SchemaVersion:
+		return fmt.Errorf("database schema version %d is older than %d, run 'xfsgo db migrate'", version, SchemaVersion)
+	}
+	return writeSchemaVersion(s, SchemaVersion)
+}
+
+// PendingMigrations returns the migrations to run on stores of the given version.
+func PendingMigrations(version uint32) []*Migration {
+	pending := make([]*Migration, 0)
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// Migrate runs the pending migrations of the stores in order and records
+// the version reached after each one. With dryRun nothing is written and the
+// migrations report the number of items they would convert, or -1 when
+// they can't tell. report is called after each migration.
+func Migrate(s *Stores, dryRun bool, report func(m *Migration, n int)) error {
+	version, highest, err := readSchemaVersions(s)
+	if err != nil {
+		return err
+	}
+	if highest > SchemaVersion {
+		return errSchemaTooNew(highest)
+	}
+	if version == 0 {
+		// nothing to migrate in new stores
+		if dryRun {
+			return nil
+		}
+		return writeSchemaVersion(s, SchemaVersion)
+	}
+	for _, m := range PendingMigrations(version) {
+		n := -1
+		if dryRun {
+			if m.Count != nil {
+				if n, err = m.Count(s); err != nil {
+					return err
+				}
+			}
+		} else {
+			logrus.Infof("Migrating database schema to version %d: %s", m.Version, m.Name)
+			if n, err = m.Migrate(s); err != nil {
+				return fmt.Errorf("migration to version %d: %v", m.Version, err)
+			}
+			if err = writeSchemaVersion(s, m.Version); err != nil {
+				return err
+			}
+		}
+		report(m, n)
+	}
+	if dryRun {
+		return nil
+	}
+	return writeSchemaVersion(s, SchemaVersion)
+}
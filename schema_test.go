@@ -0,0 +1,101 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"encoding/json"
+	"testing"
+	"xfsgo/assert"
+	"xfsgo/common/rawencode"
+	"xfsgo/storage/memdb"
+)
+
+func newTestStores() *Stores {
+	return &Stores{
+		ChainDB: memdb.New(),
+		KeysDB:  memdb.New(),
+		StateDB: memdb.New(),
+		ExtraDB: memdb.New(),
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	s := newTestStores()
+	assert.Error(t, CheckSchema(s))
+	version, err := ReadSchemaVersion(s)
+	assert.Error(t, err)
+	if version != SchemaVersion {
+		t.Fatalf("got version %d, want %d", version, SchemaVersion)
+	}
+	assert.Error(t, CheckSchema(s))
+
+	assert.Error(t, writeStoreVersion(s.ExtraDB, SchemaVersion+1))
+	if err = CheckSchema(s); err == nil {
+		t.Fatalf("newer schema version accepted")
+	}
+}
+
+func TestCheckSchema_Unversioned(t *testing.T) {
+	s := newTestStores()
+	assert.Error(t, s.ChainDB.SetData([]byte("key"), []byte("value")))
+	version, err := ReadSchemaVersion(s)
+	assert.Error(t, err)
+	if version != 1 {
+		t.Fatalf("got version %d, want 1", version)
+	}
+	if err = CheckSchema(s); err == nil {
+		t.Fatalf("older schema version accepted")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	s := newTestStores()
+	block := NewBlock(&BlockHeader{Height: 1, Bits: 4}, nil, nil)
+	blockHash := block.Hash()
+	blockKey := append(append([]byte{}, blockHashPre...), blockHash[:]...)
+	blockData, err := json.Marshal(block)
+	assert.Error(t, err)
+	assert.Error(t, s.ChainDB.SetData(blockKey, blockData))
+
+	counts := make(map[uint32]int)
+	report := func(m *Migration, n int) {
+		counts[m.Version] = n
+	}
+	assert.Error(t, Migrate(s, true, report))
+	if counts[2] != 1 {
+		t.Fatalf("got dry run count %d, want 1", counts[2])
+	}
+	val, err := s.ChainDB.GetData(blockKey)
+	assert.Error(t, err)
+	if !rawencode.IsLegacyJSON(val) {
+		t.Fatalf("dry run converted the block")
+	}
+	if version, _ := ReadSchemaVersion(s); version != 1 {
+		t.Fatalf("dry run wrote version %d", version)
+	}
+
+	assert.Error(t, Migrate(s, false, report))
+	if version, _ := ReadSchemaVersion(s); version != SchemaVersion {
+		t.Fatalf("got version %d after migration, want %d", version, SchemaVersion)
+	}
+	val, err = s.ChainDB.GetData(blockKey)
+	assert.Error(t, err)
+	if rawencode.IsLegacyJSON(val) {
+		t.Fatalf("block not converted")
+	}
+	assert.Error(t, CheckSchema(s))
+}
@@ -0,0 +1,139 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"errors"
+	"time"
+	"xfsgo/avlmerkle"
+	"xfsgo/storage"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultStateKeepBlocks is the number of recent blocks whose state is kept
+// when the state is pruned.
+const DefaultStateKeepBlocks = 128
+
+var errNoHeadBlock = errors.New("no head block")
+
+// StatePruner deletes the state tree nodes that are not reachable from the
+// state root of the genesis block or of the blocks of the last keep heights,
+// canonical or not. The state of older blocks is no longer readable then, so
+// blocks on top of them can't be inserted.
+type StatePruner struct {
+	stateDB  storage.Database
+	chainDB  *chainDB
+	eventBus *EventBus
+	keep     uint64
+	// lastHeight is the head height at the last prune.
+	lastHeight uint64
+	// headCh coalesces the head events received while pruning.
+	headCh chan struct{}
+}
+
+func NewStatePruner(stateDB, chainDB storage.Database, eventBus *EventBus, keep uint64) *StatePruner {
+	if keep == 0 {
+		keep = DefaultStateKeepBlocks
+	}
+	return &StatePruner{
+		stateDB:  stateDB,
+		chainDB:  newChainDB(chainDB),
+		eventBus: eventBus,
+		keep:     keep,
+		headCh:   make(chan struct{}, 1),
+	}
+}
+
+// keptRoots returns the state roots to keep when head is the head block.
+func (p *StatePruner) keptRoots(head *Block) [][]byte {
+	roots := make([][]byte, 0, p.keep+1)
+	if genesis := p.chainDB.GetBlockByNumber(0); genesis != nil {
+		roots = append(roots, genesis.Header.StateRoot.Bytes())
+	}
+	height := head.Height()
+	for i := uint64(0); i < p.keep && i < height; i++ {
+		// the canonical block may have been written before the height index
+		if block := p.chainDB.GetBlockByNumber(height - i); block != nil {
+			roots = append(roots, block.Header.StateRoot.Bytes())
+		}
+		for _, hash := range p.chainDB.getBlockHashesByHeight(height - i) {
+			if block := p.chainDB.GetBlockByHash(hash); block != nil {
+				roots = append(roots, block.Header.StateRoot.Bytes())
+			}
+		}
+	}
+	return roots
+}
+
+// Prune deletes the unreachable state tree nodes and returns how many were deleted.
+func (p *StatePruner) Prune() (int, error) {
+	head := p.chainDB.GetHeadBlock()
+	if head == nil {
+		return 0, errNoHeadBlock
+	}
+	n, err := avlmerkle.Prune(p.stateDB, p.keptRoots(head))
+	if err != nil {
+		return 0, err
+	}
+	p.lastHeight = head.Height()
+	return n, nil
+}
+
+// Start prunes the state in the background every keep blocks.
+func (p *StatePruner) Start() {
+	go p.pruneLoop()
+	go p.eventLoop(p.eventBus.Subscript(ChainHeadEvent{}))
+}
+
+// eventLoop only signals pruneLoop, so that a long prune does not hold
+// the delivery of head events to the other subscribers.
+func (p *StatePruner) eventLoop(chainHeadEventSub *Subscription) {
+	defer chainHeadEventSub.Unsubscribe()
+	for {
+		select {
+		case <-chainHeadEventSub.Chan():
+			select {
+			case p.headCh <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (p *StatePruner) pruneLoop() {
+	for range p.headCh {
+		head := p.chainDB.GetHeadBlock()
+		if head == nil || head.Height() < p.lastHeight+p.keep {
+			continue
+		}
+		start := time.Now()
+		n, err := p.Prune()
+		if err != nil {
+			logrus.Warnf("Failed prune state: %s", err)
+			continue
+		}
+		logrus.Infof("Pruned state: height=%d, nodes=%d, elapsed=%s",
+			p.lastHeight, n, time.Since(start))
+	}
+}
+
+// PruneStateDB prunes the state of a stopped node, keeping the state of the
+// last keep blocks, and returns the number of deleted tree nodes.
+func PruneStateDB(stateDB, chainDB storage.Database, keep uint64) (int, error) {
+	return NewStatePruner(stateDB, chainDB, nil, keep).Prune()
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package xfsgo
+
+import (
+	"math/big"
+	"testing"
+	"time"
+	"xfsgo/assert"
+	"xfsgo/common"
+)
+
+// mineTestBlockOn mines an empty block paying coinbase on top of parent,
+// without inserting it.
+func mineTestBlockOn(t *testing.T, bc *BlockChain, parent *Block, coinbase common.Address) *Block {
+	header := &BlockHeader{
+		Height:        parent.Height() + 1,
+		Version:       BlockVersion,
+		HashPrevBlock: parent.Hash(),
+		Timestamp:     parent.Timestamp() + 1,
+		Coinbase:      coinbase,
+		Bits:          parent.Bits(),
+	}
+	stateTree, err := bc.StateAt(parent.StateRoot())
+	assert.Error(t, err)
+	AccumulateRewards(stateTree, header)
+	stateTree.UpdateAll()
+	header.StateRoot = common.Bytes2Hash(stateTree.Root())
+	block := NewBlock(header, nil, nil)
+	target := BitsUnzip(block.Bits())
+	for nonce := uint64(0); ; nonce++ {
+		hash := block.UpdateNonce(nonce)
+		if new(big.Int).SetBytes(hash[:]).Cmp(target) <= 0 {
+			return block
+		}
+	}
+}
+
+func TestStatePruner_SideChains(t *testing.T) {
+	bc := newTestChain(t)
+	genesis := bc.CurrentBlock()
+	var canonical []*Block
+	for i := 0; i < 4; i++ {
+		canonical = append(canonical, mineTestBlock(t, bc))
+	}
+	_, coinbase := newTestAccount(t)
+	// a side block out of the kept heights and one within them
+	oldSide := mineTestBlockOn(t, bc, genesis, coinbase)
+	assert.Error(t, bc.InsertChain(oldSide))
+	side := mineTestBlockOn(t, bc, canonical[2], coinbase)
+	assert.Error(t, bc.InsertChain(side))
+	oldSideChild := mineTestBlockOn(t, bc, oldSide, coinbase)
+	sideChild := mineTestBlockOn(t, bc, side, coinbase)
+	assert.HashEqual(t, bc.CurrentBlock().Hash(), canonical[3].Hash())
+
+	if _, err := PruneStateDB(bc.stateDB, bc.chainDB.storage, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.StateAt(oldSide.StateRoot()); err != ErrStateNotFound {
+		t.Fatalf("got err %v, want the state of the old side block pruned", err)
+	}
+	if err := bc.InsertChain(oldSideChild); err == nil {
+		t.Fatalf("inserted a block whose parent state is pruned")
+	}
+	assert.Error(t, bc.InsertChain(sideChild))
+	assert.HashEqual(t, bc.CurrentBlock().Hash(), sideChild.Hash())
+}
+
+func TestStatePruner_EventLoop(t *testing.T) {
+	bc := newTestChain(t)
+	bus := NewEventBus()
+	p := NewStatePruner(bc.stateDB, bc.chainDB.storage, bus, 1)
+	// pruneLoop is not started, as if a prune never ended
+	go p.eventLoop(bus.Subscript(ChainHeadEvent{}))
+	other := bus.Subscript(ChainHeadEvent{})
+	for i := 0; i < 3; i++ {
+		bus.Publish(ChainHeadEvent{bc.CurrentBlock()})
+		select {
+		case <-other.Chan():
+		case <-time.After(time.Second):
+			t.Fatalf("head event %d not delivered while pruning", i)
+		}
+	}
+	if n := len(p.headCh); n != 1 {
+		t.Fatalf("got %d pending prunes, want 1", n)
+	}
+}
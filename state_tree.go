@@ -17,14 +17,16 @@
 package xfsgo
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"math/big"
 	"xfsgo/avlmerkle"
 	"xfsgo/common"
 	"xfsgo/common/ahash"
 	"xfsgo/common/rawencode"
-	"xfsgo/storage/badger"
+	"xfsgo/storage"
 	"xfsgo/uint256"
 
 	"github.com/sirupsen/logrus"
@@ -147,14 +149,22 @@ func (so *StateObj) Update() {
 	so.merkleTree.Put(hash, objRaw)
 }
 
+var (
+	// ErrStateNotFound is returned when opening the state of a block which
+	// is not stored, most likely because it has been pruned.
+	ErrStateNotFound = errors.New("state not found, it may have been pruned")
+	errStateReadOnly = errors.New("state tree is read only")
+)
+
 type StateTree struct {
 	root       []byte
-	treeDB     *badger.Storage
+	treeDB     storage.Database
 	merkleTree *avlmerkle.Tree
 	objs       map[common.Address]*StateObj
+	readOnly   bool
 }
 
-func NewStateTree(db *badger.Storage, root []byte) *StateTree {
+func NewStateTree(db storage.Database, root []byte) *StateTree {
 	st := &StateTree{
 		root:   root,
 		treeDB: db,
@@ -164,6 +174,34 @@ func NewStateTree(db *badger.Storage, root []byte) *StateTree {
 	return st
 }
 
+// OpenStateTree opens the state at root for reading, it fails with
+// ErrStateNotFound when the root is not stored. The changes made to the
+// returned tree stay in memory, it can't be committed.
+func OpenStateTree(db storage.Database, root []byte) (*StateTree, error) {
+	st, err := openStateTree(db, root)
+	if err != nil {
+		return nil, err
+	}
+	st.readOnly = true
+	return st, nil
+}
+
+// openStateTree is like OpenStateTree, but the returned tree can be committed.
+func openStateTree(db storage.Database, root []byte) (*StateTree, error) {
+	merkleTree, err := avlmerkle.OpenTree(db, root)
+	if err == storage.ErrNotFound {
+		return nil, ErrStateNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &StateTree{
+		root:       root,
+		treeDB:     db,
+		merkleTree: merkleTree,
+		objs:       make(map[common.Address]*StateObj),
+	}, nil
+}
+
 func (st *StateTree) HashAccount(addr common.Address) bool {
 	return st.GetStateObj(addr) != nil
 }
@@ -239,6 +277,56 @@ func (st *StateTree) GetOrNewStateObj(addr common.Address) *StateObj {
 	return stateObj
 }
 
+// GetProof returns the merkle proof of the account addr against Root.
+// Accounts without state get a proof of their absence.
+// Only committed changes are proven.
+func (st *StateTree) GetProof(addr common.Address) (*avlmerkle.Proof, error) {
+	hash := ahash.SHA256(addr.Bytes())
+	return st.merkleTree.Prove(hash)
+}
+
+// VerifyStateProof checks the proof of the account addr against the state root,
+// it returns the proven account or nil when the proof shows it has no state.
+func VerifyStateProof(root common.Hash, addr common.Address, proof *avlmerkle.Proof) (*StateObj, error) {
+	if proof == nil {
+		return nil, avlmerkle.ErrInvalidProof
+	}
+	key := ahash.SHA256(addr.Bytes())
+	if proof.Leaf == nil || !bytes.Equal(proof.Leaf.Key, key) {
+		if err := avlmerkle.VerifyProof(root.Bytes(), key, nil, proof); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	value := proof.Leaf.Value
+	if err := avlmerkle.VerifyProof(root.Bytes(), key, value, proof); err != nil {
+		return nil, err
+	}
+	obj := &StateObj{}
+	if err := rawencode.Decode(value, obj); err != nil {
+		return nil, err
+	}
+	if obj.address != addr {
+		return nil, avlmerkle.ErrKeyNotProven
+	}
+	return obj, nil
+}
+
+// IterateAccounts calls fn for the updated accounts in the order of their
+// keys, the hashes of the addresses, from the key start on. The iteration
+// stops when fn returns true.
+func (st *StateTree) IterateAccounts(start []byte, fn func(key []byte, obj *StateObj) bool) error {
+	var err error
+	st.merkleTree.Iterate(start, nil, true, func(key []byte, value []byte) bool {
+		obj := &StateObj{}
+		if err = rawencode.Decode(value, obj); err != nil {
+			return true
+		}
+		return fn(key, obj)
+	})
+	return err
+}
+
 func (st *StateTree) Root() []byte {
 	return st.merkleTree.Checksum()
 }
@@ -258,6 +346,9 @@ func (st *StateTree) UpdateAll() {
 }
 
 func (st *StateTree) Commit() error {
+	if st.readOnly {
+		return errStateReadOnly
+	}
 	return st.merkleTree.Commit()
 }
 
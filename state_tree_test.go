@@ -17,13 +17,16 @@
 package xfsgo
 
 import (
+	"bytes"
 	"encoding/hex"
 	"math/big"
 	"testing"
+	"xfsgo/avlmerkle"
 	"xfsgo/common"
 	"xfsgo/common/ahash"
 	"xfsgo/crypto"
 	"xfsgo/storage/badger"
+	"xfsgo/storage/memdb"
 )
 
 func caddr() common.Address {
@@ -114,3 +117,122 @@ func TestEn3(t *testing.T) {
 	hash := ahash.SHA256(append([]byte("hello"), []byte("2619202")...))
 	t.Logf("balance: %x\n", hash)
 }
+
+func TestVerifyStateProof(t *testing.T) {
+	st := NewStateTree(nil, nil)
+	addrs := []common.Address{caddr(), caddr(), caddr(), caddr()}
+	for i, addr := range addrs {
+		st.AddBalance(addr, big.NewInt(int64(i+1)))
+	}
+	st.UpdateAll()
+	root := common.Bytes2Hash(st.Root())
+	for i, addr := range addrs {
+		proof, err := st.GetProof(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj, err := VerifyStateProof(root, addr, proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if obj == nil || obj.GetBalance().Int64() != int64(i+1) {
+			t.Fatalf("got account %v, want balance %d", obj, i+1)
+		}
+	}
+	missing := caddr()
+	proof, err := st.GetProof(missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := VerifyStateProof(root, missing, proof)
+	if err != nil || obj != nil {
+		t.Fatalf("got account %v err %v, want absence", obj, err)
+	}
+	// the proof of an account does not prove another one
+	proof, _ = st.GetProof(addrs[0])
+	if _, err = VerifyStateProof(root, addrs[1], proof); err == nil {
+		t.Fatalf("proof of %s should not verify for %s", addrs[0].B58String(), addrs[1].B58String())
+	}
+}
+
+func TestOpenStateTree(t *testing.T) {
+	stateDb := memdb.New()
+	addr := caddr()
+	st := NewStateTree(stateDb, nil)
+	st.AddBalance(addr, big.NewInt(1))
+	st.UpdateAll()
+	if err := st.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	oldRoot := st.Root()
+	st.AddBalance(addr, big.NewInt(2))
+	st.UpdateAll()
+	if err := st.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	old, err := OpenStateTree(stateDb, oldRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := old.GetBalance(addr); got.Int64() != 1 {
+		t.Fatalf("got balance %d at old root, want 1", got)
+	}
+	old.AddBalance(addr, big.NewInt(5))
+	old.UpdateAll()
+	if err = old.Commit(); err != errStateReadOnly {
+		t.Fatalf("got err %v, want %v", err, errStateReadOnly)
+	}
+	if _, err = avlmerkle.Prune(stateDb, [][]byte{st.Root()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenStateTree(stateDb, oldRoot); err != ErrStateNotFound {
+		t.Fatalf("got err %v, want %v", err, ErrStateNotFound)
+	}
+}
+
+func TestStateTree_IterateAccounts(t *testing.T) {
+	st := NewStateTree(nil, nil)
+	balances := make(map[common.Address]int64)
+	for i := 0; i < 20; i++ {
+		addr := caddr()
+		st.AddBalance(addr, big.NewInt(int64(i+1)))
+		balances[addr] = int64(i + 1)
+	}
+	st.UpdateAll()
+	var (
+		keys [][]byte
+		last []byte
+	)
+	err := st.IterateAccounts(nil, func(key []byte, obj *StateObj) bool {
+		if last != nil && bytes.Compare(last, key) >= 0 {
+			t.Fatalf("key %x after %x", key, last)
+		}
+		addr := obj.GetAddress()
+		if want := balances[addr]; obj.GetBalance().Int64() != want {
+			t.Fatalf("got balance %d for %s, want %d", obj.GetBalance(), addr.B58String(), want)
+		}
+		last = key
+		keys = append(keys, key)
+		return false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != len(balances) {
+		t.Fatalf("got %d accounts, want %d", len(keys), len(balances))
+	}
+	// resumes at a key and stops early
+	count := 0
+	if err = st.IterateAccounts(keys[10], func(key []byte, obj *StateObj) bool {
+		if count == 0 && !bytes.Equal(key, keys[10]) {
+			t.Fatalf("got first key %x, want %x", key, keys[10])
+		}
+		count++
+		return count == 5
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 {
+		t.Fatalf("got %d accounts, want 5", count)
+	}
+}
@@ -21,12 +21,16 @@ import (
 	"github.com/sirupsen/logrus"
 	"io/ioutil"
 	"log"
+	"xfsgo/storage"
 )
 
+// Storage is a storage.Database stored on disk by badger.
 type Storage struct {
 	db *badger.DB
 }
 
+var _ storage.Database = (*Storage)(nil)
+
 type loggingLevel int
 
 const (
@@ -92,6 +96,47 @@ func (b *StorageWriteBatch) Delete(key []byte) error {
 	return b.batch.Delete(key)
 }
 
+// ErrKeyNotFound is returned by GetData when the key is not stored.
+var ErrKeyNotFound = storage.ErrNotFound
+
+// ErrTxnTooBig is returned by StorageTxn.Put when the transaction reached
+// the size badger can commit at once.
+var ErrTxnTooBig = storage.ErrTxnTooBig
+
+func convertErr(err error) error {
+	switch err {
+	case badger.ErrKeyNotFound:
+		return ErrKeyNotFound
+	case badger.ErrTxnTooBig:
+		return ErrTxnTooBig
+	}
+	return err
+}
+
+// StorageTxn groups writes which become visible all at once on Commit,
+// or not at all when it is discarded or the process stops before.
+type StorageTxn struct {
+	txn *badger.Txn
+}
+
+func (t *StorageTxn) Put(key, value []byte) error {
+	k := append([]byte{}, key...)
+	v := append([]byte{}, value...)
+	return convertErr(t.txn.Set(k, v))
+}
+
+func (t *StorageTxn) Delete(key []byte) error {
+	return convertErr(t.txn.Delete(append([]byte{}, key...)))
+}
+
+func (t *StorageTxn) Commit() error {
+	return t.txn.Commit()
+}
+
+func (t *StorageTxn) Discard() {
+	t.txn.Discard()
+}
+
 func defaultLogger(level loggingLevel) *defaultLog {
 	return &defaultLog{
 		Logger: log.New(ioutil.Discard, "badger ", log.LstdFlags),
@@ -122,13 +167,21 @@ func (storage *Storage) SetData(key []byte, val []byte) error {
 	})
 }
 
-func (storage *Storage) NewWriteBatch() *StorageWriteBatch {
+func (storage *Storage) NewWriteBatch() storage.WriteBatch {
 	return &StorageWriteBatch{
 		batch: storage.db.NewWriteBatch(),
 	}
 }
-func (storage *Storage) CommitWriteBatch(batch *StorageWriteBatch) error {
-	return batch.batch.Flush()
+func (storage *Storage) CommitWriteBatch(batch storage.WriteBatch) error {
+	return batch.(*StorageWriteBatch).batch.Flush()
+}
+
+// NewTxn starts an atomic write transaction. Unlike a write batch, which
+// badger may flush in several parts, it is committed as a whole.
+func (storage *Storage) NewTxn() storage.Txn {
+	return &StorageTxn{
+		txn: storage.db.NewTransaction(true),
+	}
 }
 
 func (storage *Storage) Get(key string) ([]byte, error) {
@@ -136,19 +189,36 @@ func (storage *Storage) Get(key string) ([]byte, error) {
 }
 func (storage *Storage) GetData(key []byte) (val []byte, err error) {
 	err = storage.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(key)
-		if err != nil {
-			return err
-		}
-		val, err = item.ValueCopy(val)
-		if err != nil {
-			return err
-		}
-		return nil
+		val, err = getData(txn, key)
+		return err
 	})
 	return
 }
 
+func getData(txn *badger.Txn, key []byte) ([]byte, error) {
+	item, err := txn.Get(key)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return item.ValueCopy(nil)
+}
+
+func (storage *Storage) HasData(key []byte) (has bool, err error) {
+	err = storage.db.View(func(txn *badger.Txn) error {
+		has, err = hasData(txn, key)
+		return err
+	})
+	return
+}
+
+func hasData(txn *badger.Txn, key []byte) (bool, error) {
+	_, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (storage *Storage) Del(key string) error {
 	return storage.DelData([]byte(key))
 }
@@ -267,32 +337,33 @@ func (storage *Storage) PrefixForeach(prefix string,fn func(k string,v []byte) e
 
 func (storage *Storage) PrefixForeachData(prefix []byte, fn func(k []byte, v []byte) error) error {
 	return storage.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		it := txn.NewIterator(opts)
-		defer it.Close()
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			key := item.Key()
-			val, err := item.ValueCopy(nil)
-			if err != nil {
-				return err
-			}
-			err = fn(key, val)
-			if err != nil {
-				return err
-			}
-		}
-		return nil
+		return prefixForeach(txn, prefix, fn)
 	})
 }
 
-type Iterator interface {
-	Next() bool
-	Key() []byte
-	Val() []byte
-	Close()
+func prefixForeach(txn *badger.Txn, prefix []byte, fn func(k []byte, v []byte) error) error {
+	opts := badger.DefaultIteratorOptions
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		key := item.Key()
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		err = fn(key, val)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
+type Iterator = storage.Iterator
+
+// dbIterator moves the badger iterator on the next call of Next, the
+// current item is only valid until then.
 type dbIterator struct {
 	it *badger.Iterator
 	txn *badger.Txn
@@ -300,14 +371,22 @@ type dbIterator struct {
 }
 
 func (it *dbIterator) Next() bool {
+	if it.current != nil {
+		it.it.Next()
+	}
 	if !it.it.Valid() {
+		it.current = nil
 		return false
 	}
 	it.current = it.it.Item()
-	it.it.Next()
 	return true
 }
 
+func (it *dbIterator) Seek(key []byte) {
+	it.it.Seek(key)
+	it.current = nil
+}
+
 func (it *dbIterator) Key() []byte {
 	return it.current.Key()
 }
@@ -323,7 +402,7 @@ func (it *dbIterator) Close() {
 	it.txn.Discard()
 }
 
-func (storage *Storage) NewIterator() Iterator {
+func (storage *Storage) NewIterator() storage.Iterator {
 	mTxn := storage.db.NewTransaction(true)
 	opts := badger.DefaultIteratorOptions
 	mIt := mTxn.NewIterator(opts)
@@ -333,3 +412,30 @@ func (storage *Storage) NewIterator() Iterator {
 		txn: mTxn,
 	}
 }
+
+// storageSnapshot reads the database in a read only transaction.
+type storageSnapshot struct {
+	txn *badger.Txn
+}
+
+func (s *storageSnapshot) GetData(key []byte) ([]byte, error) {
+	return getData(s.txn, key)
+}
+
+func (s *storageSnapshot) HasData(key []byte) (bool, error) {
+	return hasData(s.txn, key)
+}
+
+func (s *storageSnapshot) PrefixForeachData(prefix []byte, fn func(k []byte, v []byte) error) error {
+	return prefixForeach(s.txn, prefix, fn)
+}
+
+func (s *storageSnapshot) Release() {
+	s.txn.Discard()
+}
+
+func (storage *Storage) NewSnapshot() storage.Snapshot {
+	return &storageSnapshot{
+		txn: storage.db.NewTransaction(false),
+	}
+}
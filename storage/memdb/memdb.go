@@ -0,0 +1,306 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+// Package memdb implements storage.Database in memory, for tests and
+// for nodes which don't need to keep their data.
+package memdb
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"xfsgo/storage"
+)
+
+var errClosed = errors.New("database closed")
+
+// Storage is a storage.Database kept in a map. Iterations work on a sorted
+// copy of the keys, which is fine for the sizes it is meant for.
+type Storage struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+var _ storage.Database = (*Storage)(nil)
+
+func New() *Storage {
+	return &Storage{
+		data: make(map[string][]byte),
+	}
+}
+
+func (db *Storage) GetData(key []byte) ([]byte, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.data == nil {
+		return nil, errClosed
+	}
+	return get(db.data, key)
+}
+
+func get(data map[string][]byte, key []byte) ([]byte, error) {
+	val, ok := data[string(key)]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return append([]byte{}, val...), nil
+}
+
+func (db *Storage) HasData(key []byte) (bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.data == nil {
+		return false, errClosed
+	}
+	_, ok := db.data[string(key)]
+	return ok, nil
+}
+
+func (db *Storage) SetData(key []byte, val []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.data == nil {
+		return errClosed
+	}
+	db.data[string(key)] = append([]byte{}, val...)
+	return nil
+}
+
+func (db *Storage) DelData(key []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.data == nil {
+		return errClosed
+	}
+	delete(db.data, string(key))
+	return nil
+}
+
+// sortedItems returns the keys starting with prefix and their values
+// in ascending order.
+func sortedItems(data map[string][]byte, prefix []byte) ([]string, [][]byte) {
+	keys := make([]string, 0)
+	for k := range data {
+		if strings.HasPrefix(k, string(prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	vals := make([][]byte, len(keys))
+	for i, k := range keys {
+		vals[i] = data[k]
+	}
+	return keys, vals
+}
+
+// foreach calls fn without holding the lock, so that it may write to db.
+func (db *Storage) foreach(prefix []byte, fn func(k []byte, v []byte) error) error {
+	db.mu.RLock()
+	if db.data == nil {
+		db.mu.RUnlock()
+		return errClosed
+	}
+	keys, vals := sortedItems(db.data, prefix)
+	db.mu.RUnlock()
+	return foreachItem(keys, vals, fn)
+}
+
+func foreachItem(keys []string, vals [][]byte, fn func(k []byte, v []byte) error) error {
+	for i, k := range keys {
+		if err := fn([]byte(k), append([]byte{}, vals[i]...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *Storage) PrefixForeachData(prefix []byte, fn func(k []byte, v []byte) error) error {
+	return db.foreach(prefix, fn)
+}
+
+func (db *Storage) ForeachData(fn func(k []byte, v []byte) error) error {
+	return db.foreach(nil, fn)
+}
+
+type iterator struct {
+	keys  []string
+	vals  [][]byte
+	index int
+}
+
+func (it *iterator) Next() bool {
+	if it.index >= len(it.keys) {
+		return false
+	}
+	it.index++
+	return true
+}
+
+func (it *iterator) Seek(key []byte) {
+	it.index = sort.SearchStrings(it.keys, string(key))
+}
+
+func (it *iterator) Key() []byte {
+	return []byte(it.keys[it.index-1])
+}
+
+func (it *iterator) Val() []byte {
+	return append([]byte{}, it.vals[it.index-1]...)
+}
+
+func (it *iterator) Close() {}
+
+func (db *Storage) NewIterator() storage.Iterator {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	it := &iterator{}
+	if db.data != nil {
+		it.keys, it.vals = sortedItems(db.data, nil)
+	}
+	return it
+}
+
+// write is a delete when val is nil.
+type write struct {
+	key []byte
+	val []byte
+}
+
+// writes buffers the writes of a batch or a transaction.
+type writes struct {
+	db     *Storage
+	writes []write
+}
+
+func (w *writes) Put(key, value []byte) error {
+	w.writes = append(w.writes, write{
+		key: append([]byte{}, key...),
+		val: append([]byte{}, value...),
+	})
+	return nil
+}
+
+func (w *writes) Delete(key []byte) error {
+	w.writes = append(w.writes, write{
+		key: append([]byte{}, key...),
+	})
+	return nil
+}
+
+func (w *writes) apply() error {
+	w.db.mu.Lock()
+	defer w.db.mu.Unlock()
+	if w.db.data == nil {
+		return errClosed
+	}
+	for _, item := range w.writes {
+		if item.val == nil {
+			delete(w.db.data, string(item.key))
+		} else {
+			w.db.data[string(item.key)] = item.val
+		}
+	}
+	w.writes = nil
+	return nil
+}
+
+type writeBatch struct {
+	writes
+}
+
+func (b *writeBatch) Destroy() {
+	b.writes.writes = nil
+}
+
+func (db *Storage) NewWriteBatch() storage.WriteBatch {
+	return &writeBatch{
+		writes: writes{db: db},
+	}
+}
+
+func (db *Storage) CommitWriteBatch(batch storage.WriteBatch) error {
+	return batch.(*writeBatch).apply()
+}
+
+type txn struct {
+	writes
+}
+
+func (t *txn) Commit() error {
+	return t.apply()
+}
+
+func (t *txn) Discard() {
+	t.writes.writes = nil
+}
+
+func (db *Storage) NewTxn() storage.Txn {
+	return &txn{
+		writes: writes{db: db},
+	}
+}
+
+// snapshot holds a copy of the data, the values are never modified
+// in place so they are shared.
+type snapshot struct {
+	data map[string][]byte
+}
+
+func (s *snapshot) GetData(key []byte) ([]byte, error) {
+	return get(s.data, key)
+}
+
+func (s *snapshot) HasData(key []byte) (bool, error) {
+	_, ok := s.data[string(key)]
+	return ok, nil
+}
+
+func (s *snapshot) PrefixForeachData(prefix []byte, fn func(k []byte, v []byte) error) error {
+	keys, vals := sortedItems(s.data, prefix)
+	return foreachItem(keys, vals, fn)
+}
+
+func (s *snapshot) Release() {
+	s.data = nil
+}
+
+func (db *Storage) NewSnapshot() storage.Snapshot {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	data := make(map[string][]byte, len(db.data))
+	for k, v := range db.data {
+		data[k] = v
+	}
+	return &snapshot{data: data}
+}
+
+func (db *Storage) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.data == nil {
+		return errClosed
+	}
+	db.data = nil
+	return nil
+}
+
+// Len returns the number of stored keys.
+func (db *Storage) Len() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return len(db.data)
+}
@@ -0,0 +1,166 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package memdb
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"xfsgo/storage"
+	"xfsgo/storage/badger"
+)
+
+// testEngines runs fn against the in-memory and the badger databases,
+// which must behave the same.
+func testEngines(t *testing.T, fn func(t *testing.T, db storage.Database)) {
+	t.Run("memdb", func(t *testing.T) {
+		db := New()
+		defer func() {
+			_ = db.Close()
+		}()
+		fn(t, db)
+	})
+	t.Run("badger", func(t *testing.T) {
+		db := badger.New(t.TempDir())
+		defer func() {
+			_ = db.Close()
+		}()
+		fn(t, db)
+	})
+}
+
+func collectKeys(t *testing.T, r storage.Reader, prefix string) string {
+	var buf bytes.Buffer
+	err := r.PrefixForeachData([]byte(prefix), func(k []byte, v []byte) error {
+		_, _ = fmt.Fprintf(&buf, "%s=%s;", k, v)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestDatabase_GetSetDelete(t *testing.T) {
+	testEngines(t, func(t *testing.T, db storage.Database) {
+		if _, err := db.GetData([]byte("a")); err != storage.ErrNotFound {
+			t.Fatalf("got err %v, want %v", err, storage.ErrNotFound)
+		}
+		if err := db.SetData([]byte("a"), []byte("1")); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := db.GetData([]byte("a")); err != nil || string(got) != "1" {
+			t.Fatalf("got %q err %v", got, err)
+		}
+		if has, err := db.HasData([]byte("a")); err != nil || !has {
+			t.Fatalf("got has %v err %v", has, err)
+		}
+		if err := db.DelData([]byte("a")); err != nil {
+			t.Fatal(err)
+		}
+		if has, err := db.HasData([]byte("a")); err != nil || has {
+			t.Fatalf("got has %v err %v after delete", has, err)
+		}
+	})
+}
+
+func TestDatabase_Iterate(t *testing.T) {
+	testEngines(t, func(t *testing.T, db storage.Database) {
+		for _, k := range []string{"b:2", "a:1", "b:1", "c:1", "b:3"} {
+			if err := db.SetData([]byte(k), []byte(k)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got, want := collectKeys(t, db, "b:"), "b:1=b:1;b:2=b:2;b:3=b:3;"; got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+		keys := ""
+		it := db.NewIterator()
+		for it.Next() {
+			keys += string(it.Key()) + ";"
+		}
+		it.Close()
+		if want := "a:1;b:1;b:2;b:3;c:1;"; keys != want {
+			t.Fatalf("got %s, want %s", keys, want)
+		}
+		keys = ""
+		it = db.NewIterator()
+		it.Seek([]byte("b:15"))
+		for it.Next() {
+			keys += string(it.Key()) + ";"
+		}
+		it.Close()
+		if want := "b:2;b:3;c:1;"; keys != want {
+			t.Fatalf("got %s after seek, want %s", keys, want)
+		}
+	})
+}
+
+func TestDatabase_BatchAndTxn(t *testing.T) {
+	testEngines(t, func(t *testing.T, db storage.Database) {
+		if err := db.SetData([]byte("old"), []byte("0")); err != nil {
+			t.Fatal(err)
+		}
+		batch := db.NewWriteBatch()
+		_ = batch.Put([]byte("k1"), []byte("1"))
+		_ = batch.Delete([]byte("old"))
+		if has, _ := db.HasData([]byte("k1")); has {
+			t.Fatalf("batch write visible before commit")
+		}
+		if err := db.CommitWriteBatch(batch); err != nil {
+			t.Fatal(err)
+		}
+		if got := collectKeys(t, db, ""); got != "k1=1;" {
+			t.Fatalf("got %s after batch", got)
+		}
+
+		txn := db.NewTxn()
+		_ = txn.Put([]byte("k2"), []byte("2"))
+		txn.Discard()
+		if has, _ := db.HasData([]byte("k2")); has {
+			t.Fatalf("discarded txn write is stored")
+		}
+		txn = db.NewTxn()
+		_ = txn.Put([]byte("k2"), []byte("2"))
+		_ = txn.Delete([]byte("k1"))
+		if err := txn.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if got := collectKeys(t, db, ""); got != "k2=2;" {
+			t.Fatalf("got %s after txn", got)
+		}
+	})
+}
+
+func TestDatabase_Snapshot(t *testing.T) {
+	testEngines(t, func(t *testing.T, db storage.Database) {
+		_ = db.SetData([]byte("a"), []byte("1"))
+		snap := db.NewSnapshot()
+		defer snap.Release()
+		_ = db.SetData([]byte("a"), []byte("2"))
+		_ = db.SetData([]byte("b"), []byte("1"))
+		if got, err := snap.GetData([]byte("a")); err != nil || string(got) != "1" {
+			t.Fatalf("got %q err %v from snapshot", got, err)
+		}
+		if has, _ := snap.HasData([]byte("b")); has {
+			t.Fatalf("later write visible in snapshot")
+		}
+		if got := collectKeys(t, snap, ""); got != "a=1;" {
+			t.Fatalf("got %s from snapshot", got)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+// Package storage defines the key-value database used by the node,
+// the engines implementing it are in the sub packages.
+package storage
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when a key is not stored.
+	ErrNotFound = errors.New("key not found")
+	// ErrTxnTooBig is returned by Txn.Put when the transaction reached
+	// the size the database can commit at once.
+	ErrTxnTooBig = errors.New("transaction too big")
+)
+
+// Reader reads the keys of a database or of a snapshot of it.
+type Reader interface {
+	// GetData returns the value of key, or ErrNotFound.
+	GetData(key []byte) ([]byte, error)
+	HasData(key []byte) (bool, error)
+	// PrefixForeachData calls fn for the keys starting with prefix in
+	// ascending order, until fn returns an error, which is returned.
+	PrefixForeachData(prefix []byte, fn func(k []byte, v []byte) error) error
+}
+
+// Database is a key-value store with ordered keys.
+type Database interface {
+	Reader
+	SetData(key []byte, val []byte) error
+	DelData(key []byte) error
+	// ForeachData calls fn for every key in ascending order.
+	ForeachData(fn func(k []byte, v []byte) error) error
+	NewIterator() Iterator
+	// NewWriteBatch starts a batch of writes committed by CommitWriteBatch,
+	// a large batch may be committed in several parts.
+	NewWriteBatch() WriteBatch
+	CommitWriteBatch(batch WriteBatch) error
+	// NewTxn starts a transaction, which is committed as a whole.
+	NewTxn() Txn
+	// NewSnapshot returns a consistent view of the database at the time
+	// of the call, later writes are not seen through it.
+	NewSnapshot() Snapshot
+	Close() error
+}
+
+// Writer is implemented by the batches and the transactions.
+type Writer interface {
+	Put(key, value []byte) error
+	Delete(key []byte) error
+}
+
+type WriteBatch interface {
+	Writer
+	// Destroy drops the writes of a batch which is not committed.
+	Destroy()
+}
+
+// Txn groups writes which become visible all at once on Commit,
+// or not at all when it is discarded or the process stops before.
+type Txn interface {
+	Writer
+	Commit() error
+	Discard()
+}
+
+// Iterator walks the keys of a database in ascending order,
+// Next must be called before reading the first key. After Seek,
+// Next moves to the first key greater than or equal to key.
+type Iterator interface {
+	Next() bool
+	Seek(key []byte)
+	Key() []byte
+	Val() []byte
+	Close()
+}
+
+type Snapshot interface {
+	Reader
+	Release()
+}
@@ -0,0 +1,320 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package storage
+
+import "bytes"
+
+// Table is a namespace of a database, its keys are stored with a prefix.
+// Several tables can share a database and be written at once by a Batch.
+type Table struct {
+	db     Database
+	prefix []byte
+}
+
+var _ Database = (*Table)(nil)
+
+// NewTable returns the table of db with the given prefix. The prefixes of
+// the tables of a database must not be prefixes of each other.
+func NewTable(db Database, prefix string) *Table {
+	if t, ok := db.(*Table); ok {
+		return &Table{
+			db:     t.db,
+			prefix: append(append([]byte{}, t.prefix...), prefix...),
+		}
+	}
+	return &Table{
+		db:     db,
+		prefix: []byte(prefix),
+	}
+}
+
+func (t *Table) key(key []byte) []byte {
+	return append(append(make([]byte, 0, len(t.prefix)+len(key)), t.prefix...), key...)
+}
+
+func (t *Table) GetData(key []byte) ([]byte, error) {
+	return t.db.GetData(t.key(key))
+}
+
+func (t *Table) HasData(key []byte) (bool, error) {
+	return t.db.HasData(t.key(key))
+}
+
+func (t *Table) SetData(key []byte, val []byte) error {
+	return t.db.SetData(t.key(key), val)
+}
+
+func (t *Table) DelData(key []byte) error {
+	return t.db.DelData(t.key(key))
+}
+
+func prefixForeach(r Reader, tablePrefix, prefix []byte, fn func(k []byte, v []byte) error) error {
+	full := append(append([]byte{}, tablePrefix...), prefix...)
+	return r.PrefixForeachData(full, func(k []byte, v []byte) error {
+		return fn(k[len(tablePrefix):], v)
+	})
+}
+
+func (t *Table) PrefixForeachData(prefix []byte, fn func(k []byte, v []byte) error) error {
+	return prefixForeach(t.db, t.prefix, prefix, fn)
+}
+
+func (t *Table) ForeachData(fn func(k []byte, v []byte) error) error {
+	return t.PrefixForeachData(nil, fn)
+}
+
+// tableIterator skips the keys of the database outside of the table.
+type tableIterator struct {
+	it     Iterator
+	prefix []byte
+}
+
+func (it *tableIterator) Next() bool {
+	for it.it.Next() {
+		key := it.it.Key()
+		if bytes.HasPrefix(key, it.prefix) {
+			return true
+		}
+		if bytes.Compare(key, it.prefix) > 0 {
+			return false
+		}
+	}
+	return false
+}
+
+func (it *tableIterator) Seek(key []byte) {
+	it.it.Seek(append(append([]byte{}, it.prefix...), key...))
+}
+
+func (it *tableIterator) Key() []byte {
+	return it.it.Key()[len(it.prefix):]
+}
+
+func (it *tableIterator) Val() []byte {
+	return it.it.Val()
+}
+
+func (it *tableIterator) Close() {
+	it.it.Close()
+}
+
+func (t *Table) NewIterator() Iterator {
+	it := &tableIterator{
+		it:     t.db.NewIterator(),
+		prefix: t.prefix,
+	}
+	it.Seek(nil)
+	return it
+}
+
+// tableWriter writes the keys of a table through a writer of its database.
+type tableWriter struct {
+	w      Writer
+	prefix []byte
+}
+
+func (w *tableWriter) key(key []byte) []byte {
+	return append(append([]byte{}, w.prefix...), key...)
+}
+
+func (w *tableWriter) Put(key, value []byte) error {
+	return w.w.Put(w.key(key), value)
+}
+
+func (w *tableWriter) Delete(key []byte) error {
+	return w.w.Delete(w.key(key))
+}
+
+type tableBatch struct {
+	tableWriter
+	batch WriteBatch
+}
+
+func (b *tableBatch) Destroy() {
+	b.batch.Destroy()
+}
+
+func (t *Table) NewWriteBatch() WriteBatch {
+	batch := t.db.NewWriteBatch()
+	return &tableBatch{
+		tableWriter: tableWriter{w: batch, prefix: t.prefix},
+		batch:       batch,
+	}
+}
+
+func (t *Table) CommitWriteBatch(batch WriteBatch) error {
+	return t.db.CommitWriteBatch(batch.(*tableBatch).batch)
+}
+
+type tableTxn struct {
+	tableWriter
+	txn Txn
+}
+
+func (t *tableTxn) Commit() error {
+	return t.txn.Commit()
+}
+
+func (t *tableTxn) Discard() {
+	t.txn.Discard()
+}
+
+func (t *Table) NewTxn() Txn {
+	txn := t.db.NewTxn()
+	return &tableTxn{
+		tableWriter: tableWriter{w: txn, prefix: t.prefix},
+		txn:         txn,
+	}
+}
+
+type tableSnapshot struct {
+	snap   Snapshot
+	prefix []byte
+}
+
+func (s *tableSnapshot) GetData(key []byte) ([]byte, error) {
+	return s.snap.GetData(append(append([]byte{}, s.prefix...), key...))
+}
+
+func (s *tableSnapshot) HasData(key []byte) (bool, error) {
+	return s.snap.HasData(append(append([]byte{}, s.prefix...), key...))
+}
+
+func (s *tableSnapshot) PrefixForeachData(prefix []byte, fn func(k []byte, v []byte) error) error {
+	return prefixForeach(s.snap, s.prefix, prefix, fn)
+}
+
+func (s *tableSnapshot) Release() {
+	s.snap.Release()
+}
+
+func (t *Table) NewSnapshot() Snapshot {
+	return &tableSnapshot{
+		snap:   t.db.NewSnapshot(),
+		prefix: t.prefix,
+	}
+}
+
+// Close does nothing, the database of the table is closed by its owner.
+func (t *Table) Close() error {
+	return nil
+}
+
+// Batch groups writes to several databases. The writes to the tables of
+// the same database are committed at once, other databases are committed
+// one after the other in the order they were first written.
+type Batch struct {
+	txns  []Txn
+	bases map[Database]Txn
+}
+
+func NewBatch() *Batch {
+	return &Batch{
+		bases: make(map[Database]Txn),
+	}
+}
+
+// Writer returns the writer of the batch for db.
+func (b *Batch) Writer(db Database) Writer {
+	base := db
+	var prefix []byte
+	if t, ok := db.(*Table); ok {
+		base, prefix = t.db, t.prefix
+	}
+	txn, ok := b.bases[base]
+	if !ok {
+		txn = base.NewTxn()
+		b.bases[base] = txn
+		b.txns = append(b.txns, txn)
+	}
+	if prefix == nil {
+		return txn
+	}
+	return &tableWriter{w: txn, prefix: prefix}
+}
+
+func (b *Batch) Commit() error {
+	for _, txn := range b.txns {
+		if err := txn.Commit(); err != nil {
+			return err
+		}
+	}
+	b.txns = nil
+	return nil
+}
+
+// Discard drops the writes which are not committed.
+func (b *Batch) Discard() {
+	for _, txn := range b.txns {
+		txn.Discard()
+	}
+	b.txns = nil
+}
+
+// Update writes to db in a transaction committed when fn succeeds.
+func Update(db Database, fn func(w Writer) error) error {
+	txn := db.NewTxn()
+	defer txn.Discard()
+	if err := fn(txn); err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+// UpdateChunked is like Update, but when the transaction is full it is
+// committed and the writes go on in a new one, so the writes of fn are not
+// atomic. The writes committed before an error are kept.
+func UpdateChunked(db Database, fn func(w Writer) error) error {
+	w := &chunkedWriter{db: db, txn: db.NewTxn()}
+	defer func() {
+		w.txn.Discard()
+	}()
+	if err := fn(w); err != nil {
+		return err
+	}
+	return w.txn.Commit()
+}
+
+// chunkedWriter starts a new transaction when the current one is full.
+type chunkedWriter struct {
+	db  Database
+	txn Txn
+}
+
+func (w *chunkedWriter) write(fn func(txn Txn) error) error {
+	err := fn(w.txn)
+	if err != ErrTxnTooBig {
+		return err
+	}
+	if err = w.txn.Commit(); err != nil {
+		return err
+	}
+	w.txn = w.db.NewTxn()
+	return fn(w.txn)
+}
+
+func (w *chunkedWriter) Put(key, value []byte) error {
+	return w.write(func(txn Txn) error {
+		return txn.Put(key, value)
+	})
+}
+
+func (w *chunkedWriter) Delete(key []byte) error {
+	return w.write(func(txn Txn) error {
+		return txn.Delete(key)
+	})
+}
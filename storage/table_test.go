@@ -0,0 +1,111 @@
+// Copyright 2018 The xfsgo Authors
+// This file is part of the xfsgo library.
+//
+// The xfsgo library is free software: you can redistribute it and/or modify
+// it under the terms of the MIT Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The xfsgo library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// MIT Lesser General Public License for more details.
+//
+// You should have received a copy of the MIT Lesser General Public License
+// along with the xfsgo library. If not, see <https://mit-license.org/>.
+
+package storage_test
+
+import (
+	"testing"
+	"xfsgo/storage"
+	"xfsgo/storage/memdb"
+)
+
+func tableKeys(t *testing.T, db storage.Database) string {
+	keys := ""
+	if err := db.ForeachData(func(k []byte, v []byte) error {
+		keys += string(k) + "=" + string(v) + ";"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestTable(t *testing.T) {
+	base := memdb.New()
+	a := storage.NewTable(base, "a:")
+	b := storage.NewTable(base, "b:")
+	_ = base.SetData([]byte("0"), []byte("base"))
+	_ = a.SetData([]byte("k1"), []byte("a1"))
+	_ = a.SetData([]byte("k2"), []byte("a2"))
+	_ = b.SetData([]byte("k1"), []byte("b1"))
+	_ = base.SetData([]byte("c"), []byte("base"))
+	if got, err := a.GetData([]byte("k1")); err != nil || string(got) != "a1" {
+		t.Fatalf("got %q err %v", got, err)
+	}
+	if _, err := b.GetData([]byte("k2")); err != storage.ErrNotFound {
+		t.Fatalf("got err %v, want %v", err, storage.ErrNotFound)
+	}
+	if got := tableKeys(t, a); got != "k1=a1;k2=a2;" {
+		t.Fatalf("got %s", got)
+	}
+	keys := ""
+	it := b.NewIterator()
+	for it.Next() {
+		keys += string(it.Key()) + ";"
+	}
+	it.Close()
+	if keys != "k1;" {
+		t.Fatalf("got %s from the iterator", keys)
+	}
+	keys = ""
+	it = a.NewIterator()
+	it.Seek([]byte("k15"))
+	for it.Next() {
+		keys += string(it.Key()) + ";"
+	}
+	it.Close()
+	if keys != "k2;" {
+		t.Fatalf("got %s from the iterator after seek", keys)
+	}
+	if got := tableKeys(t, base); got != "0=base;a:k1=a1;a:k2=a2;b:k1=b1;c=base;" {
+		t.Fatalf("got %s in the database", got)
+	}
+	nested := storage.NewTable(a, "n:")
+	_ = nested.SetData([]byte("k"), []byte("n"))
+	if got, _ := base.GetData([]byte("a:n:k")); string(got) != "n" {
+		t.Fatalf("nested table key not stored under both prefixes")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	base := memdb.New()
+	other := memdb.New()
+	a := storage.NewTable(base, "a:")
+	b := storage.NewTable(base, "b:")
+	batch := storage.NewBatch()
+	_ = batch.Writer(a).Put([]byte("k"), []byte("1"))
+	_ = batch.Writer(b).Put([]byte("k"), []byte("2"))
+	_ = batch.Writer(other).Put([]byte("k"), []byte("3"))
+	if base.Len() != 0 || other.Len() != 0 {
+		t.Fatalf("batch writes visible before commit")
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := tableKeys(t, base); got != "a:k=1;b:k=2;" {
+		t.Fatalf("got %s", got)
+	}
+	if got := tableKeys(t, other); got != "k=3;" {
+		t.Fatalf("got %s", got)
+	}
+
+	batch = storage.NewBatch()
+	_ = batch.Writer(a).Delete([]byte("k"))
+	batch.Discard()
+	if has, _ := a.HasData([]byte("k")); !has {
+		t.Fatalf("discarded batch deleted the key")
+	}
+}
@@ -19,6 +19,7 @@ package xfsgo
 import (
 	"crypto/ecdsa"
 	"encoding/json"
+	"errors"
 	"math/big"
 	"xfsgo/common"
 	"xfsgo/common/ahash"
@@ -28,12 +29,20 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	errNegativeValue = errors.New("transaction value is negative")
+	errNoValue       = errors.New("transaction value is missing")
+)
+
 // Transaction type.
 type Transaction struct {
 	To        common.Address `json:"to"`
 	Nonce     uint64         `json:"nonce"`
 	Value     *big.Int       `json:"value"`
 	Signature []byte         `json:"signature"`
+	// legacy transactions belong to the blocks before BlockVersion, they
+	// keep the JSON encoding their hash and signature are computed over.
+	legacy bool
 }
 
 func NewTransaction(to common.Address, value *big.Int) *Transaction {
@@ -43,12 +52,43 @@ func NewTransaction(to common.Address, value *big.Int) *Transaction {
 	}
 }
 
+// Encode returns the canonical binary encoding of the transaction, or the
+// JSON encoding of a legacy transaction.
+// tx = format(1byte)+to(25byte)+nonce(8byte)+value+signature
 func (t *Transaction) Encode() ([]byte, error) {
-	return json.Marshal(t)
+	if t.Value != nil && t.Value.Sign() < 0 {
+		return nil, errNegativeValue
+	}
+	if t.legacy {
+		return json.Marshal(t)
+	}
+	w := rawencode.NewBinaryWriter()
+	w.WriteUint8(binaryFormatV1)
+	w.WriteFixed(t.To[:])
+	w.WriteUint64(t.Nonce)
+	w.WriteBigInt(t.Value)
+	w.WriteBytes(t.Signature)
+	return w.Bytes(), nil
 }
 
+// Decode parses the binary encoding of the transaction, or its legacy JSON
+// encoding. The transaction is not marked legacy, see Block.Decode.
 func (t *Transaction) Decode(data []byte) error {
-	return json.Unmarshal(data, t)
+	if rawencode.IsLegacyJSON(data) {
+		return json.Unmarshal(data, t)
+	}
+	r := rawencode.NewBinaryReader(data)
+	if r.ReadUint8() != binaryFormatV1 {
+		return rawencode.ErrFormatVersion
+	}
+	r.ReadFixed(t.To[:])
+	t.Nonce = r.ReadUint64()
+	t.Value = r.ReadBigInt()
+	t.Signature = r.ReadBytes()
+	if len(t.Signature) == 0 {
+		t.Signature = nil
+	}
+	return r.Finish()
 }
 
 func (t *Transaction) Hash() common.Hash {
@@ -112,8 +152,8 @@ func (t *Transaction) FromAddr() (common.Address, error) {
 	if err != nil {
 		return common.Bytes2Address([]byte{}), err
 	}
-	logrus.Infof("from addr pubx: %x", pub.X.Bytes())
-	logrus.Infof("from addr puby: %x", pub.Y.Bytes())
+	logrus.Debugf("from addr pubx: %x", pub.X.Bytes())
+	logrus.Debugf("from addr puby: %x", pub.Y.Bytes())
 	addr := crypto.DefaultPubKey2Addr(pub)
 	return addr, nil
 }
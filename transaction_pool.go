@@ -80,6 +80,9 @@ func (pool *TxPool) validateTx(tx *Transaction) error {
 		from common.Address
 		err  error
 	)
+	if tx.Value == nil {
+		return errNoValue
+	}
 	if from, err = tx.FromAddr(); err != nil {
 		return errors.New("from fields is invalid")
 	}
@@ -254,6 +257,22 @@ func (pool *TxPool) GetTransactions() []*Transaction {
 	return txs
 }
 
+// GetTransaction returns the transaction with the hash and whether it is
+// processable, or nil when it is not in the pool.
+func (pool *TxPool) GetTransaction(hash common.Hash) (*Transaction, bool) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	if tx, ok := pool.pending[hash]; ok {
+		return tx, true
+	}
+	for _, txs := range pool.queue {
+		if tx, ok := txs[hash]; ok {
+			return tx, false
+		}
+	}
+	return nil, false
+}
+
 func (pool *TxPool) GetTransactionsSize() int {
 	return len(pool.GetTransactions())
 }
@@ -22,7 +22,7 @@ import (
 	"sync"
 	"xfsgo/common"
 	"xfsgo/crypto"
-	"xfsgo/storage/badger"
+	"xfsgo/storage"
 )
 
 // Wallet represents a software wallet that has a default address derived from private key.
@@ -35,7 +35,7 @@ type Wallet struct {
 }
 
 // NewWallet constructs and returns a new Wallet instance with badger db.
-func NewWallet(storage *badger.Storage) *Wallet {
+func NewWallet(storage storage.Database) *Wallet {
 	w := &Wallet{
 		db:    newKeyStoreDB(storage),
 		cache: make(map[common.Address]*ecdsa.PrivateKey),